@@ -0,0 +1,128 @@
+// Command deadletter sends the logs kept in dead letter files written by the
+// retry_on_failure.dead_letter setting back into a pipeline.
+//
+// Usage:
+//
+//	deadletter -endpoint http://localhost:4318/v1/logs [-reason max_elapsed_time] FILE...
+//	deadletter -output replay.json [-reason max_elapsed_time] FILE...
+//
+// With -endpoint every record is posted as OTLP-JSON to an OTLP/HTTP logs endpoint.
+// With -output the logs are written as OTLP-JSON lines, one request per line, which
+// can be read by an OTLP JSON file receiver. Rotated and gzip compressed files are supported.
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/fsgonz/otelnetstatsreceiver/internal/consumerretry"
+)
+
+// errUsage is returned for invalid arguments, after the usage is printed.
+var errUsage = errors.New("invalid arguments")
+
+func main() {
+	if err := run(os.Args[1:], os.Stdout, os.Stderr); err != nil {
+		if errors.Is(err, errUsage) {
+			os.Exit(2)
+		}
+		fmt.Fprintln(os.Stderr, "deadletter:", err)
+		os.Exit(1)
+	}
+}
+
+// run re-injects the records of the dead letter files named in args. It returns instead of
+// exiting, so that the files it opened are closed first.
+func run(args []string, stdout, stderr io.Writer) error {
+	flags := flag.NewFlagSet("deadletter", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	endpoint := flags.String("endpoint", "", "OTLP/HTTP logs endpoint to post the logs to")
+	output := flags.String("output", "", "file to write the logs to as OTLP-JSON lines, - for stdout")
+	reason := flags.String("reason", "", "only re-inject the logs dropped for this reason")
+	timeout := flags.Duration("timeout", 10*time.Second, "timeout of each request to the endpoint")
+	if err := flags.Parse(args); err != nil {
+		return errUsage
+	}
+
+	if (*endpoint == "") == (*output == "") || flags.NArg() == 0 {
+		fmt.Fprintln(stderr, "usage: deadletter (-endpoint URL | -output FILE) [-reason REASON] FILE...")
+		return errUsage
+	}
+
+	var send func(consumerretry.DeadLetterRecord) error
+	if *endpoint != "" {
+		client := &http.Client{Timeout: *timeout}
+		send = func(record consumerretry.DeadLetterRecord) error {
+			return post(client, *endpoint, record.Logs)
+		}
+	} else {
+		w := stdout
+		if *output != "-" {
+			f, err := os.OpenFile(*output, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			w = f
+		}
+		send = func(record consumerretry.DeadLetterRecord) error {
+			_, err := w.Write(append(append([]byte{}, record.Logs...), '\n'))
+			return err
+		}
+	}
+
+	sent := 0
+	for _, name := range flags.Args() {
+		err := readFile(name, func(record consumerretry.DeadLetterRecord) error {
+			if *reason != "" && record.Reason != *reason {
+				return nil
+			}
+			if err := send(record); err != nil {
+				return err
+			}
+			sent++
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	fmt.Fprintf(stderr, "re-injected %d records\n", sent)
+	return nil
+}
+
+func readFile(name string, fn func(consumerretry.DeadLetterRecord) error) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return consumerretry.ReadDeadLetters(f, fn)
+}
+
+func post(client *http.Client, endpoint string, body []byte) error {
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return errors.New("endpoint returned " + resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/plog"
+)
+
+const testFile = "testdata/deadletter.json"
+
+func TestRunOutput(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "replay.json")
+	var stderr bytes.Buffer
+	require.NoError(t, run([]string{"-output", output, testFile}, io.Discard, &stderr))
+	require.Equal(t, "re-injected 2 records\n", stderr.String())
+	require.Equal(t, []string{"first", "second"}, bodies(t, readLines(t, output)...))
+
+	// -reason only re-injects the records dropped for that reason.
+	var stdout bytes.Buffer
+	require.NoError(t, run([]string{"-output", "-", "-reason", "permanent_error", testFile}, &stdout, io.Discard))
+	require.Equal(t, []string{"second"}, bodies(t, strings.TrimSpace(stdout.String())))
+}
+
+func TestRunEndpoint(t *testing.T) {
+	var mu sync.Mutex
+	var received []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		require.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		mu.Lock()
+		received = append(received, string(body))
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	require.NoError(t, run([]string{"-endpoint", server.URL, testFile}, io.Discard, io.Discard))
+	require.Equal(t, []string{"first", "second"}, bodies(t, received...))
+}
+
+func TestRunErrors(t *testing.T) {
+	require.ErrorIs(t, run([]string{testFile}, io.Discard, io.Discard), errUsage)
+	require.ErrorIs(t, run([]string{"-output", "-"}, io.Discard, io.Discard), errUsage)
+	require.Error(t, run([]string{"-output", "-", "testdata/missing.json"}, io.Discard, io.Discard))
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+	require.Error(t, run([]string{"-endpoint", server.URL, testFile}, io.Discard, io.Discard))
+}
+
+func readLines(t *testing.T, name string) []string {
+	data, err := os.ReadFile(name)
+	require.NoError(t, err)
+	return strings.Split(strings.TrimSpace(string(data)), "\n")
+}
+
+// bodies returns the bodies of the log records of the OTLP-JSON documents.
+func bodies(t *testing.T, docs ...string) []string {
+	var bodies []string
+	for _, doc := range docs {
+		logs, err := (&plog.JSONUnmarshaler{}).UnmarshalLogs([]byte(doc))
+		require.NoError(t, err)
+		records := logs.ResourceLogs().At(0).ScopeLogs().At(0).LogRecords()
+		for i := 0; i < records.Len(); i++ {
+			bodies = append(bodies, records.At(i).Body().Str())
+		}
+	}
+	return bodies
+}
@@ -0,0 +1,81 @@
+// Command decryptrecords decrypts the files written by a file_logger sampler output with encryption
+// enabled.
+//
+// Usage:
+//
+//	decryptrecords -key-file encryption.keys [-output FILE] [-allow-plain] FILE...
+//
+// FILE is the output file or any of its rotated backups, whether their records or the whole files
+// were encrypted, gzip compressed or not. The records are written, one per line, to -output, or
+// stdout if not set. Plain records are copied as is, unless the file also holds encrypted records,
+// in which case they are an error, as they may have been added to it. -allow-plain copies them
+// anyway, for files written before encryption was enabled.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/fsgonz/otelnetstatsreceiver/internal/envelope"
+)
+
+func main() {
+	keyFile := flag.String("key-file", "", "file holding the encryption keys of the sampler")
+	output := flag.String("output", "", "file to write the records to, stdout if not set")
+	allowPlain := flag.Bool("allow-plain", false, "copy the plain records of files holding encrypted records")
+	flag.Parse()
+
+	if *keyFile == "" || flag.NArg() == 0 {
+		fmt.Fprintln(os.Stderr, "usage: decryptrecords -key-file FILE [-output FILE] [-allow-plain] FILE...")
+		os.Exit(2)
+	}
+
+	keyring, err := envelope.LoadKeyring(*keyFile)
+	if err != nil {
+		fail(err)
+	}
+
+	if *output == "" {
+		if err := decryptFiles(keyring, flag.Args(), os.Stdout, *allowPlain); err != nil {
+			fail(err)
+		}
+		return
+	}
+	f, err := os.OpenFile(*output, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		fail(err)
+	}
+	// The output is closed explicitly, as fail exits without running deferred calls.
+	err = decryptFiles(keyring, flag.Args(), f, *allowPlain)
+	if closeErr := f.Close(); err == nil && closeErr != nil {
+		err = fmt.Errorf("%s: %w", *output, closeErr)
+	}
+	if err != nil {
+		fail(err)
+	}
+}
+
+func decryptFiles(keyring *envelope.Keyring, names []string, w io.Writer, allowPlain bool) error {
+	for _, name := range names {
+		if err := decryptFile(keyring, name, w, allowPlain); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func decryptFile(keyring *envelope.Keyring, name string, w io.Writer, allowPlain bool) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return keyring.Decrypt(w, f, allowPlain)
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, "decryptrecords:", err)
+	os.Exit(1)
+}
@@ -0,0 +1,50 @@
+// Command verifyrecords checks the records written by a file_logger sampler output with signing
+// enabled, reporting the records modified, dropped or reordered.
+//
+// Usage:
+//
+//	verifyrecords -key-file signing.key FILE...
+//
+// FILE is the output file and its rotated backups, in any order. Gzip compressed files are
+// supported. The exit status is 1 if any problem is found.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/fsgonz/otelnetstatsreceiver/internal/signing"
+)
+
+func main() {
+	keyFile := flag.String("key-file", "", "file holding the signing key of the sampler")
+	flag.Parse()
+
+	if *keyFile == "" || flag.NArg() == 0 {
+		fmt.Fprintln(os.Stderr, "usage: verifyrecords -key-file FILE FILE...")
+		os.Exit(2)
+	}
+
+	key, err := signing.LoadKey(*keyFile)
+	if err != nil {
+		fail(err)
+	}
+	report, err := signing.Verify(key, flag.Args())
+	if err != nil {
+		fail(err)
+	}
+
+	for _, problem := range report.Problems {
+		fmt.Println(problem)
+	}
+	fmt.Fprintf(os.Stderr, "verified %d records, sequence %d to %d, %d problems\n", report.Records, report.FirstSeq, report.LastSeq, len(report.Problems))
+	if len(report.Problems) > 0 {
+		os.Exit(1)
+	}
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, "verifyrecords:", err)
+	os.Exit(1)
+}
@@ -0,0 +1,93 @@
+package adapter
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/fsgonz/otelnetstatsreceiver/internal/consumerretry"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/entry"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/operator/helper"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/receiver/receiverhelper"
+	"go.opentelemetry.io/collector/receiver/receivertest"
+)
+
+// BenchmarkReceiverThroughput sends entries from the emitter through the converter to the consumer,
+// as the file input does, for different worker and batching settings.
+func BenchmarkReceiverThroughput(b *testing.B) {
+	for _, workers := range []int{1, 4} {
+		for _, batchSize := range []uint{10, 100, 1000} {
+			for _, flushInterval := range []time.Duration{10 * time.Millisecond, DefaultFlushInterval} {
+				cfg := BaseConfig{NumWorkers: workers, MaxBatchSize: batchSize, FlushInterval: flushInterval}
+				name := fmt.Sprintf("workers=%d/batch=%d/flush=%s", workers, batchSize, flushInterval)
+				b.Run(name, func(b *testing.B) {
+					benchmarkReceiver(b, cfg)
+				})
+			}
+		}
+	}
+}
+
+func benchmarkReceiver(b *testing.B, cfg BaseConfig) {
+	sink := new(consumertest.LogsSink)
+	r := newBenchmarkReceiver(b, cfg, sink)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	r.cancel = cancel
+	require.NoError(b, r.emitter.Start(nil))
+	r.converter.Start()
+	r.wg.Add(2)
+	go r.emitterLoop(ctx)
+	go r.consumerLoop(ctx)
+
+	entries := make([]*entry.Entry, 0, b.N)
+	for i := 0; i < b.N; i++ {
+		e := entry.New()
+		e.Body = fmt.Sprintf("netstats sample %d", i)
+		e.Resource = map[string]any{"host.name": "bench"}
+		e.Attributes = map[string]any{"log.file.name": fmt.Sprintf("file%d.log", i%8)}
+		entries = append(entries, e)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	start := time.Now()
+	for _, e := range entries {
+		require.NoError(b, r.emitter.Process(ctx, e))
+	}
+	for sink.LogRecordCount() < b.N {
+		time.Sleep(time.Millisecond)
+	}
+	b.StopTimer()
+	b.ReportMetric(float64(b.N)/time.Since(start).Seconds(), "entries/s")
+
+	require.NoError(b, r.emitter.Stop())
+	r.converter.Stop()
+	r.cancel()
+	r.wg.Wait()
+}
+
+func newBenchmarkReceiver(b *testing.B, cfg BaseConfig, sink *consumertest.LogsSink) *receiver {
+	params := receivertest.NewNopCreateSettings()
+	obsrecv, err := receiverhelper.NewObsReport(receiverhelper.ObsReportSettings{
+		ReceiverID:             params.ID,
+		ReceiverCreateSettings: params,
+	})
+	require.NoError(b, err)
+	retryConsumer, err := consumerretry.NewLogs(consumerretry.NewDefaultConfig(), component.MustNewID("otelnetstatsreceiver"), params.TelemetrySettings, sink)
+	require.NoError(b, err)
+
+	return &receiver{
+		set:       params.TelemetrySettings,
+		id:        params.ID,
+		emitter:   newDrainingEmitter(params.TelemetrySettings, helper.WithMaxBatchSize(cfg.MaxBatchSize), helper.WithFlushInterval(cfg.FlushInterval)),
+		converter: NewConverter(params.TelemetrySettings, withWorkerCount(cfg.NumWorkers)),
+		consumer:  retryConsumer,
+		obsrecv:   obsrecv,
+		status:    newStatusReporter(params.TelemetrySettings),
+	}
+}
@@ -0,0 +1,197 @@
+package adapter // import "github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/adapter"
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/entry"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/noop"
+)
+
+const scopeName = "github.com/fsgonz/otelnetstatsreceiver/internal/adapter"
+
+// inflightBudget accounts the entries sent by the emitter until the consumer is done with them.
+// Acquiring blocks while the budget is exceeded, which blocks the emitter and so the file readers.
+type inflightBudget struct {
+	maxEntries int
+	maxBytes   int64
+
+	mu      sync.Mutex
+	entries int
+	bytes   int64
+	// released is closed and replaced every time entries are released.
+	released chan struct{}
+
+	attrs         attribute.Set
+	throttles     metric.Int64Counter
+	throttledTime metric.Float64Counter
+	// registration observes the entries in flight until the receiver shuts down.
+	registration metric.Registration
+}
+
+// newInflightBudget returns the budget for cfg, nil if no limit is set.
+func newInflightBudget(cfg InflightLimitConfig, id component.ID, set component.TelemetrySettings) (*inflightBudget, error) {
+	if cfg.MaxEntries == 0 && cfg.MaxSizeMiB == 0 {
+		return nil, nil
+	}
+	meterProvider := set.MeterProvider
+	if meterProvider == nil {
+		meterProvider = noop.NewMeterProvider()
+	}
+	meter := meterProvider.Meter(scopeName)
+	b := &inflightBudget{
+		maxEntries: cfg.MaxEntries,
+		maxBytes:   int64(cfg.MaxSizeMiB) << 20,
+		released:   make(chan struct{}),
+		attrs:      attribute.NewSet(attribute.String("receiver", id.String())),
+	}
+
+	var err error
+	if b.throttles, err = meter.Int64Counter("otelnetstats_inflight_throttled",
+		metric.WithDescription("Number of times reading or sampling was paused because the inflight limit was exceeded."),
+		metric.WithUnit("{pauses}")); err != nil {
+		return nil, err
+	}
+	if b.throttledTime, err = meter.Float64Counter("otelnetstats_inflight_throttled_time",
+		metric.WithDescription("Time reading or sampling was paused because the inflight limit was exceeded."),
+		metric.WithUnit("s")); err != nil {
+		return nil, err
+	}
+	entries, err := meter.Int64ObservableGauge("otelnetstats_inflight_entries",
+		metric.WithDescription("Number of entries read but not yet consumed."),
+		metric.WithUnit("{entries}"))
+	if err != nil {
+		return nil, err
+	}
+	size, err := meter.Int64ObservableGauge("otelnetstats_inflight_size",
+		metric.WithDescription("Estimated size of the entries read but not yet consumed."),
+		metric.WithUnit("By"))
+	if err != nil {
+		return nil, err
+	}
+	b.registration, err = meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		o.ObserveInt64(entries, int64(b.entries), metric.WithAttributeSet(b.attrs))
+		o.ObserveInt64(size, b.bytes, metric.WithAttributeSet(b.attrs))
+		return nil
+	}, entries, size)
+	if err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// shutdown stops observing the entries in flight, so that a stopped receiver no longer reports them.
+func (b *inflightBudget) shutdown() error {
+	if b == nil {
+		return nil
+	}
+	return b.registration.Unregister()
+}
+
+// exceeded tells whether the budget is used up, b.mu must be held.
+func (b *inflightBudget) exceeded() bool {
+	return (b.maxEntries > 0 && b.entries >= b.maxEntries) || (b.maxBytes > 0 && b.bytes >= b.maxBytes)
+}
+
+// acquire waits for the budget to be available and accounts entries in it. A batch is admitted
+// as long as the budget is not exceeded yet, so a batch larger than the budget still goes through.
+func (b *inflightBudget) acquire(ctx context.Context, entries []*entry.Entry) error {
+	if err := b.wait(ctx); err != nil {
+		return err
+	}
+	var size int64
+	for _, e := range entries {
+		size += entrySize(e)
+	}
+	b.mu.Lock()
+	b.entries += len(entries)
+	b.bytes += size
+	b.mu.Unlock()
+	return nil
+}
+
+// wait blocks until the budget is not exceeded, recording the pause.
+func (b *inflightBudget) wait(ctx context.Context) error {
+	var start time.Time
+	for {
+		b.mu.Lock()
+		if !b.exceeded() {
+			b.mu.Unlock()
+			if !start.IsZero() {
+				b.throttledTime.Add(ctx, time.Since(start).Seconds(), metric.WithAttributeSet(b.attrs))
+			}
+			return nil
+		}
+		released := b.released
+		b.mu.Unlock()
+
+		if start.IsZero() {
+			start = time.Now()
+			b.throttles.Add(ctx, 1, metric.WithAttributeSet(b.attrs))
+		}
+		select {
+		case <-released:
+		case <-ctx.Done():
+			b.throttledTime.Add(context.WithoutCancel(ctx), time.Since(start).Seconds(), metric.WithAttributeSet(b.attrs))
+			return ctx.Err()
+		}
+	}
+}
+
+// release gives back the budget of n consumed entries. The converter regroups entries, so
+// their size is released in proportion to the entries in flight.
+func (b *inflightBudget) release(n int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if n >= b.entries {
+		b.entries, b.bytes = 0, 0
+	} else {
+		b.bytes -= b.bytes * int64(n) / int64(b.entries)
+		b.entries -= n
+	}
+	close(b.released)
+	b.released = make(chan struct{})
+}
+
+// entrySize estimates the memory held by an entry.
+func entrySize(e *entry.Entry) int64 {
+	const entryOverhead = 128
+	return entryOverhead + valueSize(e.Body) + valueSize(e.Attributes) + valueSize(e.Resource)
+}
+
+func valueSize(v any) int64 {
+	switch v := v.(type) {
+	case string:
+		return int64(len(v))
+	case []byte:
+		return int64(len(v))
+	case map[string]any:
+		var size int64
+		for k, value := range v {
+			size += int64(len(k)) + valueSize(value)
+		}
+		return size
+	case map[string]string:
+		var size int64
+		for k, value := range v {
+			size += int64(len(k) + len(value))
+		}
+		return size
+	case []any:
+		var size int64
+		for _, value := range v {
+			size += valueSize(value)
+		}
+		return size
+	case nil:
+		return 0
+	default:
+		return 8
+	}
+}
@@ -0,0 +1,72 @@
+package adapter
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/entry"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componenttest"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestInflightBudget(t *testing.T) {
+	budget, err := newInflightBudget(InflightLimitConfig{}, component.MustNewID("otelnetstatsreceiver"), componenttest.NewNopTelemetrySettings())
+	require.NoError(t, err)
+	require.Nil(t, budget, "no limit set")
+
+	budget, err = newInflightBudget(InflightLimitConfig{MaxEntries: 2}, component.MustNewID("otelnetstatsreceiver"), componenttest.NewNopTelemetrySettings())
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	require.NoError(t, budget.acquire(ctx, []*entry.Entry{entry.New(), entry.New(), entry.New()}), "a batch is admitted below the limit")
+
+	acquired := make(chan error)
+	go func() {
+		acquired <- budget.acquire(ctx, []*entry.Entry{entry.New()})
+	}()
+	select {
+	case <-acquired:
+		t.Fatal("acquired while the limit is exceeded")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	budget.release(2)
+	require.NoError(t, <-acquired)
+	require.Equal(t, 2, budget.entries)
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	require.ErrorIs(t, budget.wait(cancelled), context.Canceled)
+
+	budget.release(2)
+	require.Zero(t, budget.entries)
+	require.Zero(t, budget.bytes)
+}
+
+func TestInflightBudgetShutdown(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	set := componenttest.NewNopTelemetrySettings()
+	set.MeterProvider = sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	budget, err := newInflightBudget(InflightLimitConfig{MaxEntries: 2}, component.MustNewID("otelnetstatsreceiver"), set)
+	require.NoError(t, err)
+
+	require.NoError(t, budget.acquire(context.Background(), []*entry.Entry{entry.New()}))
+	require.Equal(t, int64(1), collectMetrics(t, reader)["otelnetstats_inflight_entries"].(metricdata.Gauge[int64]).DataPoints[0].Value)
+
+	require.NoError(t, budget.shutdown())
+	require.NotContains(t, collectMetrics(t, reader), "otelnetstats_inflight_entries")
+
+	var disabled *inflightBudget
+	require.NoError(t, disabled.shutdown())
+}
+
+func TestEntrySize(t *testing.T) {
+	e := entry.New()
+	e.Body = "0123456789"
+	e.Attributes = map[string]any{"key": "value"}
+	require.Equal(t, int64(128+10+8), entrySize(e))
+}
@@ -4,9 +4,13 @@
 package adapter // import "github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/adapter"
 
 import (
+	"errors"
+	"fmt"
 	"github.com/fsgonz/otelnetstatsreceiver/internal/consumerretry"
+	"path/filepath"
 	"time"
 
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/entry"
 	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/operator"
 	"go.opentelemetry.io/collector/component"
 )
@@ -17,8 +21,98 @@ type BaseConfig struct {
 	StorageID      *component.ID        `mapstructure:"storage"`
 	RetryOnFailure consumerretry.Config `mapstructure:"retry_on_failure"`
 
-	// currently not configurable by users, but available for benchmarking
-	numWorkers    int
-	maxBatchSize  uint
-	flushInterval time.Duration
+	// NumWorkers is the number of workers converting entries into logs.
+	// One worker per four CPUs is started if NumWorkers == 0.
+	NumWorkers int `mapstructure:"num_workers"`
+	// MaxBatchSize is the number of entries batched before they are sent to the converter.
+	// Default value is 100.
+	MaxBatchSize uint `mapstructure:"max_batch_size"`
+	// FlushInterval is the maximum time entries are batched before they are sent to the converter.
+	// Default value is 100ms.
+	FlushInterval time.Duration `mapstructure:"flush_interval"`
+	// InflightLimit bounds the entries between the emitter and the consumer.
+	InflightLimit InflightLimitConfig `mapstructure:"inflight_limit"`
+	// Replay reads OTLP log files into the operators.
+	Replay ReplayConfig `mapstructure:"replay"`
+	// Dedup drops entries seen recently.
+	Dedup DedupConfig `mapstructure:"dedup"`
+}
+
+// DedupConfig drops entries whose key is among the keys of the last WindowSize entries. The window
+// is persisted in storage, so duplicates read again after a restart are dropped too.
+type DedupConfig struct {
+	// Enabled indicates whether duplicate entries are dropped. Default is false.
+	Enabled bool `mapstructure:"enabled"`
+	// KeyField is the field holding the id of the entry. When neither KeyField nor HashFields
+	// is set, the body is hashed.
+	KeyField entry.Field `mapstructure:"key_field"`
+	// HashFields, when set, are hashed together as the key instead of KeyField.
+	HashFields []entry.Field `mapstructure:"hash_fields"`
+	// WindowSize is the number of keys remembered. Default value is 10000.
+	WindowSize int `mapstructure:"window_size"`
+}
+
+// ReplayConfig selects OTLP log files, such as archived or dead lettered logs, whose records are
+// pushed through the operators once. With storage, files already replayed are skipped.
+type ReplayConfig struct {
+	// Include is the list of glob patterns of the files to replay.
+	Include []string `mapstructure:"include"`
+	// Format is otlp_json, one OTLP-JSON document after the other, or otlp_proto, OTLP protobuf
+	// messages prefixed by their size. Default is otlp_json.
+	Format string `mapstructure:"format"`
+}
+
+// InflightLimitConfig is the budget of entries read but not yet consumed. Once exceeded, reading
+// files and sampling is paused until the consumer catches up. Zero values disable each limit.
+type InflightLimitConfig struct {
+	// MaxEntries is the maximum number of entries in flight.
+	MaxEntries int `mapstructure:"max_entries"`
+	// MaxSizeMiB is the maximum estimated size in MiB of the entries in flight.
+	MaxSizeMiB int `mapstructure:"max_size_mib"`
+}
+
+const (
+	// DefaultMaxBatchSize is the default number of entries batched by the emitter.
+	DefaultMaxBatchSize uint = 100
+	// DefaultFlushInterval is the default interval the emitter flushes its batch at.
+	DefaultFlushInterval = 100 * time.Millisecond
+
+	// DefaultDedupWindowSize is the default number of keys remembered by dedup.
+	DefaultDedupWindowSize = 10000
+
+	// minFlushInterval keeps the emitter from flushing in a busy loop.
+	minFlushInterval = time.Millisecond
+)
+
+// Validate checks the worker, batching, inflight limit, replay and dedup settings, zero values fall back to the defaults.
+func (cfg *BaseConfig) Validate() error {
+	if cfg.NumWorkers < 0 {
+		return errors.New("num_workers must not be negative")
+	}
+	if cfg.FlushInterval < 0 {
+		return errors.New("flush_interval must not be negative")
+	}
+	if cfg.FlushInterval > 0 && cfg.FlushInterval < minFlushInterval {
+		return fmt.Errorf("flush_interval must be at least %s", minFlushInterval)
+	}
+	if cfg.InflightLimit.MaxEntries < 0 {
+		return errors.New("inflight_limit max_entries must not be negative")
+	}
+	if cfg.InflightLimit.MaxSizeMiB < 0 {
+		return errors.New("inflight_limit max_size_mib must not be negative")
+	}
+	if cfg.Dedup.WindowSize < 0 {
+		return errors.New("dedup window_size must not be negative")
+	}
+	switch cfg.Replay.Format {
+	case "", OTLP_JSON_REPLAY_FORMAT, OTLP_PROTO_REPLAY_FORMAT:
+	default:
+		return fmt.Errorf("replay format must be %s or %s", OTLP_JSON_REPLAY_FORMAT, OTLP_PROTO_REPLAY_FORMAT)
+	}
+	for _, pattern := range cfg.Replay.Include {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return fmt.Errorf("replay include %q: %w", pattern, err)
+		}
+	}
+	return nil
 }
@@ -0,0 +1,16 @@
+package adapter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBaseConfigValidate(t *testing.T) {
+	require.NoError(t, (&BaseConfig{}).Validate())
+	require.NoError(t, (&BaseConfig{NumWorkers: 4, MaxBatchSize: 1000, FlushInterval: DefaultFlushInterval}).Validate())
+	require.Error(t, (&BaseConfig{NumWorkers: -1}).Validate())
+	require.Error(t, (&BaseConfig{FlushInterval: -time.Second}).Validate())
+	require.Error(t, (&BaseConfig{FlushInterval: time.Microsecond}).Validate())
+}
@@ -13,6 +13,7 @@ import (
 	"runtime"
 	"sort"
 	"sync"
+	"sync/atomic"
 
 	"github.com/cespare/xxhash/v2"
 	"go.opentelemetry.io/collector/component"
@@ -56,25 +57,42 @@ type Converter struct {
 	set component.TelemetrySettings
 
 	// pLogsChan is a channel on which aggregated logs will be sent to.
-	pLogsChan chan plog.Logs
+	pLogsChan chan ConvertedLogs
 
 	stopOnce sync.Once
 	stopChan chan struct{}
 
 	// workerChan is an internal communication channel that gets the log
 	// entries from Batch() calls and it receives the data in workerLoop().
-	workerChan chan []*entry.Entry
+	workerChan chan entryBatch
 	// workerCount configures the amount of workers started.
 	workerCount int
 
 	// flushChan is an internal channel used for transporting batched plog.Logs.
-	flushChan chan plog.Logs
+	flushChan chan ConvertedLogs
+
+	// pending counts the batches not sent on pLogsChan yet, flushed is notified each time one is.
+	pending atomic.Int64
+	flushed chan struct{}
 
 	// wg is a WaitGroup that makes sure that we wait for spun up goroutines exit
 	// when Stop() is called.
 	wg sync.WaitGroup
 }
 
+// entryBatch is the entries of a Batch() call with the keys identifying them.
+type entryBatch struct {
+	entries []*entry.Entry
+	keys    []uint64
+}
+
+// ConvertedLogs are the logs converted from the entries of a Batch() call.
+type ConvertedLogs struct {
+	plog.Logs
+	// Keys are the keys passed to Batch() with the entries, such as their dedup keys.
+	Keys []uint64
+}
+
 type converterOption interface {
 	apply(*Converter)
 }
@@ -95,11 +113,12 @@ func NewConverter(set component.TelemetrySettings, opts ...converterOption) *Con
 	set.Logger = set.Logger.With(zap.String("component", "converter"))
 	c := &Converter{
 		set:         set,
-		workerChan:  make(chan []*entry.Entry),
+		workerChan:  make(chan entryBatch),
 		workerCount: int(math.Max(1, float64(runtime.NumCPU()/4))),
-		pLogsChan:   make(chan plog.Logs),
+		pLogsChan:   make(chan ConvertedLogs),
 		stopChan:    make(chan struct{}),
-		flushChan:   make(chan plog.Logs),
+		flushChan:   make(chan ConvertedLogs),
+		flushed:     make(chan struct{}, 1),
 	}
 	for _, opt := range opts {
 		opt.apply(c)
@@ -128,7 +147,7 @@ func (c *Converter) Stop() {
 }
 
 // OutChannel returns the channel on which converted entries will be sent to.
-func (c *Converter) OutChannel() <-chan plog.Logs {
+func (c *Converter) OutChannel() <-chan ConvertedLogs {
 	return c.pLogsChan
 }
 
@@ -144,7 +163,7 @@ func (c *Converter) workerLoop() {
 		case <-c.stopChan:
 			return
 
-		case entries, ok := <-c.workerChan:
+		case batch, ok := <-c.workerChan:
 			if !ok {
 				return
 			}
@@ -155,7 +174,7 @@ func (c *Converter) workerLoop() {
 			pLogs := plog.NewLogs()
 			var sl plog.ScopeLogs
 
-			for _, e := range entries {
+			for _, e := range batch.entries {
 				resourceID := HashResource(e.Resource)
 				var rl plog.ResourceLogs
 
@@ -185,7 +204,7 @@ func (c *Converter) workerLoop() {
 
 			// Send plogs directly to flushChan
 			select {
-			case c.flushChan <- pLogs:
+			case c.flushChan <- ConvertedLogs{Logs: pLogs, Keys: batch.keys}:
 			case <-c.stopChan:
 			}
 		}
@@ -208,12 +227,17 @@ func (c *Converter) flushLoop() {
 					zap.Error(err),
 				)
 			}
+			c.pending.Add(-1)
+			select {
+			case c.flushed <- struct{}{}:
+			default:
+			}
 		}
 	}
 }
 
 // flush flushes provided plog.Logs entries onto a channel.
-func (c *Converter) flush(ctx context.Context, pLogs plog.Logs) error {
+func (c *Converter) flush(ctx context.Context, pLogs ConvertedLogs) error {
 	doneChan := ctx.Done()
 
 	select {
@@ -230,16 +254,33 @@ func (c *Converter) flush(ctx context.Context, pLogs plog.Logs) error {
 	return nil
 }
 
-// Batch takes in an entry.Entry and sends it to an available worker for processing.
-func (c *Converter) Batch(e []*entry.Entry) error {
+// Batch takes in an entry.Entry and sends it to an available worker for processing. The keys are
+// handed back with the converted logs.
+func (c *Converter) Batch(e []*entry.Entry, keys []uint64) error {
+	c.pending.Add(1)
 	select {
-	case c.workerChan <- e:
+	case c.workerChan <- entryBatch{entries: e, keys: keys}:
 		return nil
 	case <-c.stopChan:
+		c.pending.Add(-1)
 		return errors.New("logs converter has been stopped")
 	}
 }
 
+// Drain waits until the batches received so far are sent on OutChannel, or ctx is done.
+func (c *Converter) Drain(ctx context.Context) error {
+	for c.pending.Load() > 0 {
+		select {
+		case <-c.flushed:
+		case <-ctx.Done():
+			return fmt.Errorf("draining log entries interrupted, err: %w", ctx.Err())
+		case <-c.stopChan:
+			return errors.New("logs converter has been stopped")
+		}
+	}
+	return nil
+}
+
 // convert converts one entry.Entry into plog.LogRecord allocating it.
 func convert(ent *entry.Entry) plog.LogRecord {
 	dest := plog.NewLogRecord()
@@ -0,0 +1,226 @@
+package adapter // import "github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/adapter"
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"sync"
+
+	"github.com/cespare/xxhash/v2"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/entry"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/operator"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/noop"
+	"go.uber.org/zap"
+)
+
+const (
+	// dedupHeadKey stores the next slot of the window.
+	dedupHeadKey = "dedup_head"
+	// dedupChunkKeyPrefix prefixes the keys of the window chunks.
+	dedupChunkKeyPrefix = "dedup_chunk_"
+	// dedupChunkSize is the number of keys persisted together, so a batch only rewrites the chunks it changed.
+	dedupChunkSize = 1024
+)
+
+// deduplicator drops entries whose key was seen among the last keys of its window. Keys are the
+// xxhash of the key field or of the hash fields. The emitter loop filters the entries, the consumer
+// loop remembers their keys once they are delivered.
+type deduplicator struct {
+	keyField   entry.Field
+	hashFields []entry.Field
+	logger     *zap.Logger
+
+	mu sync.Mutex
+	// window is a ring of the last keys delivered, head is the next slot to overwrite.
+	window []uint64
+	head   int
+	seen   map[uint64]int
+	// pending holds the keys of the entries kept but not delivered yet.
+	pending map[uint64]bool
+
+	persister operator.Persister
+	dirty     map[int]bool
+
+	attrs   attribute.Set
+	dropped metric.Int64Counter
+}
+
+// newDeduplicator returns the deduplicator for cfg, nil if it is disabled.
+func newDeduplicator(cfg DedupConfig, id component.ID, set component.TelemetrySettings) (*deduplicator, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+	windowSize := cfg.WindowSize
+	if windowSize == 0 {
+		windowSize = DefaultDedupWindowSize
+	}
+	hashFields := cfg.HashFields
+	if cfg.KeyField.FieldInterface == nil && len(hashFields) == 0 {
+		// Without a key, the whole body is hashed, which also matches the unparsed lines.
+		hashFields = []entry.Field{entry.NewBodyField()}
+	}
+	meterProvider := set.MeterProvider
+	if meterProvider == nil {
+		meterProvider = noop.NewMeterProvider()
+	}
+	dropped, err := meterProvider.Meter(scopeName).Int64Counter("otelnetstats_dedup_dropped",
+		metric.WithDescription("Number of duplicate entries dropped."),
+		metric.WithUnit("{entries}"))
+	if err != nil {
+		return nil, err
+	}
+	return &deduplicator{
+		keyField:   cfg.KeyField,
+		hashFields: hashFields,
+		logger:     set.Logger.With(zap.String("component", "dedup")),
+		window:     make([]uint64, 0, windowSize),
+		seen:       make(map[uint64]int, windowSize),
+		pending:    map[uint64]bool{},
+		dirty:      map[int]bool{},
+		attrs:      attribute.NewSet(attribute.String("receiver", id.String())),
+		dropped:    dropped,
+	}, nil
+}
+
+// load restores the window persisted by a previous run.
+func (d *deduplicator) load(ctx context.Context, persister operator.Persister) error {
+	d.persister = persister
+	head, err := persister.Get(ctx, dedupHeadKey)
+	if err != nil || head == nil {
+		return err
+	}
+	n, err := strconv.Atoi(string(head))
+	if err != nil {
+		return fmt.Errorf("dedup head: %w", err)
+	}
+
+	var keys []uint64
+	for chunk := 0; chunk*dedupChunkSize < cap(d.window); chunk++ {
+		data, err := persister.Get(ctx, dedupChunkKeyPrefix+strconv.Itoa(chunk))
+		if err != nil {
+			return err
+		}
+		if len(data) == 0 {
+			break
+		}
+		for i := 0; i+8 <= len(data); i += 8 {
+			keys = append(keys, binary.BigEndian.Uint64(data[i:]))
+		}
+	}
+	if len(keys) > cap(d.window) {
+		keys = keys[:cap(d.window)]
+	}
+	d.window = append(d.window, keys...)
+	for i, key := range d.window {
+		d.seen[key] = i
+	}
+	if n <= len(d.window) && n < cap(d.window) {
+		d.head = n
+	}
+	return nil
+}
+
+// filter returns the entries whose key was neither seen nor is pending, and the keys of the kept
+// entries, which stay pending until done is called with them.
+func (d *deduplicator) filter(ctx context.Context, entries []*entry.Entry) ([]*entry.Entry, []uint64) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	kept := entries[:0]
+	var keys []uint64
+	for _, e := range entries {
+		key, ok := d.key(e)
+		if !ok {
+			kept = append(kept, e)
+			continue
+		}
+		if _, seen := d.seen[key]; seen || d.pending[key] {
+			d.dropped.Add(ctx, 1, metric.WithAttributeSet(d.attrs))
+			continue
+		}
+		d.pending[key] = true
+		keys = append(keys, key)
+		kept = append(kept, e)
+	}
+	return kept, keys
+}
+
+// done ends the pending keys, remembering and persisting them if their entries were delivered, so
+// that entries which were not can be received again.
+func (d *deduplicator) done(ctx context.Context, keys []uint64, delivered bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, key := range keys {
+		if !d.pending[key] {
+			continue
+		}
+		delete(d.pending, key)
+		if delivered {
+			d.remember(key)
+		}
+	}
+	d.persist(ctx)
+}
+
+// key hashes the hash fields of e if any are configured, its key field otherwise.
+func (d *deduplicator) key(e *entry.Entry) (uint64, bool) {
+	digest := xxhash.New()
+	if len(d.hashFields) == 0 {
+		value, ok := e.Get(d.keyField)
+		if !ok {
+			return 0, false
+		}
+		fmt.Fprint(digest, value)
+		return digest.Sum64(), true
+	}
+	found := false
+	for _, field := range d.hashFields {
+		value, ok := e.Get(field)
+		found = found || ok
+		// fmt sorts map keys, so equal maps hash the same.
+		fmt.Fprintf(digest, "%s=%v\x00", field.String(), value)
+	}
+	return digest.Sum64(), found
+}
+
+// remember adds key to the window, evicting the oldest key once full.
+func (d *deduplicator) remember(key uint64) {
+	if len(d.window) < cap(d.window) {
+		d.window = append(d.window, key)
+	} else {
+		old := d.window[d.head]
+		if d.seen[old] == d.head {
+			delete(d.seen, old)
+		}
+		d.window[d.head] = key
+	}
+	d.seen[key] = d.head
+	d.dirty[d.head/dedupChunkSize] = true
+	d.head = (d.head + 1) % cap(d.window)
+}
+
+// persist writes the chunks of the window changed since the last call.
+func (d *deduplicator) persist(ctx context.Context) {
+	if d.persister == nil || len(d.dirty) == 0 {
+		return
+	}
+	for chunk := range d.dirty {
+		start := chunk * dedupChunkSize
+		end := min(start+dedupChunkSize, len(d.window))
+		data := make([]byte, 0, (end-start)*8)
+		for _, key := range d.window[start:end] {
+			data = binary.BigEndian.AppendUint64(data, key)
+		}
+		if err := d.persister.Set(ctx, dedupChunkKeyPrefix+strconv.Itoa(chunk), data); err != nil {
+			d.logger.Error("Failed to persist the dedup window", zap.Error(err))
+			return
+		}
+		delete(d.dirty, chunk)
+	}
+	if err := d.persister.Set(ctx, dedupHeadKey, []byte(strconv.Itoa(d.head))); err != nil {
+		d.logger.Error("Failed to persist the dedup window", zap.Error(err))
+	}
+}
@@ -0,0 +1,116 @@
+package adapter
+
+import (
+	"context"
+	"testing"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/entry"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/operator"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/testutil"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componenttest"
+)
+
+func TestDeduplicator(t *testing.T) {
+	ctx := context.Background()
+	persister := testutil.NewUnscopedMockPersister()
+	cfg := DedupConfig{Enabled: true, KeyField: entry.NewBodyField("id"), WindowSize: 2}
+	dedup := newTestDeduplicator(t, cfg, persister)
+
+	kept := deliver(ctx, dedup, []*entry.Entry{idEntry("a"), idEntry("b"), idEntry("a"), entry.New()})
+	require.Equal(t, []any{"a", "b", nil}, ids(kept), "repeats are dropped, entries without id are kept")
+
+	// The window survives a restart.
+	dedup = newTestDeduplicator(t, cfg, persister)
+	require.Empty(t, deliver(ctx, dedup, []*entry.Entry{idEntry("a"), idEntry("b")}))
+
+	// Keys are evicted once the window is full, when the new keys are delivered.
+	require.Equal(t, []any{"c"}, ids(deliver(ctx, dedup, []*entry.Entry{idEntry("c"), idEntry("a")})))
+	require.Equal(t, []any{"a"}, ids(deliver(ctx, dedup, []*entry.Entry{idEntry("b"), idEntry("a")})))
+	require.Equal(t, []any{"b"}, ids(deliver(ctx, dedup, []*entry.Entry{idEntry("b"), idEntry("c")})))
+}
+
+func TestDeduplicatorRemembersDeliveredKeys(t *testing.T) {
+	ctx := context.Background()
+	persister := testutil.NewUnscopedMockPersister()
+	cfg := DedupConfig{Enabled: true, KeyField: entry.NewBodyField("id"), WindowSize: 10}
+	dedup := newTestDeduplicator(t, cfg, persister)
+
+	kept, keys := dedup.filter(ctx, []*entry.Entry{idEntry("a")})
+	require.Equal(t, []any{"a"}, ids(kept))
+	pending, _ := dedup.filter(ctx, []*entry.Entry{idEntry("a")})
+	require.Empty(t, pending, "a pending key is a duplicate")
+
+	// The keys of entries that were not delivered are neither remembered nor persisted.
+	dedup.done(ctx, keys, false)
+	require.Equal(t, []any{"a"}, ids(deliver(ctx, newTestDeduplicator(t, cfg, persister), []*entry.Entry{idEntry("a")})))
+	kept, keys = dedup.filter(ctx, []*entry.Entry{idEntry("a")})
+	require.Equal(t, []any{"a"}, ids(kept))
+
+	// The converter hands the keys back to the consumer loop with the converted logs.
+	converter := NewConverter(componenttest.NewNopTelemetrySettings())
+	converter.Start()
+	defer converter.Stop()
+	require.NoError(t, converter.Batch(kept, keys))
+	converted := <-converter.OutChannel()
+	require.Equal(t, 1, converted.LogRecordCount())
+	require.Equal(t, keys, converted.Keys)
+	dedup.done(ctx, converted.Keys, true)
+	require.Empty(t, deliver(ctx, newTestDeduplicator(t, cfg, persister), []*entry.Entry{idEntry("a")}))
+}
+
+func TestDeduplicatorHashesBodyByDefault(t *testing.T) {
+	dedup := newTestDeduplicator(t, DedupConfig{Enabled: true}, testutil.NewUnscopedMockPersister())
+
+	line := func(body string) *entry.Entry {
+		e := entry.New()
+		e.Body = body
+		return e
+	}
+	kept := deliver(context.Background(), dedup, []*entry.Entry{line(`{"id":"a"}`), line(`{"id":"b"}`), line(`{"id":"a"}`)})
+	require.Len(t, kept, 2, "string bodies are deduplicated without a json_parser")
+}
+
+func TestDeduplicatorHashFields(t *testing.T) {
+	cfg := DedupConfig{Enabled: true, HashFields: []entry.Field{entry.NewBodyField(), entry.NewAttributeField("host")}}
+	dedup := newTestDeduplicator(t, cfg, testutil.NewUnscopedMockPersister())
+
+	record := func(body, host string) *entry.Entry {
+		e := entry.New()
+		e.Body = body
+		e.Attributes = map[string]any{"host": host}
+		return e
+	}
+	kept := deliver(context.Background(), dedup, []*entry.Entry{record("usage", "a"), record("usage", "b"), record("usage", "a")})
+	require.Len(t, kept, 2)
+}
+
+// deliver filters entries and reports the kept ones as delivered.
+func deliver(ctx context.Context, dedup *deduplicator, entries []*entry.Entry) []*entry.Entry {
+	kept, keys := dedup.filter(ctx, entries)
+	dedup.done(ctx, keys, true)
+	return kept
+}
+
+func newTestDeduplicator(t *testing.T, cfg DedupConfig, persister operator.Persister) *deduplicator {
+	dedup, err := newDeduplicator(cfg, component.MustNewID("otelnetstatsreceiver"), componenttest.NewNopTelemetrySettings())
+	require.NoError(t, err)
+	require.NoError(t, dedup.load(context.Background(), persister))
+	return dedup
+}
+
+func idEntry(id string) *entry.Entry {
+	e := entry.New()
+	e.Body = map[string]any{"id": id}
+	return e
+}
+
+func ids(entries []*entry.Entry) []any {
+	var ids []any
+	for _, e := range entries {
+		id, _ := e.Get(entry.NewBodyField("id"))
+		ids = append(ids, id)
+	}
+	return ids
+}
@@ -4,9 +4,13 @@
 package adapter // import "github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/adapter"
 
 import (
+	"context"
+	"sync/atomic"
+
 	"go.opentelemetry.io/collector/component"
 	"go.uber.org/zap"
 
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/entry"
 	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/operator/helper"
 )
 
@@ -17,3 +21,66 @@ type LogEmitter = helper.LogEmitter
 func NewLogEmitter(logger *zap.SugaredLogger, opts ...helper.EmitterOption) *LogEmitter {
 	return helper.NewLogEmitter(component.TelemetrySettings{Logger: logger.Desugar()}, opts...)
 }
+
+// drainingEmitter is a helper.LogEmitter whose Stop waits for the batched entries to be handed off,
+// instead of discarding the last batch.
+type drainingEmitter struct {
+	*helper.LogEmitter
+
+	// drainCtx abandons the batched entries once done.
+	drainCtx atomic.Pointer[context.Context]
+	// pending counts the entries processed but not handed off yet.
+	pending atomic.Int64
+	// handedOff is notified every time entries are handed off.
+	handedOff chan struct{}
+}
+
+func newDrainingEmitter(set component.TelemetrySettings, opts ...helper.EmitterOption) *drainingEmitter {
+	e := &drainingEmitter{
+		LogEmitter: helper.NewLogEmitter(set, opts...),
+		handedOff:  make(chan struct{}, 1),
+	}
+	e.setDrainContext(context.Background())
+	return e
+}
+
+// setDrainContext sets the context abandoning the batched entries once done.
+func (e *drainingEmitter) setDrainContext(ctx context.Context) {
+	e.drainCtx.Store(&ctx)
+}
+
+// Stop waits for the processed entries to be handed off, the flusher sending the last batch, then
+// stops the emitter and closes the log channel.
+func (e *drainingEmitter) Stop() error {
+	e.waitHandedOff()
+	return e.LogEmitter.Stop()
+}
+
+// waitHandedOff waits for the processed entries to be handed off, or the drain context to be done.
+func (e *drainingEmitter) waitHandedOff() {
+	drainCtx := *e.drainCtx.Load()
+	for e.pending.Load() > 0 {
+		select {
+		case <-e.handedOff:
+		case <-drainCtx.Done():
+			e.Logger().Warn("Discarding batched entries", zap.Int64("entries", e.pending.Load()))
+			return
+		}
+	}
+}
+
+// Process adds an entry to the batch. A full batch is sent unless the drain context is done first, so
+// that stopping an input does not discard the batch it fills.
+func (e *drainingEmitter) Process(_ context.Context, ent *entry.Entry) error {
+	e.pending.Add(1)
+	return e.LogEmitter.Process(*e.drainCtx.Load(), ent)
+}
+
+// handOff records that n entries read from OutChannel were handed off.
+func (e *drainingEmitter) handOff(n int) {
+	e.pending.Add(int64(-n))
+	select {
+	case e.handedOff <- struct{}{}:
+	default:
+	}
+}
@@ -5,9 +5,11 @@ package adapter // import "github.com/open-telemetry/opentelemetry-collector-con
 
 import (
 	"context"
+	"fmt"
 	"github.com/fsgonz/otelnetstatsreceiver/internal/consumerretry"
 	"github.com/fsgonz/otelnetstatsreceiver/internal/file"
 	"github.com/fsgonz/otelnetstatsreceiver/internal/logsampler"
+	"github.com/fsgonz/otelnetstatsreceiver/internal/lumberjack"
 	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/operator"
 	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/operator/helper"
 	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/pipeline"
@@ -15,7 +17,6 @@ import (
 	"go.opentelemetry.io/collector/consumer"
 	rcvr "go.opentelemetry.io/collector/receiver"
 	"go.opentelemetry.io/collector/receiver/receiverhelper"
-	"time"
 )
 
 // LogReceiverType is the interface used by stanza-based log receivers
@@ -25,7 +26,6 @@ type LogReceiverType interface {
 	BaseConfig(component.Config) BaseConfig
 	InputConfig(component.Config) operator.Config
 	LogSamplers(component.Config) logsampler.Config
-	Input(cfg component.Config) file.Input
 }
 
 // NewFactory creates a factory for a Stanza-based receiver
@@ -47,32 +47,42 @@ func createLogsReceiver(logReceiverType LogReceiverType) rcvr.CreateLogsFunc {
 		inputCfg := logReceiverType.InputConfig(cfg)
 		baseCfg := logReceiverType.BaseConfig(cfg)
 		logSamplerCfg := logReceiverType.LogSamplers(cfg)
-		input := logReceiverType.Input(cfg)
 
-		operators := append([]operator.Config{inputCfg}, baseCfg.Operators...)
+		var logSampler *logsampler.LogSampler
+		samplerPollInterval := logsampler.DefaultPollInterval
 
-		emitterOpts := []helper.EmitterOption{}
-		if baseCfg.maxBatchSize > 0 {
-			emitterOpts = append(emitterOpts, helper.WithMaxBatchSize(baseCfg.maxBatchSize))
+		if len(logSamplerCfg.LogSamplers) != 0 {
+			logSampler = &logSamplerCfg.LogSamplers[0]
+			if logSampler.PollInterval > 0 {
+				samplerPollInterval = logSampler.PollInterval
+			}
 		}
 
-		if baseCfg.flushInterval > 0 {
-			emitterOpts = append(emitterOpts, helper.WithFlushInterval(baseCfg.flushInterval))
+		if logSampler != nil && logSampler.SelfIngest {
+			var err error
+			if inputCfg, err = selfIngestInputConfig(inputCfg, logSampler.URI); err != nil {
+				return nil, err
+			}
+			if baseCfg.StorageID == nil {
+				params.Logger.Warn("Self ingest is enabled without storage, sampler records may be read again after a restart")
+			}
+		}
+		if logSampler != nil && logSampler.Signing.KeyFile != "" && baseCfg.StorageID == nil {
+			params.Logger.Warn("Signing is enabled without storage, the chain of signed records starts again at seq 1 after a restart")
 		}
 
-		sampler := ""
-		samplerUri := ""
-		samplerOutput := ""
-		samplerPollInterval := time.Minute
+		operators := append([]operator.Config{inputCfg}, baseCfg.Operators...)
 
-		if len(logSamplerCfg.LogSamplers) != 0 {
-			sampler = logSamplerCfg.LogSamplers[0].Metric
-			samplerPollInterval = logSamplerCfg.LogSamplers[0].PollInterval
-			samplerUri = logSamplerCfg.LogSamplers[0].URI
-			samplerOutput = logSamplerCfg.LogSamplers[0].Output
+		emitterOpts := []helper.EmitterOption{}
+		if baseCfg.MaxBatchSize > 0 {
+			emitterOpts = append(emitterOpts, helper.WithMaxBatchSize(baseCfg.MaxBatchSize))
+		}
+
+		if baseCfg.FlushInterval > 0 {
+			emitterOpts = append(emitterOpts, helper.WithFlushInterval(baseCfg.FlushInterval))
 		}
 
-		emitter := helper.NewLogEmitter(params.TelemetrySettings, emitterOpts...)
+		emitter := newDrainingEmitter(params.TelemetrySettings, emitterOpts...)
 		pipe, err := pipeline.Config{
 			Operators:     operators,
 			DefaultOutput: emitter,
@@ -81,9 +91,19 @@ func createLogsReceiver(logReceiverType LogReceiverType) rcvr.CreateLogsFunc {
 			return nil, err
 		}
 
+		// Replayed records go through the file input operators.
+		replayInput, err := findInput(pipe, inputCfg.ID())
+		if err != nil {
+			return nil, err
+		}
+		samplerPipe, samplerInput, alertInput, err := samplerInputs(params.TelemetrySettings, logSampler, replayInput, emitter)
+		if err != nil {
+			return nil, err
+		}
+
 		converterOpts := []converterOption{}
-		if baseCfg.numWorkers > 0 {
-			converterOpts = append(converterOpts, withWorkerCount(baseCfg.numWorkers))
+		if baseCfg.NumWorkers > 0 {
+			converterOpts = append(converterOpts, withWorkerCount(baseCfg.NumWorkers))
 		}
 		converter := NewConverter(params.TelemetrySettings, converterOpts...)
 		obsrecv, err := receiverhelper.NewObsReport(receiverhelper.ObsReportSettings{
@@ -94,20 +114,106 @@ func createLogsReceiver(logReceiverType LogReceiverType) rcvr.CreateLogsFunc {
 			return nil, err
 		}
 
+		budget, err := newInflightBudget(baseCfg.InflightLimit, params.ID, params.TelemetrySettings)
+		if err != nil {
+			return nil, err
+		}
+
+		dedup, err := newDeduplicator(baseCfg.Dedup, params.ID, params.TelemetrySettings)
+		if err != nil {
+			return nil, err
+		}
+
+		retryConsumer, err := consumerretry.NewLogs(baseCfg.RetryOnFailure, params.ID, params.TelemetrySettings, nextConsumer)
+		if err != nil {
+			return nil, err
+		}
+
+		var samplerTelemetry *SamplerTelemetry
+		if logSampler != nil {
+			samplerTelemetry, err = NewSamplerTelemetry(params.TelemetrySettings, params.ID, logSampler.Metric)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		var tailerInclude, tailerExclude []string
+		if fileCfg, ok := inputCfg.Builder.(*file.FileInputConfig); ok {
+			tailerInclude, tailerExclude = fileCfg.Include, fileCfg.Exclude
+		}
+
 		return &receiver{
 			set:                 params.TelemetrySettings,
 			id:                  params.ID,
 			pipe:                pipe,
 			emitter:             emitter,
-			consumer:            consumerretry.NewLogs(baseCfg.RetryOnFailure, params.Logger, nextConsumer),
+			consumer:            retryConsumer,
 			converter:           converter,
 			obsrecv:             obsrecv,
 			storageID:           baseCfg.StorageID,
-			sampler:             sampler,
+			persistentQueue:     baseCfg.RetryOnFailure.Enabled && baseCfg.RetryOnFailure.PersistentQueue.Enabled,
+			logSampler:          logSampler,
 			samplerPollInterval: samplerPollInterval,
-			samplerOutput:       samplerOutput,
-			samplerURI:          samplerUri,
-			input:               input,
+			samplerPipe:         samplerPipe,
+			budget:              budget,
+			replay:              baseCfg.Replay,
+			replayInput:         replayInput,
+			dedup:               dedup,
+			status:              newStatusReporter(params.TelemetrySettings),
+			tailerInclude:       tailerInclude,
+			tailerExclude:       tailerExclude,
+			samplerTelemetry:    samplerTelemetry,
+			samplerInput:        samplerInput,
+			alertInput:          alertInput,
 		}, nil
 	}
 }
+
+// samplerInputs returns the pipeline of the sampler, nil if it needs none, and the inputs its
+// records and alerts are written into. Records go through the sampler operators if set, otherwise
+// through the file input operators. Alerts never go through the file input operators, which
+// expect the lines of the tailed files.
+func samplerInputs(set component.TelemetrySettings, logSampler *logsampler.LogSampler, fileInput SamplerInput, emitter operator.Operator) (pipeline.Pipeline, SamplerInput, SamplerInput, error) {
+	if logSampler == nil || (len(logSampler.Operators) == 0 && len(logSampler.Rules) == 0) {
+		return nil, fileInput, fileInput, nil
+	}
+
+	samplerPipe, err := buildSamplerPipeline(set, logSampler.Operators, emitter)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("sampler operators: %w", err)
+	}
+	input, err := findInput(samplerPipe, samplerInputType)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if len(logSampler.Operators) == 0 {
+		return samplerPipe, fileInput, input, nil
+	}
+	return samplerPipe, input, input, nil
+}
+
+// selfIngestInputConfig returns a copy of the file input configuration that also tails
+// the file written by a file_logger sampler and its rotated backups.
+func selfIngestInputConfig(inputCfg operator.Config, uri string) (operator.Config, error) {
+	fileCfg, ok := inputCfg.Builder.(*file.FileInputConfig)
+	if !ok {
+		return operator.Config{}, fmt.Errorf("self ingest is not supported by input operator '%s'", inputCfg.Type())
+	}
+
+	// The plain backups are tailed so that the records written before a rotation are still read. The
+	// compressed and encrypted backups do not match, their records having been read before.
+	selfIngestCfg := *fileCfg
+	selfIngestCfg.Include = append(append([]string{}, fileCfg.Include...), uri, lumberjack.BackupPattern(uri))
+	return operator.NewConfig(&selfIngestCfg), nil
+}
+
+// findInput returns the input operator with the given ID from the built pipeline,
+// so that samplers can write into its operator chain.
+func findInput(pipe pipeline.Pipeline, inputID string) (SamplerInput, error) {
+	for _, op := range pipe.Operators() {
+		if input, ok := op.(SamplerInput); ok && op.ID() == inputID {
+			return input, nil
+		}
+	}
+	return nil, fmt.Errorf("input operator '%s' not found in pipeline", inputID)
+}
@@ -0,0 +1,55 @@
+package adapter
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/fsgonz/otelnetstatsreceiver/internal/file"
+	"github.com/fsgonz/otelnetstatsreceiver/internal/lumberjack"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/fileconsumer/matcher"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/operator"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/operator/helper"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSelfIngestInputConfig(t *testing.T) {
+	dir := t.TempDir()
+	uri := filepath.Join(dir, "usage.log")
+
+	fileCfg := file.NewFileInputConfig()
+	fileCfg.Include = []string{filepath.Join(dir, "app*.log")}
+	fileCfg.Exclude = []string{filepath.Join(dir, "app-debug.log")}
+	inputCfg, err := selfIngestInputConfig(operator.NewConfig(fileCfg), uri)
+	require.NoError(t, err)
+
+	selfIngestCfg := inputCfg.Builder.(*file.FileInputConfig)
+	require.Equal(t, []string{filepath.Join(dir, "app*.log"), uri, filepath.Join(dir, "usage-*.log")}, selfIngestCfg.Include)
+	require.Equal(t, fileCfg.Exclude, selfIngestCfg.Exclude)
+	require.Len(t, fileCfg.Include, 1, "the file input configuration is not modified")
+
+	// The output file and its plain backup are tailed, so the records written before a rotation are read.
+	// The compressed and encrypted backups hold the same records again and are not tailed.
+	writer := &lumberjack.Logger{Filename: uri}
+	_, err = writer.Write([]byte("record\n"))
+	require.NoError(t, err)
+	require.NoError(t, writer.Rotate())
+	require.NoError(t, writer.Close())
+	backups, err := filepath.Glob(filepath.Join(dir, "usage-*"))
+	require.NoError(t, err)
+	require.Len(t, backups, 1)
+	for _, name := range []string{"app.log", "app-debug.log", filepath.Base(backups[0]) + ".gz", filepath.Base(backups[0]) + ".enc"} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte("record\n"), 0600))
+	}
+
+	m, err := matcher.New(selfIngestCfg.Criteria)
+	require.NoError(t, err)
+	matched, err := m.MatchFiles()
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{filepath.Join(dir, "app.log"), uri, backups[0]}, matched)
+
+	_, err = selfIngestInputConfig(operator.NewConfig(&samplerInputConfig{
+		InputConfig: helper.NewInputConfig(samplerInputType, samplerInputType),
+	}), uri)
+	require.Error(t, err)
+}
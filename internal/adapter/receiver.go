@@ -5,45 +5,79 @@ package adapter // import "github.com/open-telemetry/opentelemetry-collector-con
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"github.com/fsgonz/otelnetstatsreceiver/internal/file"
+	"github.com/fsgonz/otelnetstatsreceiver/internal/consumerretry"
+	"github.com/fsgonz/otelnetstatsreceiver/internal/logsampler"
 	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/operator"
+	"io"
 	"sync"
 	"time"
 
 	"go.opentelemetry.io/collector/component"
-	"go.opentelemetry.io/collector/consumer"
 	"go.opentelemetry.io/collector/extension/experimental/storage"
 	rcvr "go.opentelemetry.io/collector/receiver"
 	"go.opentelemetry.io/collector/receiver/receiverhelper"
 	"go.uber.org/multierr"
 	"go.uber.org/zap"
 
-	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/operator/helper"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/entry"
 	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/pipeline"
 )
 
 type receiver struct {
 	set                 component.TelemetrySettings
 	samplerPollInterval time.Duration
-	sampler             string
-	samplerOutput       string
-	samplerURI          string
+	logSampler          *logsampler.LogSampler
 	id                  component.ID
 	wg                  sync.WaitGroup
 	cancel              context.CancelFunc
 
 	pipe      pipeline.Pipeline
-	emitter   *helper.LogEmitter
-	consumer  consumer.Logs
+	emitter   *drainingEmitter
+	consumer  consumerretry.Logs
 	converter *Converter
 	obsrecv   *receiverhelper.ObsReport
 
-	storageID     *component.ID
-	storageClient storage.Client
-	input         file.Input
+	storageID       *component.ID
+	storageClient   storage.Client
+	persistentQueue bool
+
+	// samplerPipe is the pipeline of the sampler operators and alerts, nil if the sampler has neither.
+	samplerPipe  pipeline.Pipeline
+	samplerInput SamplerInput
+	alertInput   SamplerInput
+
+	// budget bounds the entries in flight, nil if there is no inflight limit.
+	budget *inflightBudget
+
+	// replay is the configuration of the OTLP files written into replayInput.
+	replay      ReplayConfig
+	replayInput SamplerInput
+
+	// dedup drops duplicate entries, nil if disabled.
+	dedup *deduplicator
+
+	// inputWg waits for the sampler, replay and tailer status goroutines, which stop before the pipelines.
+	inputWg      sync.WaitGroup
+	inputStop    chan struct{}
+	replayCancel context.CancelFunc
+	// consumerStop ends the consumer loop once the converter is drained.
+	consumerStop chan struct{}
+
+	// samplerTelemetry records the sampler metrics, nil without sampler.
+	samplerTelemetry *SamplerTelemetry
+
+	// status reports the status of the tailer, the sampler and the consumer.
+	status *statusReporter
+	// tailerInclude and tailerExclude are the patterns of the files tailed by the file input.
+	tailerInclude []string
+	tailerExclude []string
 }
 
+// tailerStatusInterval is how often the tailer status is checked.
+const tailerStatusInterval = time.Minute
+
 // Ensure this receiver adheres to required interface
 var _ rcvr.Logs = (*receiver)(nil)
 
@@ -51,16 +85,39 @@ var _ rcvr.Logs = (*receiver)(nil)
 func (r *receiver) Start(ctx context.Context, host component.Host) error {
 	rctx, cancel := context.WithCancel(ctx)
 	r.cancel = cancel
+	r.inputStop = make(chan struct{})
+	r.consumerStop = make(chan struct{})
 	r.set.Logger.Info("Starting stanza receiver")
 
 	if err := r.setStorageClient(ctx, host); err != nil {
 		return fmt.Errorf("storage client: %w", err)
 	}
 
+	if err := r.startConsumer(ctx, host); err != nil {
+		return fmt.Errorf("retry consumer: %w", err)
+	}
+
+	if r.dedup != nil {
+		if err := r.dedup.load(ctx, r.storageClient); err != nil {
+			return fmt.Errorf("dedup window: %w", err)
+		}
+	}
+
+	// The emitter abandons the entries it batches once the receiver is canceled.
+	r.emitter.setDrainContext(rctx)
 	if err := r.pipe.Start(r.storageClient); err != nil {
 		return fmt.Errorf("start stanza: %w", err)
 	}
 
+	if r.samplerPipe != nil {
+		if err := r.samplerPipe.Start(r.storageClient); err != nil {
+			// The file input is stopped, so that it does not read files that nothing consumes. The
+			// emitter stops without waiting for its entries to be handed off.
+			r.cancel()
+			return multierr.Append(fmt.Errorf("start sampler stanza: %w", err), r.pipe.Stop())
+		}
+	}
+
 	r.converter.Start()
 
 	// Below we're starting 2 loops:
@@ -83,15 +140,31 @@ func (r *receiver) Start(ctx context.Context, host component.Host) error {
 	// channel. In order to prevent backpressure, reading from the converter
 	// channel and batching are done in those 2 goroutines.
 
-	if r.sampler != "" {
+	if r.logSampler != nil {
+		r.inputWg.Add(1)
 		go r.samplerLoop(rctx, r.storageClient)
 	}
 
+	if len(r.tailerInclude) > 0 {
+		r.inputWg.Add(1)
+		go r.tailerStatusLoop(rctx)
+	}
+
+	if len(r.replay.Include) > 0 {
+		replayCtx, replayCancel := context.WithCancel(rctx)
+		r.replayCancel = replayCancel
+		r.inputWg.Add(1)
+		go func() {
+			defer r.inputWg.Done()
+			newReplayer(r.replay, r.set, r.replayInput, r.storageClient).run(replayCtx)
+		}()
+	}
+
 	return nil
 }
 
 // emitterLoop reads the log entries produced by the emitter and batches them
-// in converter.
+// in converter. It returns once the emitter is stopped.
 func (r *receiver) emitterLoop(ctx context.Context) {
 	defer r.wg.Done()
 
@@ -105,16 +178,48 @@ func (r *receiver) emitterLoop(ctx context.Context) {
 
 		case e, ok := <-r.emitter.OutChannel():
 			if !ok {
-				continue
+				r.set.Logger.Debug("Emitter channel got closed")
+				return
 			}
 
-			if err := r.converter.Batch(e); err != nil {
-				r.set.Logger.Error("Could not add entry to batch", zap.Error(err))
+			if !r.batch(ctx, e) {
+				return
 			}
 		}
 	}
 }
 
+// batch sends entries to the converter, returning false if ctx got done while throttled.
+func (r *receiver) batch(ctx context.Context, entries []*entry.Entry) bool {
+	// The emitter waits for the entries to be handed to the converter when stopping.
+	defer r.emitter.handOff(len(entries))
+
+	var keys []uint64
+	if r.dedup != nil {
+		if entries, keys = r.dedup.filter(ctx, entries); len(entries) == 0 {
+			return true
+		}
+	}
+
+	if r.budget != nil {
+		if err := r.budget.acquire(ctx, entries); err != nil {
+			r.set.Logger.Debug("Receive loop stopped while throttled")
+			return false
+		}
+	}
+
+	if err := r.converter.Batch(entries, keys); err != nil {
+		r.set.Logger.Error("Could not add entry to batch", zap.Error(err))
+		if r.budget != nil {
+			r.budget.release(len(entries))
+		}
+		if r.dedup != nil {
+			r.dedup.done(ctx, keys, false)
+		}
+	}
+	return true
+}
+
 // consumerLoop reads converter log entries and calls the consumer to consumer them.
 func (r *receiver) consumerLoop(ctx context.Context) {
 	defer r.wg.Done()
@@ -128,6 +233,10 @@ func (r *receiver) consumerLoop(ctx context.Context) {
 			r.set.Logger.Debug("Consumer loop stopped")
 			return
 
+		case <-r.consumerStop:
+			r.set.Logger.Debug("Consumer loop drained")
+			return
+
 		case pLogs, ok := <-pLogsChan:
 			if !ok {
 				r.set.Logger.Debug("Converter channel got closed")
@@ -135,41 +244,82 @@ func (r *receiver) consumerLoop(ctx context.Context) {
 			}
 			obsrecvCtx := r.obsrecv.StartLogsOp(ctx)
 			logRecordCount := pLogs.LogRecordCount()
-			cErr := r.consumer.ConsumeLogs(ctx, pLogs)
+			cErr := r.consumer.ConsumeLogs(ctx, pLogs.Logs)
 			if cErr != nil {
 				r.set.Logger.Error("ConsumeLogs() failed", zap.Error(cErr))
+				r.status.recoverable(consumerStatusSource, cErr)
+			} else {
+				r.status.ok(consumerStatusSource)
 			}
 			r.obsrecv.EndLogsOp(obsrecvCtx, "stanza", logRecordCount, cErr)
+			if r.budget != nil {
+				r.budget.release(logRecordCount)
+			}
+			if r.dedup != nil {
+				r.dedup.done(ctx, pLogs.Keys, cErr == nil)
+			}
 		}
 	}
 }
 
-// Shutdown is invoked during service shutdown
+// Shutdown is invoked during service shutdown. It takes a final sample and delivers the entries
+// read so far to the consumer, unless ctx is done first, before closing the storage.
 func (r *receiver) Shutdown(ctx context.Context) error {
 	if r.cancel == nil {
 		return nil
 	}
 
 	r.set.Logger.Info("Stopping stanza receiver")
-	pipelineErr := r.pipe.Stop()
+	// Draining is abandoned once the shutdown deadline is reached.
+	stopAbandon := context.AfterFunc(ctx, r.cancel)
+	defer stopAbandon()
+
+	if r.replayCancel != nil {
+		r.replayCancel()
+	}
+	close(r.inputStop)
+	r.inputWg.Wait()
+
+	// The inputs stop first, then the emitter waits for its batch to be handed to the converter
+	// and closes its channel, which ends the emitter loop.
+	var pipelineErr error
+	if r.samplerPipe != nil {
+		pipelineErr = r.samplerPipe.Stop()
+	}
+	pipelineErr = multierr.Append(pipelineErr, r.pipe.Stop())
+
+	if err := r.converter.Drain(ctx); err != nil {
+		r.set.Logger.Warn("Discarding log entries not converted", zap.Error(err))
+	}
+	// The consumer loop delivers the last entries, retrying if needed, before the receiver is canceled.
+	close(r.consumerStop)
+	r.wg.Wait()
 	r.converter.Stop()
 	r.cancel()
-	r.wg.Wait()
 
+	consumerErr := r.consumer.Shutdown(ctx)
+	telemetryErr := multierr.Append(r.samplerTelemetry.shutdown(), r.budget.shutdown())
 	if r.storageClient != nil {
 		clientErr := r.storageClient.Close(ctx)
-		return multierr.Combine(pipelineErr, clientErr)
+		return multierr.Combine(pipelineErr, consumerErr, telemetryErr, clientErr)
 	}
-	return pipelineErr
+	return multierr.Combine(pipelineErr, consumerErr, telemetryErr)
 }
 
 func (r *receiver) samplerLoop(ctx context.Context, persister operator.Persister) {
-	samplerEmitter, err := SamplerEmitterFactory(r.samplerOutput, r.samplerURI, persister, r.emitter, r.input)
+	defer r.inputWg.Done()
+
+	samplerEmitter, err := SamplerEmitterFactory(*r.logSampler, persister, r.emitter, r.samplerInput, r.alertInput, r.samplerTelemetry, r.set.Logger)
 
 	if err != nil {
-		r.set.Logger.Debug("Error on sampler loop creation", zap.Error(err))
+		r.set.Logger.Error("Error on sampler loop creation", zap.Error(err))
+		r.status.permanent(samplerStatusSource, err)
 		return
 	}
+	r.status.ok(samplerStatusSource)
+	if closer, ok := samplerEmitter.(io.Closer); ok {
+		defer closer.Close()
+	}
 
 	ticker := time.NewTicker(r.samplerPollInterval)
 	defer ticker.Stop()
@@ -177,9 +327,62 @@ func (r *receiver) samplerLoop(ctx context.Context, persister operator.Persister
 	for {
 		select {
 		case <-ticker.C:
-			samplerEmitter.Emit(ctx)
+			// Counters are cumulative, a sample delayed while throttled is not lost.
+			if r.budget != nil {
+				if err := r.budget.wait(ctx); err != nil {
+					return
+				}
+			}
+			if err := samplerEmitter.Emit(ctx); err != nil {
+				r.set.Logger.Warn("Failed to sample", zap.Error(err))
+				r.status.recoverable(samplerStatusSource, err)
+			} else {
+				r.samplerTelemetry.recordSuccess()
+				r.status.ok(samplerStatusSource)
+			}
+		case <-r.inputStop:
+			// The final sample covers the usage since the last tick.
+			if err := samplerEmitter.Emit(ctx); err != nil {
+				r.set.Logger.Warn("Failed to take the final sample", zap.Error(err))
+				r.status.recoverable(samplerStatusSource, err)
+			} else {
+				r.samplerTelemetry.recordSuccess()
+				r.status.ok(samplerStatusSource)
+			}
+			return
 		case <-ctx.Done():
 			return
 		}
 	}
 }
+
+// tailerStatusLoop reports a recoverable error while no file matches the file input patterns.
+func (r *receiver) tailerStatusLoop(ctx context.Context) {
+	defer r.inputWg.Done()
+
+	ticker := time.NewTicker(tailerStatusInterval)
+	defer ticker.Stop()
+
+	for {
+		r.checkTailer()
+		select {
+		case <-ticker.C:
+		case <-r.inputStop:
+			return
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (r *receiver) checkTailer() {
+	matched, err := matchFiles(r.tailerInclude, r.tailerExclude)
+	switch {
+	case err != nil:
+		r.status.recoverable(tailerStatusSource, err)
+	case !matched:
+		r.status.recoverable(tailerStatusSource, errors.New("no files match the include patterns"))
+	default:
+		r.status.ok(tailerStatusSource)
+	}
+}
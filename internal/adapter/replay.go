@@ -0,0 +1,240 @@
+package adapter // import "github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/adapter"
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync/atomic"
+
+	"github.com/bmatcuk/doublestar/v4"
+	"github.com/fsgonz/otelnetstatsreceiver/internal/consumerretry"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/operator"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.uber.org/zap"
+)
+
+const (
+	OTLP_JSON_REPLAY_FORMAT  = "otlp_json"
+	OTLP_PROTO_REPLAY_FORMAT = "otlp_proto"
+
+	// replayedKeyPrefix prefixes the persisted keys of the files already replayed.
+	replayedKeyPrefix = "replayed:"
+	// maxReplayMessageSize bounds the length prefix read from protobuf files.
+	maxReplayMessageSize = 64 << 20
+)
+
+// replayer reads OTLP log files and writes their records into the input of the operator pipeline.
+type replayer struct {
+	cfg       ReplayConfig
+	logger    *zap.Logger
+	input     SamplerInput
+	converter *FromPdataConverter
+	persister operator.Persister
+
+	// written counts the entries written to input, notified on each write.
+	written atomic.Int64
+	notify  chan struct{}
+}
+
+func newReplayer(cfg ReplayConfig, set component.TelemetrySettings, input SamplerInput, persister operator.Persister) *replayer {
+	return &replayer{
+		cfg:       cfg,
+		logger:    set.Logger.With(zap.String("component", "replay")),
+		input:     input,
+		converter: NewFromPdataConverter(set, 0),
+		persister: persister,
+		notify:    make(chan struct{}, 1),
+	}
+}
+
+// run replays every file matching the include patterns once, skipping the ones replayed before.
+func (rp *replayer) run(ctx context.Context) {
+	rp.converter.Start()
+	defer rp.converter.Stop()
+
+	done := make(chan struct{})
+	defer close(done)
+	go rp.writeLoop(ctx, done)
+
+	for _, path := range rp.files() {
+		if err := rp.replayFile(ctx, path); err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			rp.logger.Error("Failed to replay file", zap.String("path", path), zap.Error(err))
+		}
+	}
+}
+
+// files returns the files matching the include patterns, in name order. Patterns support ** as the
+// include patterns of the file input do.
+func (rp *replayer) files() []string {
+	var files []string
+	seen := map[string]bool{}
+	for _, pattern := range rp.cfg.Include {
+		matches, err := doublestar.FilepathGlob(pattern, doublestar.WithFilesOnly())
+		if err != nil {
+			rp.logger.Error("Invalid replay pattern", zap.String("pattern", pattern), zap.Error(err))
+			continue
+		}
+		for _, match := range matches {
+			if !seen[match] {
+				seen[match] = true
+				files = append(files, match)
+			}
+		}
+	}
+	sort.Strings(files)
+	return files
+}
+
+// writeLoop writes the converted entries into the input.
+func (rp *replayer) writeLoop(ctx context.Context, done <-chan struct{}) {
+	for {
+		select {
+		case <-done:
+			return
+		case entries, ok := <-rp.converter.OutChannel():
+			if !ok {
+				return
+			}
+			for _, e := range entries {
+				rp.input.Write(ctx, e)
+			}
+			rp.written.Add(int64(len(entries)))
+			select {
+			case rp.notify <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
+
+func (rp *replayer) replayFile(ctx context.Context, path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	key := replayedKeyPrefix + path
+	version := []byte(fmt.Sprintf("%d:%d", info.Size(), info.ModTime().UnixNano()))
+	if replayed, err := rp.persister.Get(ctx, key); err == nil && string(replayed) == string(version) {
+		rp.logger.Debug("Skipping file already replayed", zap.String("path", path))
+		return nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if strings.HasSuffix(path, ".gz") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	expected := rp.written.Load()
+	err = rp.decode(r, func(logs plog.Logs) error {
+		expected += int64(logs.LogRecordCount())
+		return rp.converter.Batch(logs)
+	})
+	if err != nil {
+		return err
+	}
+
+	// Wait for the records to be written before marking the file as replayed.
+	for rp.written.Load() < expected {
+		select {
+		case <-rp.notify:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	rp.logger.Info("Replayed file", zap.String("path", path))
+	return rp.persister.Set(ctx, key, version)
+}
+
+// decode calls fn with each OTLP message read from r.
+func (rp *replayer) decode(r io.Reader, fn func(plog.Logs) error) error {
+	switch rp.cfg.Format {
+	case OTLP_PROTO_REPLAY_FORMAT:
+		return decodeOTLPProto(r, fn)
+	default:
+		return decodeOTLPJSON(r, fn)
+	}
+}
+
+// decodeOTLPJSON reads a stream of OTLP-JSON documents, such as one per line. The records of a dead
+// letter file are unwrapped to the logs they hold.
+func decodeOTLPJSON(r io.Reader, fn func(plog.Logs) error) error {
+	unmarshaler := &plog.JSONUnmarshaler{}
+	decoder := json.NewDecoder(r)
+	for {
+		var raw json.RawMessage
+		if err := decoder.Decode(&raw); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		var record consumerretry.DeadLetterRecord
+		if err := json.Unmarshal(raw, &record); err == nil && record.Logs != nil {
+			raw = record.Logs
+		}
+		logs, err := unmarshaler.UnmarshalLogs(raw)
+		if err != nil {
+			return err
+		}
+		// Documents that are not OTLP-JSON decode to empty logs rather than failing.
+		if logs.ResourceLogs().Len() == 0 {
+			return errors.New("document without resourceLogs")
+		}
+		if err := fn(logs); err != nil {
+			return err
+		}
+	}
+}
+
+// decodeOTLPProto reads OTLP protobuf messages, each prefixed by its size as a big endian uint32.
+func decodeOTLPProto(r io.Reader, fn func(plog.Logs) error) error {
+	unmarshaler := &plog.ProtoUnmarshaler{}
+	br := bufio.NewReader(r)
+	var size [4]byte
+	for {
+		if _, err := io.ReadFull(br, size[:]); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		n := binary.BigEndian.Uint32(size[:])
+		if n > maxReplayMessageSize {
+			return fmt.Errorf("message of %d bytes exceeds %d bytes", n, maxReplayMessageSize)
+		}
+		buf := make([]byte, n)
+		if _, err := io.ReadFull(br, buf); err != nil {
+			return err
+		}
+		logs, err := unmarshaler.UnmarshalLogs(buf)
+		if err != nil {
+			return err
+		}
+		if err := fn(logs); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package adapter
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/fsgonz/otelnetstatsreceiver/internal/consumerretry"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/testutil"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/pdata/plog"
+)
+
+func TestReplay(t *testing.T) {
+	dir := t.TempDir()
+	var jsonFile []byte
+	for _, bodies := range [][]string{{"first", "second"}, {"third"}} {
+		doc, err := (&plog.JSONMarshaler{}).MarshalLogs(replayLogs(bodies...))
+		require.NoError(t, err)
+		jsonFile = append(append(jsonFile, doc...), '\n')
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "archive.json"), jsonFile, 0600))
+
+	msg, err := (&plog.ProtoMarshaler{}).MarshalLogs(replayLogs("fourth"))
+	require.NoError(t, err)
+	protoFile := binary.BigEndian.AppendUint32(nil, uint32(len(msg)))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "archive.pb"), append(protoFile, msg...), 0600))
+
+	next := testutil.NewFakeOutput(t)
+	pipe, err := buildSamplerPipeline(componenttest.NewNopTelemetrySettings(), nil, next)
+	require.NoError(t, err)
+	input, err := findInput(pipe, samplerInputType)
+	require.NoError(t, err)
+	persister := testutil.NewUnscopedMockPersister()
+	require.NoError(t, pipe.Start(persister))
+	defer func() {
+		require.NoError(t, pipe.Stop())
+	}()
+
+	replay := func(cfg ReplayConfig) {
+		newReplayer(cfg, componenttest.NewNopTelemetrySettings(), input, persister).run(context.Background())
+	}
+	replay(ReplayConfig{Include: []string{filepath.Join(dir, "*.json")}})
+	replay(ReplayConfig{Include: []string{filepath.Join(dir, "*.pb")}, Format: OTLP_PROTO_REPLAY_FORMAT})
+
+	var bodies []any
+	for i := 0; i < 4; i++ {
+		ent := <-next.Received
+		require.Equal(t, "replay", ent.Resource["service.name"])
+		bodies = append(bodies, ent.Body)
+	}
+	require.ElementsMatch(t, []any{"first", "second", "third", "fourth"}, bodies)
+
+	// Files already replayed are skipped.
+	replay(ReplayConfig{Include: []string{filepath.Join(dir, "*.json")}})
+	require.Empty(t, next.Received)
+}
+
+func TestReplayDeadLetterFile(t *testing.T) {
+	dir := t.TempDir()
+	cfg := consumerretry.NewDefaultConfig()
+	cfg.DeadLetter.Enabled = true
+	cfg.DeadLetter.Path = filepath.Join(dir, "nested", "deadletter.json")
+	retry, err := consumerretry.NewLogs(cfg, component.MustNewID("otelnetstatsreceiver"), componenttest.NewNopTelemetrySettings(),
+		consumertest.NewErr(errors.New("refused")))
+	require.NoError(t, err)
+	require.Error(t, retry.ConsumeLogs(context.Background(), replayLogs("dropped")))
+	require.NoError(t, retry.Shutdown(context.Background()))
+
+	next := testutil.NewFakeOutput(t)
+	pipe, err := buildSamplerPipeline(componenttest.NewNopTelemetrySettings(), nil, next)
+	require.NoError(t, err)
+	input, err := findInput(pipe, samplerInputType)
+	require.NoError(t, err)
+	persister := testutil.NewUnscopedMockPersister()
+	require.NoError(t, pipe.Start(persister))
+	defer func() {
+		require.NoError(t, pipe.Stop())
+	}()
+
+	// ** matches the nested directories, as in the include patterns of the file input.
+	replayCfg := ReplayConfig{Include: []string{filepath.Join(dir, "**", "*.json")}}
+	newReplayer(replayCfg, componenttest.NewNopTelemetrySettings(), input, persister).run(context.Background())
+	ent := <-next.Received
+	require.Equal(t, "dropped", ent.Body)
+	require.Equal(t, "replay", ent.Resource["service.name"])
+
+	// Documents that are neither OTLP-JSON nor dead letter records are not taken for empty logs.
+	require.Error(t, decodeOTLPJSON(strings.NewReader(`{"time":"2024-01-01T00:00:00Z"}`), func(plog.Logs) error { return nil }))
+}
+
+func replayLogs(bodies ...string) plog.Logs {
+	logs := plog.NewLogs()
+	rl := logs.ResourceLogs().AppendEmpty()
+	rl.Resource().Attributes().PutStr("service.name", "replay")
+	records := rl.ScopeLogs().AppendEmpty().LogRecords()
+	for _, body := range bodies {
+		records.AppendEmpty().Body().SetStr(body)
+	}
+	return logs
+}
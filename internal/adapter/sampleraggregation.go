@@ -0,0 +1,186 @@
+package adapter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/operator"
+)
+
+// AGGREGATE_WINDOW_KEY is the persister key of the window being aggregated.
+const AGGREGATE_WINDOW_KEY = "AGGREGATE_WINDOW"
+
+// aggregatePercentile is the percentile of the rates reported by aggregates.
+const aggregatePercentile = 0.95
+
+// rateAggregate summarizes the per-interval rates of the samples of a window.
+type rateAggregate struct {
+	Min  float64 `json:"min"`
+	Max  float64 `json:"max"`
+	Mean float64 `json:"mean"`
+	P95  float64 `json:"p95"`
+}
+
+// networkIOAggregate describes the window an aggregated event covers. The usage of the event is the sum of the
+// usage of the samples.
+type networkIOAggregate struct {
+	// WindowStart and WindowEnd delimit the window in unix epoch milliseconds.
+	WindowStart int64 `json:"window_start"`
+	WindowEnd   int64 `json:"window_end"`
+	// Samples is the number of samples in the window.
+	Samples int `json:"samples"`
+	// BytesPerSecond and PacketsPerSecond summarize the rates of the samples that have one.
+	BytesPerSecond   *rateAggregate `json:"bytes_per_second,omitempty"`
+	PacketsPerSecond *rateAggregate `json:"packets_per_second,omitempty"`
+}
+
+// aggregationWindow is the persisted state of the window being aggregated.
+type aggregationWindow struct {
+	Start            int64     `json:"start"`
+	UsageBytes       uint64    `json:"usage_bytes"`
+	Samples          int       `json:"samples"`
+	BytesPerSecond   []float64 `json:"bytes_per_second"`
+	PacketsPerSecond []float64 `json:"packets_per_second"`
+}
+
+// windowAggregator accumulates the sampled entries into windows of interval, aligned on the wall clock,
+// and returns the aggregated entry of a window once a sample of a later window is added. The window is
+// persisted after every sample whose records are written, so a restart continues it.
+type windowAggregator struct {
+	interval  time.Duration
+	persister operator.Persister
+
+	loaded bool
+	window *aggregationWindow
+}
+
+func newWindowAggregator(interval time.Duration, persister operator.Persister) *windowAggregator {
+	return &windowAggregator{
+		interval:  interval,
+		persister: persister,
+	}
+}
+
+// add adds the sampled entry to its window. It returns the aggregated entry of the previous window when
+// the sample starts a new one, and false otherwise. The window only changes once the returned stateCommit
+// is called, so a sample whose records could not be written is not added.
+func (a *windowAggregator) add(ctx context.Context, sampled networkIOLogEntry) (networkIOLogEntry, bool, stateCommit, error) {
+	if err := a.load(ctx); err != nil {
+		return networkIOLogEntry{}, false, nil, err
+	}
+
+	evt := sampled.Events[0]
+	start := time.UnixMilli(evt.Timestamp).Truncate(a.interval).UnixMilli()
+
+	var aggregated networkIOLogEntry
+	closed := false
+	var window aggregationWindow
+	switch {
+	case a.window != nil && start > a.window.Start:
+		aggregated, closed = a.aggregate(sampled), true
+		window = aggregationWindow{Start: start}
+	case a.window != nil:
+		window = *a.window
+		window.BytesPerSecond = slices.Clone(a.window.BytesPerSecond)
+		window.PacketsPerSecond = slices.Clone(a.window.PacketsPerSecond)
+	default:
+		window = aggregationWindow{Start: start}
+	}
+
+	window.UsageBytes += evt.UsageBytes
+	window.Samples++
+	if evt.BytesPerSecond != nil {
+		window.BytesPerSecond = append(window.BytesPerSecond, *evt.BytesPerSecond)
+	}
+	if evt.PacketsPerSecond != nil {
+		window.PacketsPerSecond = append(window.PacketsPerSecond, *evt.PacketsPerSecond)
+	}
+
+	commit := func(ctx context.Context) error {
+		state, err := json.Marshal(window)
+		if err != nil {
+			return err
+		}
+		if err := a.persister.Set(ctx, AGGREGATE_WINDOW_KEY, state); err != nil {
+			return fmt.Errorf("persist aggregation window: %w", err)
+		}
+		a.window = &window
+		return nil
+	}
+	return aggregated, closed, commit, nil
+}
+
+func (a *windowAggregator) load(ctx context.Context) error {
+	if a.loaded {
+		return nil
+	}
+	state, err := a.persister.Get(ctx, AGGREGATE_WINDOW_KEY)
+	if err != nil {
+		return fmt.Errorf("load aggregation window: %w", err)
+	}
+	if state != nil {
+		window := &aggregationWindow{}
+		if err := json.Unmarshal(state, window); err != nil {
+			return fmt.Errorf("load aggregation window: %w", err)
+		}
+		a.window = window
+	}
+	a.loaded = true
+	return nil
+}
+
+// aggregate returns the entry of the current window, with the envelope and identity of the sampled entry.
+func (a *windowAggregator) aggregate(sampled networkIOLogEntry) networkIOLogEntry {
+	end := time.UnixMilli(a.window.Start).Add(a.interval).UnixMilli()
+	u, _ := uuid.NewRandom()
+
+	evt := sampled.Events[0]
+	evt.ID = u.String()
+	evt.Timestamp = end
+	evt.UsageBytes = a.window.UsageBytes
+	evt.BytesPerSecond, evt.PacketsPerSecond = nil, nil
+	evt.Aggregate = &networkIOAggregate{
+		WindowStart:      a.window.Start,
+		WindowEnd:        end,
+		Samples:          a.window.Samples,
+		BytesPerSecond:   summarize(a.window.BytesPerSecond),
+		PacketsPerSecond: summarize(a.window.PacketsPerSecond),
+	}
+	if evt.Aggregate.BytesPerSecond != nil {
+		evt.BytesPerSecond = &evt.Aggregate.BytesPerSecond.Mean
+	}
+	if evt.Aggregate.PacketsPerSecond != nil {
+		evt.PacketsPerSecond = &evt.Aggregate.PacketsPerSecond.Mean
+	}
+
+	aggregated := sampled
+	aggregated.Time = end
+	aggregated.Events = []networkIOLogEntryEvent{evt}
+	return aggregated
+}
+
+// summarize returns the min, max, mean and nearest rank p95 of the rates, or nil if there are none.
+func summarize(rates []float64) *rateAggregate {
+	if len(rates) == 0 {
+		return nil
+	}
+	sorted := slices.Clone(rates)
+	slices.Sort(sorted)
+
+	var sum float64
+	for _, rate := range sorted {
+		sum += rate
+	}
+	rank := int(math.Ceil(aggregatePercentile*float64(len(sorted)))) - 1
+	return &rateAggregate{
+		Min:  sorted[0],
+		Max:  sorted[len(sorted)-1],
+		Mean: sum / float64(len(sorted)),
+		P95:  sorted[max(rank, 0)],
+	}
+}
@@ -0,0 +1,90 @@
+package adapter
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWindowAggregator(t *testing.T) {
+	ctx := context.Background()
+	persister := testutil.NewUnscopedMockPersister()
+	windowStart := time.Date(2024, 6, 1, 10, 0, 0, 0, time.UTC)
+
+	aggregator := newWindowAggregator(time.Hour, persister)
+	sampled := func(offset time.Duration, usage uint64, bytesPerSecond *float64) networkIOLogEntry {
+		entry := testNetworkIOLogEntry()
+		entry.Events[0].Timestamp = windowStart.Add(offset).UnixMilli()
+		entry.Events[0].UsageBytes = usage
+		entry.Events[0].BytesPerSecond = bytesPerSecond
+		return entry
+	}
+	rate := func(r float64) *float64 { return &r }
+
+	// The first sample has no rate, it only adds to the usage.
+	for i, entry := range []networkIOLogEntry{
+		sampled(10*time.Second, 500, nil),
+		sampled(20*time.Minute, 100, rate(10)),
+	} {
+		_, closed, commit, err := aggregator.add(ctx, entry)
+		require.NoError(t, err)
+		require.NoError(t, commit(ctx))
+		require.False(t, closed, "sample %d", i)
+	}
+
+	// The partial window is persisted, so it is continued after a restart.
+	aggregator = newWindowAggregator(time.Hour, persister)
+	for _, entry := range []networkIOLogEntry{
+		sampled(40*time.Minute, 300, rate(30)),
+		sampled(59*time.Minute, 200, rate(20)),
+	} {
+		_, closed, commit, err := aggregator.add(ctx, entry)
+		require.NoError(t, err)
+		require.NoError(t, commit(ctx))
+		require.False(t, closed)
+	}
+
+	// A sample whose records are not written does not change the window.
+	_, _, _, err := aggregator.add(ctx, sampled(59*time.Minute, 1000, rate(100)))
+	require.NoError(t, err)
+
+	aggregated, closed, commit, err := aggregator.add(ctx, sampled(time.Hour+10*time.Second, 50, rate(5)))
+	require.NoError(t, err)
+	require.NoError(t, commit(ctx))
+	require.True(t, closed)
+
+	evt := aggregated.Events[0]
+	require.Equal(t, uint64(1100), evt.UsageBytes)
+	require.Equal(t, windowStart.Add(time.Hour).UnixMilli(), evt.Timestamp)
+	require.Equal(t, &networkIOAggregate{
+		WindowStart:    windowStart.UnixMilli(),
+		WindowEnd:      windowStart.Add(time.Hour).UnixMilli(),
+		Samples:        4,
+		BytesPerSecond: &rateAggregate{Min: 10, Max: 30, Mean: 20, P95: 30},
+	}, evt.Aggregate)
+	require.Equal(t, 20.0, *evt.BytesPerSecond, "the rate of the window is the mean one")
+	require.Nil(t, evt.PacketsPerSecond)
+	require.NotEqual(t, testNetworkIOLogEntry().Events[0].ID, evt.ID)
+	require.Equal(t, "worker-0", evt.WorkerID)
+
+	// The sample closing the window starts the next one.
+	aggregated, closed, _, err = aggregator.add(ctx, sampled(3*time.Hour, 0, rate(0)))
+	require.NoError(t, err)
+	require.True(t, closed)
+	require.Equal(t, uint64(50), aggregated.Events[0].UsageBytes)
+	require.Equal(t, 1, aggregated.Events[0].Aggregate.Samples)
+}
+
+func TestSummarize(t *testing.T) {
+	require.Nil(t, summarize(nil))
+
+	rates := make([]float64, 0, 100)
+	for i := 100; i > 0; i-- {
+		rates = append(rates, float64(i))
+	}
+	require.Equal(t, &rateAggregate{Min: 1, Max: 100, Mean: 50.5, P95: 95}, summarize(rates))
+	require.Equal(t, 100.0, rates[0], "the rates are not sorted in place")
+}
@@ -4,27 +4,48 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"github.com/fsgonz/otelnetstatsreceiver/internal/file"
+	"github.com/fsgonz/otelnetstatsreceiver/internal/envelope"
+	"github.com/fsgonz/otelnetstatsreceiver/internal/logsampler"
 	"github.com/fsgonz/otelnetstatsreceiver/internal/lumberjack"
 	"github.com/fsgonz/otelnetstatsreceiver/internal/stats/sampler"
 	"github.com/fsgonz/otelnetstatsreceiver/internal/stats/scraper"
 	"github.com/google/uuid"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/entry"
 	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/operator"
-	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/operator/helper"
+	"io"
 	"log"
 	"os"
 	"strconv"
 	"strings"
 	"time"
+
+	"go.uber.org/zap"
+)
+
+// The persister keys of the state of the last sample. The state was persisted under a key per value before
+// SAMPLER_STATE_KEY, these keys are still read after an upgrade.
+const (
+	SAMPLER_STATE_KEY     = "SAMPLER_STATE"
+	LAST_COUNT_KEY        = "LAST_COUNT"
+	LAST_PACKET_COUNT_KEY = "LAST_PACKET_COUNT"
+	LAST_SAMPLE_TIME_KEY  = "LAST_SAMPLE_TIME"
 )
 
 const (
-	LAST_COUNT_KEY          = "LAST_COUNT"
 	FORMAT                  = "v1"
 	SCHEMA_ID               = "schema_id"
 	NETWORK_SCHEMA_ID       = "network_schema_id"
 	FILE_LOGGER_OUTPUT      = "file_logger"
 	PIPELINE_EMITTER_OUTPUT = "pipeline_emitter"
+	PROCFS_SOURCE           = "procfs"
+	NETLINK_SOURCE          = "netlink"
+	SYSFS_SOURCE            = "sysfs"
+	DELTA_MODE              = "delta"
+	RATE_MODE               = "rate"
+	RECORDS_ENCRYPTION      = "records"
+	FILES_ENCRYPTION        = "files"
+	HOST_NAME_RESOURCE      = "host.name"
+	WORKER_ID_RESOURCE      = "worker.id"
 )
 
 type networkIOLogEntry struct {
@@ -47,80 +68,431 @@ type networkIOLogEntryEvent struct {
 	WorkerID   string `json:"worker_id"`
 	UsageBytes uint64 `json:"usage_bytes"`
 	Billable   bool   `json:"billable"`
+	// BytesPerSecond is the usage divided by the seconds elapsed since the last sample. Only set in rate mode,
+	// from the second sample on.
+	BytesPerSecond *float64 `json:"bytes_per_second,omitempty"`
+	// PacketsPerSecond is the packets since the last sample divided by the seconds elapsed. Only set in rate
+	// mode, from the second sample on.
+	PacketsPerSecond *float64 `json:"packets_per_second,omitempty"`
+	// Aggregate describes the window of an aggregated event, whose rates are then the mean ones.
+	Aggregate *networkIOAggregate `json:"aggregate,omitempty"`
 }
 
 type SamplerEmitter interface {
-	Emit(context.Context)
+	// Emit takes a sample and writes its records, returning an error if none could be written.
+	Emit(context.Context) error
 }
 
 type FileLoggerSamplerEmitter struct {
 	URI           string
-	metricsLogger log.Logger
+	metricsLogger *log.Logger
 	persister     operator.Persister
 	sampler       sampler.Sampler
+	encoder       RecordEncoder
+	telemetry     *SamplerTelemetry
+	// rate adds the bytes and packets per second to the records.
+	rate bool
+	// aggregator, if set, turns the samples into a record per window.
+	aggregator *windowAggregator
+	// alerts, if set, writes the alerts of the rules into the pipeline.
+	alerts *alertEmitter
+	// signer, if set, signs the records.
+	signer *recordSigner
+}
+
+func (e *FileLoggerSamplerEmitter) Emit(ctx context.Context) error {
+	logEntry, ok, commit, err := sampledEntry(ctx, e.persister, e.sampler, e.telemetry, e.rate, e.aggregator)
+	if alertErr := e.alerts.emit(ctx); alertErr != nil {
+		return alertErr
+	}
+	if err != nil {
+		return err
+	}
+	if ok {
+		if err := e.write(ctx, logEntry); err != nil {
+			return err
+		}
+	}
+	return commit(ctx)
 }
 
-func (e FileLoggerSamplerEmitter) Emit(ctx context.Context) {
-	jsonEntry := logEntry(ctx, e.persister, e.sampler)
-	e.metricsLogger.Println(string(jsonEntry))
+func (e *FileLoggerSamplerEmitter) write(ctx context.Context, logEntry networkIOLogEntry) error {
+	records, err := e.encoder.Encode(logEntry)
+	if err != nil {
+		return err
+	}
+	for _, record := range records {
+		record, commit, err := e.signer.sign(ctx, record)
+		if err != nil {
+			return err
+		}
+		if err := e.metricsLogger.Output(2, string(record)); err != nil {
+			return err
+		}
+		e.telemetry.recordRecords(ctx, 1, FILE_LOGGER_OUTPUT)
+		if err := commit(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
+// Close closes the file records are written to.
+func (e *FileLoggerSamplerEmitter) Close() error {
+	if closer, ok := e.metricsLogger.Writer().(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
 }
 
 type PipelineConsumerSamplerEmitter struct {
-	Emitter   helper.LogEmitter
+	Emitter   operator.Operator
 	persister operator.Persister
 	sampler   sampler.Sampler
-	input     file.Input
+	input     SamplerInput
+	encoder   RecordEncoder
+	// structured emits one entry per event with a map body instead of encoded records.
+	structured bool
+	telemetry  *SamplerTelemetry
+	// rate adds the bytes and packets per second to the records.
+	rate bool
+	// aggregator, if set, turns the samples into a record per window.
+	aggregator *windowAggregator
+	// alerts, if set, writes the alerts of the rules into the pipeline.
+	alerts *alertEmitter
+	// hostname is the host.name resource of the structured entries, not set if empty.
+	hostname string
+}
+
+func (e *PipelineConsumerSamplerEmitter) Emit(ctx context.Context) error {
+	logEntry, ok, commit, err := sampledEntry(ctx, e.persister, e.sampler, e.telemetry, e.rate, e.aggregator)
+	if alertErr := e.alerts.emit(ctx); alertErr != nil {
+		return alertErr
+	}
+	if err != nil {
+		return err
+	}
+	if ok {
+		if err := e.write(ctx, logEntry); err != nil {
+			return err
+		}
+	}
+	return commit(ctx)
+}
+
+func (e *PipelineConsumerSamplerEmitter) write(ctx context.Context, logEntry networkIOLogEntry) error {
+	if e.structured {
+		return e.emitStructured(ctx, logEntry)
+	}
+
+	records, err := e.encoder.Encode(logEntry)
+	if err != nil {
+		return err
+	}
+	for _, record := range records {
+		ent, err := e.input.NewEntry(string(record))
+		if err != nil {
+			return err
+		}
+		e.input.Write(ctx, ent)
+		e.telemetry.recordRecords(ctx, 1, PIPELINE_EMITTER_OUTPUT)
+	}
+	return nil
+}
+
+// emitStructured writes an entry per event with the event fields as a map body,
+// the usage as attributes, the event time as timestamp and the host and worker
+// as resource attributes, so no parser operator is needed downstream.
+func (e *PipelineConsumerSamplerEmitter) emitStructured(ctx context.Context, logEntry networkIOLogEntry) error {
+	for _, flat := range flatten(logEntry) {
+		ent, err := e.input.NewEntry(flat.asMap())
+		if err != nil {
+			return err
+		}
+		ent.Timestamp = time.UnixMilli(flat.Timestamp)
+		if ent.Attributes == nil {
+			ent.Attributes = map[string]any{}
+		}
+		ent.Attributes[SCHEMA_ID] = flat.SchemaID
+		ent.Attributes["usage_bytes"] = flat.UsageBytes
+		ent.Attributes["billable"] = flat.Billable
+		if flat.BytesPerSecond != nil {
+			ent.Attributes["bytes_per_second"] = *flat.BytesPerSecond
+		}
+		if flat.PacketsPerSecond != nil {
+			ent.Attributes["packets_per_second"] = *flat.PacketsPerSecond
+		}
+		for key, value := range logEntry.Metadata {
+			if key != SCHEMA_ID {
+				ent.Attributes[key] = value
+			}
+		}
+		if e.hostname != "" {
+			addResourceIfAbsent(ent, HOST_NAME_RESOURCE, e.hostname)
+		}
+		addResourceIfAbsent(ent, WORKER_ID_RESOURCE, flat.WorkerID)
+
+		e.input.Write(ctx, ent)
+		e.telemetry.recordRecords(ctx, 1, PIPELINE_EMITTER_OUTPUT)
+	}
+	return nil
 }
 
-func (e PipelineConsumerSamplerEmitter) Emit(ctx context.Context) {
-	jsonEntry := logEntry(ctx, e.persister, e.sampler)
-	e.input.Emit(ctx, jsonEntry, map[string]any{})
+// addResourceIfAbsent sets a resource attribute unless the input configuration already provides it.
+func addResourceIfAbsent(ent *entry.Entry, key string, value string) {
+	if _, ok := ent.Resource[key]; ok {
+		return
+	}
+	ent.AddResourceKey(key, value)
 }
 
-func SamplerEmitterFactory(output string, uri string, persister operator.Persister, emitter *helper.LogEmitter, input file.Input) (SamplerEmitter, error) {
-	fileBasedSampler := sampler.NewFileBasedSampler("/proc/net/dev", scraper.NewLinuxNetworkDevicesFileScraper())
+func SamplerEmitterFactory(cfg logsampler.LogSampler, persister operator.Persister, emitter operator.Operator, input SamplerInput, alertInput SamplerInput, telemetry *SamplerTelemetry, logger *zap.Logger) (SamplerEmitter, error) {
+	networkStatsSampler := networkSampler(cfg, logger)
+	hostname, _ := os.Hostname()
+	var statsSampler sampler.Sampler = networkStatsSampler
+	var alerts *alertEmitter
+	if len(cfg.Rules) > 0 {
+		rules := newRuleSampler(networkStatsSampler, cfg.Rules)
+		statsSampler = rules
+		if _, ok := networkStatsSampler.(sampler.InterfacesSampler); ok {
+			statsSampler = interfacesRuleSampler{rules}
+		}
+		alerts = &alertEmitter{rules: rules, input: alertInput, hostname: hostname}
+	}
+
+	rate := cfg.Mode == RATE_MODE
+	encoderOpts := []encoderOption{}
+	if rate {
+		encoderOpts = append(encoderOpts, withRates())
+	}
+	var aggregator *windowAggregator
+	if cfg.Aggregation.Interval > 0 {
+		aggregator = newWindowAggregator(cfg.Aggregation.Interval, persister)
+		encoderOpts = append(encoderOpts, withAggregates())
+	}
+	// Only the sysfs source describes the sampled interfaces.
+	if cfg.Source == SYSFS_SOURCE {
+		encoderOpts = append(encoderOpts, withMetadata())
+	}
+	encoder, err := RecordEncoderFactory(cfg.Encoding, encoderOpts...)
+	if err != nil {
+		return nil, err
+	}
 
-	switch output {
+	switch cfg.Output {
 	case FILE_LOGGER_OUTPUT:
-		metricsLogger := log.New(&lumberjack.Logger{
-			Filename:   uri,
+		fileMode, err := logsampler.ParseFileMode(cfg.FileMode)
+		if err != nil {
+			return nil, err
+		}
+		dirMode, err := logsampler.ParseFileMode(cfg.DirMode)
+		if err != nil {
+			return nil, err
+		}
+
+		var signer *recordSigner
+		if cfg.Signing.KeyFile != "" {
+			signer, err = newRecordSigner(cfg.Signing.KeyFile, persister)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		writer := &lumberjack.Logger{
+			Filename:   cfg.URI,
 			MaxSize:    100, // kilobytes
 			MaxBackups: 20,
-		}, "", 0)
+			FileMode:   fileMode,
+			DirMode:    dirMode,
+			UID:        cfg.UID,
+			GID:        cfg.GID,
+			Header:     encoder.Header(),
+		}
+		if cfg.Encryption.KeyFile != "" {
+			keyring, err := envelope.LoadKeyring(cfg.Encryption.KeyFile)
+			if err != nil {
+				return nil, err
+			}
+			if cfg.Encryption.Mode == FILES_ENCRYPTION {
+				writer.EncryptFile = keyring.EncryptFile
+			} else {
+				writer.EncryptRecord = keyring.EncryptRecord
+			}
+		}
+		metricsLogger := log.New(writer, "", 0)
 
-		return FileLoggerSamplerEmitter{
-			output,
-			*metricsLogger,
+		return &FileLoggerSamplerEmitter{
+			cfg.URI,
+			metricsLogger,
 			persister,
-			fileBasedSampler,
+			statsSampler,
+			encoder,
+			telemetry,
+			rate,
+			aggregator,
+			alerts,
+			signer,
+		}, nil
+	case UNIX_SOCKET_OUTPUT, UDP_OUTPUT, SYSLOG_OUTPUT:
+		return &NetworkSamplerEmitter{
+			newNetworkOutput(cfg, encoder.Header(), telemetry, logger),
+			persister,
+			statsSampler,
+			encoder,
+			telemetry,
+			rate,
+			aggregator,
+			alerts,
 		}, nil
 	case PIPELINE_EMITTER_OUTPUT:
-		return PipelineConsumerSamplerEmitter{
-			*emitter,
+		return &PipelineConsumerSamplerEmitter{
+			emitter,
 			persister,
-			fileBasedSampler,
+			statsSampler,
 			input,
+			encoder,
+			cfg.Structured,
+			telemetry,
+			rate,
+			aggregator,
+			alerts,
+			hostname,
 		}, nil
 	default:
-		return nil, fmt.Errorf("unknown output type: %s", output)
+		return nil, fmt.Errorf("unknown output type: %s", cfg.Output)
 	}
 }
 
-func logEntry(ctx context.Context, persister operator.Persister, sampler sampler.Sampler) []byte {
-	byteSlice, _ := persister.Get(ctx, LAST_COUNT_KEY)
+// networkSampler returns the sampler of the configured source, reading eth0 or, with physical_only,
+// the sum of the physical interfaces. The netlink sampler falls back to procfs, warning the first
+// time it does.
+func networkSampler(cfg logsampler.LogSampler, logger *zap.Logger) sampler.StatsSampler {
+	// The samplers fall back once, on the first netlink failure.
+	onFallback := func(err error) {
+		logger.Warn("Failed to sample with netlink, falling back to procfs", zap.Error(err))
+	}
 
-	var last_count uint64 = 0
+	if !cfg.PhysicalOnly {
+		return interfaceSampler(cfg, "", onFallback)
+	}
+	return sampler.NewPhysicalInterfacesSampler(cfg.SysfsRoot, interfacesSource(cfg, onFallback))
+}
 
-	if byteSlice != nil {
-		// Parse the string to an integer
-		counter, _ := strconv.ParseUint(string(byteSlice), 10, 64)
-		last_count = counter
+// interfacesSource returns the source of the configured source for the physical interfaces. netlink reads all
+// of them from a single dump.
+func interfacesSource(cfg logsampler.LogSampler, onFallback func(error)) sampler.InterfacesSource {
+	procfsSamplers := sampler.NewInterfaceSamplers(func(interfaceName string) sampler.StatsSampler {
+		return sampler.NewFileBasedSampler("/proc/net/dev", scraper.NewLinuxNetworkDevicesFileScraperWithInterface(interfaceName))
+	})
+	switch cfg.Source {
+	case SYSFS_SOURCE:
+		return sampler.NewInterfaceSamplers(func(interfaceName string) sampler.StatsSampler {
+			return sampler.NewSysfsSampler(scraper.NewSysfsScraper(cfg.SysfsRoot, interfaceName))
+		})
+	case NETLINK_SOURCE:
+		return sampler.NewFallbackSource(sampler.NewNetlinkSource(), procfsSamplers, onFallback)
+	default:
+		return procfsSamplers
+	}
+}
+
+// interfaceSampler returns the sampler of the configured source for an interface, eth0 if empty.
+func interfaceSampler(cfg logsampler.LogSampler, interfaceName string, onFallback func(error)) sampler.StatsSampler {
+	procfsSampler := sampler.NewFileBasedSampler("/proc/net/dev", scraper.NewLinuxNetworkDevicesFileScraperWithInterface(interfaceName))
+	switch cfg.Source {
+	case SYSFS_SOURCE:
+		return sampler.NewSysfsSampler(scraper.NewSysfsScraper(cfg.SysfsRoot, interfaceName))
+	case NETLINK_SOURCE:
+		return sampler.NewFallbackSampler(
+			sampler.NewNetlinkSampler(scraper.NewNetlinkScraperWithInterface(interfaceName)),
+			procfsSampler,
+			onFallback,
+		)
+	default:
+		return procfsSampler
+	}
+}
+
+// stateCommit persists the state of a sample once its records are written, so that the usage of a sample
+// whose records could not be written is included in the next one.
+type stateCommit func(ctx context.Context) error
+
+// then returns a stateCommit persisting the state of c, then the one of next.
+func (c stateCommit) then(next stateCommit) stateCommit {
+	return func(ctx context.Context) error {
+		if err := c(ctx); err != nil {
+			return err
+		}
+		return next(ctx)
+	}
+}
+
+// noCommit is the stateCommit of a state that is not persisted.
+func noCommit(context.Context) error {
+	return nil
+}
+
+// sampledEntry returns the entry of a sample or, with an aggregator, the entry of the window the sample
+// closes. It returns false when the sample is only added to the window being aggregated. The returned
+// stateCommit must be called once the records of the entry are written.
+func sampledEntry(ctx context.Context, persister operator.Persister, statsSampler sampler.Sampler, telemetry *SamplerTelemetry, rate bool, aggregator *windowAggregator) (networkIOLogEntry, bool, stateCommit, error) {
+	// The windows summarize the rates of the samples.
+	logEntry, commit, err := logEntry(ctx, persister, statsSampler, telemetry, rate || aggregator != nil)
+	if err != nil {
+		return networkIOLogEntry{}, false, nil, err
+	}
+	if aggregator == nil {
+		return logEntry, true, commit, nil
+	}
+	aggregated, closed, commitWindow, err := aggregator.add(ctx, logEntry)
+	if err != nil {
+		return networkIOLogEntry{}, false, nil, err
+	}
+	return aggregated, closed, commit.then(commitWindow), nil
+}
+
+// logEntry samples the counter and returns the usage since the last sample. The counter is only
+// persisted by the returned stateCommit, once the records of the sample are written, so the usage of
+// a failed sample is included in the next one. A counter lower than the last one was reset, so the
+// usage is the counter itself.
+//
+// In rate mode the usage and the packets are also divided by the seconds elapsed since the last
+// sample, persisted with the counter, so delayed or missed ticks do not skew the rates.
+func logEntry(ctx context.Context, persister operator.Persister, statsSampler sampler.Sampler, telemetry *SamplerTelemetry, rate bool) (networkIOLogEntry, stateCommit, error) {
+	last, err := loadSamplerState(ctx, persister)
+	if err != nil {
+		return networkIOLogEntry{}, nil, err
 	}
 
-	samp, _ := sampler.Sample()
+	start := time.Now()
+	next, err := sample(statsSampler)
+	sampledAt := time.Now()
+	telemetry.recordScrape(ctx, sampledAt.Sub(start), err)
+	if err != nil {
+		return networkIOLogEntry{}, nil, fmt.Errorf("sample: %w", err)
+	}
+	next.Time = sampledAt.UnixNano()
+	usage, packetsDelta, hasPackets, reset := next.since(last)
+	if reset {
+		telemetry.recordCounterReset(ctx)
+	}
 
-	persister.Set(ctx, LAST_COUNT_KEY, []byte(strconv.FormatUint(samp, 10)))
+	commit := func(ctx context.Context) error {
+		return next.save(ctx, persister)
+	}
+
+	var bytesPerSecond, packetsPerSecond *float64
+	// The first sample has nothing to compare with, nor a sample taken after the clock went back.
+	if elapsed := sampledAt.Sub(time.Unix(0, last.Time)).Seconds(); rate && last.Time != 0 && elapsed > 0 {
+		bytesRate := float64(usage) / elapsed
+		bytesPerSecond = &bytesRate
+		if hasPackets {
+			packetsRate := float64(packetsDelta) / elapsed
+			packetsPerSecond = &packetsRate
+		}
+	}
+	// The usage of the records a network output dropped since the last sample is added, without skewing the rates.
+	usage += last.Dropped
 
 	orgID := os.Getenv("ORG_ID")
 	envID := os.Getenv("ENV_ID")
@@ -133,26 +505,184 @@ func logEntry(ctx context.Context, persister operator.Persister, sampler sampler
 	u, _ := uuid.NewRandom()
 
 	evt := networkIOLogEntryEvent{
-		ID:         u.String(),
-		Timestamp:  ts,
-		RootOrgID:  rootOrgID,
-		OrgID:      orgID,
-		EnvID:      envID,
-		AssetID:    deploymentID,
-		WorkerID:   workerID,
-		UsageBytes: samp - last_count,
-		Billable:   billingEnabled,
-	}
-
-	logEntry := networkIOLogEntry{
-		Format: FORMAT,
-		Time:   ts,
-		Events: []networkIOLogEntryEvent{evt},
-		Metadata: map[string]string{
-			SCHEMA_ID: NETWORK_SCHEMA_ID,
-		},
-	}
-
-	jsonEntry, _ := json.Marshal(logEntry)
-	return jsonEntry
+		ID:               u.String(),
+		Timestamp:        ts,
+		RootOrgID:        rootOrgID,
+		OrgID:            orgID,
+		EnvID:            envID,
+		AssetID:          deploymentID,
+		WorkerID:         workerID,
+		UsageBytes:       usage,
+		Billable:         billingEnabled,
+		BytesPerSecond:   bytesPerSecond,
+		PacketsPerSecond: packetsPerSecond,
+	}
+
+	metadata := map[string]string{}
+	// Samplers describing the sample, such as the sysfs link state, enrich the entry.
+	if metadataSampler, ok := statsSampler.(sampler.MetadataSampler); ok {
+		for key, value := range metadataSampler.Metadata() {
+			metadata[key] = value
+		}
+	}
+	metadata[SCHEMA_ID] = NETWORK_SCHEMA_ID
+
+	return networkIOLogEntry{
+		Format:   FORMAT,
+		Time:     ts,
+		Events:   []networkIOLogEntryEvent{evt},
+		Metadata: metadata,
+	}, commit, nil
+}
+
+// sample returns the state of a sample: the sampled counter and, for samplers of network statistics, the
+// packets and, for samplers of several interfaces, the counters of each one.
+func sample(statsSampler sampler.Sampler) (samplerState, error) {
+	if interfacesSampler, ok := statsSampler.(sampler.InterfacesSampler); ok {
+		interfaces, err := interfacesSampler.SampleInterfaces()
+		if err != nil {
+			return samplerState{}, err
+		}
+		stats := sampler.SumStats(interfaces)
+		packets := stats.ReceivedPackets + stats.TransmittedPackets
+		state := samplerState{
+			Count:      stats.ReceivedBytes + stats.TransmittedBytes,
+			Packets:    &packets,
+			Interfaces: make(map[string]interfaceCounters, len(interfaces)),
+		}
+		for name, stats := range interfaces {
+			state.Interfaces[name] = interfaceCounters{
+				Bytes:   stats.ReceivedBytes + stats.TransmittedBytes,
+				Packets: stats.ReceivedPackets + stats.TransmittedPackets,
+			}
+		}
+		return state, nil
+	}
+
+	networkStatsSampler, ok := statsSampler.(sampler.StatsSampler)
+	if !ok {
+		count, err := statsSampler.Sample()
+		return samplerState{Count: count}, err
+	}
+
+	stats, err := networkStatsSampler.SampleStats()
+	if err != nil {
+		return samplerState{}, err
+	}
+	packets := stats.ReceivedPackets + stats.TransmittedPackets
+	return samplerState{Count: stats.ReceivedBytes + stats.TransmittedBytes, Packets: &packets}, nil
+}
+
+// counterDelta returns the increase of a counter since its last value, or the counter itself if it was reset.
+func counterDelta(count uint64, last uint64) (delta uint64, reset bool) {
+	if count >= last {
+		return count - last, false
+	}
+	return count, true
+}
+
+// samplerState is the state of the last sample written. It is persisted as a single value, so that the counter,
+// the packets and the time of a sample are never mixed with the ones of another sample.
+type samplerState struct {
+	Count uint64 `json:"count"`
+	// Packets is nil for samplers of a counter without packets.
+	Packets *uint64 `json:"packets,omitempty"`
+	// Time is the unix nanoseconds of the sample, zero if unknown.
+	Time int64 `json:"time,omitempty"`
+	// Interfaces are the counters of each interface, for samplers of several interfaces.
+	Interfaces map[string]interfaceCounters `json:"interfaces,omitempty"`
+	// Dropped is the usage of the records dropped by a network output since the sample, added to the next one.
+	Dropped uint64 `json:"dropped,omitempty"`
+}
+
+// interfaceCounters are the counters of an interface in a samplerState.
+type interfaceCounters struct {
+	Bytes   uint64 `json:"bytes"`
+	Packets uint64 `json:"packets"`
+}
+
+// since returns the bytes and the packets counted since the last state, whether the packets are known, and
+// whether a counter was reset. With the counters of each interface, an interface added since the last state
+// starts from its current counters and a removed one is left out, so adding or removing a NIC neither counts
+// its whole counter nor looks like a reset.
+func (s samplerState) since(last samplerState) (usage uint64, packets uint64, hasPackets bool, reset bool) {
+	if s.Interfaces != nil && last.Interfaces != nil {
+		for name, counters := range s.Interfaces {
+			lastCounters, ok := last.Interfaces[name]
+			if !ok {
+				continue
+			}
+			bytesDelta, bytesReset := counterDelta(counters.Bytes, lastCounters.Bytes)
+			packetsDelta, _ := counterDelta(counters.Packets, lastCounters.Packets)
+			usage += bytesDelta
+			packets += packetsDelta
+			reset = reset || bytesReset
+		}
+		return usage, packets, true, reset
+	}
+
+	// The first sample of several interfaces, or after an upgrade, compares the sums.
+	usage, reset = counterDelta(s.Count, last.Count)
+	if s.Packets != nil && last.Packets != nil {
+		packets, _ = counterDelta(*s.Packets, *last.Packets)
+		hasPackets = true
+	}
+	return usage, packets, hasPackets, reset
+}
+
+// loadSamplerState returns the persisted state of the last sample, or the zero state before the first one.
+func loadSamplerState(ctx context.Context, persister operator.Persister) (samplerState, error) {
+	var state samplerState
+	byteSlice, err := persister.Get(ctx, SAMPLER_STATE_KEY)
+	if err != nil {
+		return state, fmt.Errorf("load sampler state: %w", err)
+	}
+	if byteSlice != nil {
+		if err := json.Unmarshal(byteSlice, &state); err != nil {
+			return state, fmt.Errorf("load sampler state: %w", err)
+		}
+		return state, nil
+	}
+
+	// Before an upgrade the state was persisted under a key per value.
+	if state.Count, _, err = persistedUint(ctx, persister, LAST_COUNT_KEY); err != nil {
+		return state, err
+	}
+	packets, hasPackets, err := persistedUint(ctx, persister, LAST_PACKET_COUNT_KEY)
+	if err != nil {
+		return state, err
+	}
+	if hasPackets {
+		state.Packets = &packets
+	}
+	sampledAt, _, err := persistedUint(ctx, persister, LAST_SAMPLE_TIME_KEY)
+	if err != nil {
+		return state, err
+	}
+	state.Time = int64(sampledAt)
+	return state, nil
+}
+
+func (s samplerState) save(ctx context.Context, persister operator.Persister) error {
+	byteSlice, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	if err := persister.Set(ctx, SAMPLER_STATE_KEY, byteSlice); err != nil {
+		return fmt.Errorf("persist sampler state: %w", err)
+	}
+	return nil
+}
+
+// persistedUint returns the unsigned integer persisted under key, and whether there is one.
+func persistedUint(ctx context.Context, persister operator.Persister, key string) (uint64, bool, error) {
+	byteSlice, err := persister.Get(ctx, key)
+	if err != nil {
+		return 0, false, fmt.Errorf("load %s: %w", key, err)
+	}
+	if byteSlice == nil {
+		return 0, false, nil
+	}
+	value, err := strconv.ParseUint(string(byteSlice), 10, 64)
+	return value, err == nil, nil
 }
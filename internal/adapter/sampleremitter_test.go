@@ -0,0 +1,365 @@
+package adapter
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/fsgonz/otelnetstatsreceiver/internal/envelope"
+	"github.com/fsgonz/otelnetstatsreceiver/internal/logsampler"
+	"github.com/fsgonz/otelnetstatsreceiver/internal/lumberjack"
+	"github.com/fsgonz/otelnetstatsreceiver/internal/stats/sampler"
+	"github.com/fsgonz/otelnetstatsreceiver/internal/stats/scraper"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/operator"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/testutil"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.uber.org/zap"
+)
+
+func TestSamplerMetadataEnrichment(t *testing.T) {
+	ctx := context.Background()
+	statsSampler := &fakeMetadataSampler{
+		fakeSampler: fakeSampler{values: []uint64{100}, errs: []error{nil}},
+		metadata:    map[string]string{sampler.LinkOperStateKey: "up", sampler.LinkUtilizationKey: "10.00"},
+	}
+
+	logEntry, _, err := logEntry(ctx, testutil.NewUnscopedMockPersister(), statsSampler, nil, false)
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{
+		SCHEMA_ID:                  NETWORK_SCHEMA_ID,
+		sampler.LinkOperStateKey:   "up",
+		sampler.LinkUtilizationKey: "10.00",
+	}, logEntry.Metadata)
+
+	output := testutil.NewFakeOutput(t)
+	pipe, err := buildSamplerPipeline(componenttest.NewNopTelemetrySettings(), nil, output)
+	require.NoError(t, err)
+	input, err := findInput(pipe, samplerInputType)
+	require.NoError(t, err)
+	require.NoError(t, pipe.Start(testutil.NewUnscopedMockPersister()))
+	defer func() {
+		require.NoError(t, pipe.Stop())
+	}()
+
+	emitter := &PipelineConsumerSamplerEmitter{input: input, structured: true}
+	require.NoError(t, emitter.emitStructured(ctx, logEntry))
+
+	got := <-output.Received
+	require.Equal(t, "up", got.Attributes[sampler.LinkOperStateKey])
+	require.Equal(t, "10.00", got.Attributes[sampler.LinkUtilizationKey])
+	require.NotContains(t, got.Attributes, sampler.LinkSpeedKey)
+}
+
+func TestEmitStructured(t *testing.T) {
+	output := testutil.NewFakeOutput(t)
+	pipe, err := buildSamplerPipeline(componenttest.NewNopTelemetrySettings(), nil, output)
+	require.NoError(t, err)
+	input, err := findInput(pipe, samplerInputType)
+	require.NoError(t, err)
+	require.NoError(t, pipe.Start(testutil.NewUnscopedMockPersister()))
+	defer func() {
+		require.NoError(t, pipe.Stop())
+	}()
+
+	logEntry := testNetworkIOLogEntry()
+	rate := 12.5
+	logEntry.Events[0].BytesPerSecond = &rate
+	second := logEntry.Events[0]
+	second.WorkerID = "worker-1"
+	second.UsageBytes = 2048
+	logEntry.Events = append(logEntry.Events, second)
+
+	emitter := &PipelineConsumerSamplerEmitter{input: input, structured: true, hostname: "host-a"}
+	require.NoError(t, emitter.emitStructured(context.Background(), logEntry))
+
+	for _, evt := range logEntry.Events {
+		got := <-output.Received
+		require.Equal(t, map[string]any{
+			"format":           FORMAT,
+			"schema_id":        NETWORK_SCHEMA_ID,
+			"id":               evt.ID,
+			"timestamp":        evt.Timestamp,
+			"root_org_id":      "root",
+			"org_id":           "org",
+			"env_id":           "env",
+			"asset_id":         "asset",
+			"worker_id":        evt.WorkerID,
+			"usage_bytes":      evt.UsageBytes,
+			"billable":         true,
+			"bytes_per_second": rate,
+		}, got.Body)
+		require.Equal(t, time.UnixMilli(evt.Timestamp), got.Timestamp)
+		require.Equal(t, map[string]any{
+			SCHEMA_ID:          NETWORK_SCHEMA_ID,
+			"usage_bytes":      evt.UsageBytes,
+			"billable":         true,
+			"bytes_per_second": rate,
+		}, got.Attributes)
+		require.Equal(t, map[string]any{
+			HOST_NAME_RESOURCE: "host-a",
+			WORKER_ID_RESOURCE: evt.WorkerID,
+		}, got.Resource)
+	}
+}
+
+// fakeMetadataSampler is a fakeSampler describing its samples with fixed metadata.
+type fakeMetadataSampler struct {
+	fakeSampler
+	metadata map[string]string
+}
+
+func (s *fakeMetadataSampler) Metadata() map[string]string {
+	return s.metadata
+}
+
+func TestPhysicalOnlySampler(t *testing.T) {
+	cfg := logsampler.LogSampler{Source: SYSFS_SOURCE, SysfsRoot: "../stats/scraper/testdata/sys", PhysicalOnly: true}
+
+	got, err := networkSampler(cfg, zap.NewNop()).Sample()
+	require.NoError(t, err)
+	require.Equal(t, uint64(3862937603+281882792+1000+2000), got, "only eth0 and eth1 are physical")
+}
+
+func TestEncryptionWiring(t *testing.T) {
+	dir := t.TempDir()
+	keyFile := filepath.Join(dir, "encryption.keys")
+	require.NoError(t, os.WriteFile(keyFile, []byte("k1 MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY=\n"), 0600))
+	keyring, err := envelope.LoadKeyring(keyFile)
+	require.NoError(t, err)
+
+	writer := func(t *testing.T, cfg logsampler.LogSampler) *lumberjack.Logger {
+		emitter, err := SamplerEmitterFactory(cfg, testutil.NewUnscopedMockPersister(), nil, nil, nil, nil, zap.NewNop())
+		require.NoError(t, err)
+		writer := emitter.(*FileLoggerSamplerEmitter).metricsLogger.Writer().(*lumberjack.Logger)
+		t.Cleanup(func() { writer.Close() })
+		return writer
+	}
+
+	t.Run("records", func(t *testing.T) {
+		uri := filepath.Join(dir, "records.log")
+		w := writer(t, logsampler.LogSampler{Output: FILE_LOGGER_OUTPUT, URI: uri, Encoding: CSV_ENCODING, Encryption: logsampler.EncryptionConfig{KeyFile: keyFile}})
+		require.NotNil(t, w.EncryptRecord)
+		require.Nil(t, w.EncryptFile)
+
+		_, err := w.Write([]byte("record\n"))
+		require.NoError(t, err)
+		content, err := os.ReadFile(uri)
+		require.NoError(t, err)
+		require.NotContains(t, string(content), "record")
+		var plain bytes.Buffer
+		require.NoError(t, keyring.Decrypt(&plain, bytes.NewReader(content), false))
+		require.Equal(t, string(csvEncoder{}.Header())+"record\n", plain.String(), "the header is encrypted too")
+	})
+
+	t.Run("files", func(t *testing.T) {
+		w := writer(t, logsampler.LogSampler{Output: FILE_LOGGER_OUTPUT, URI: filepath.Join(dir, "files.log"), Encryption: logsampler.EncryptionConfig{KeyFile: keyFile, Mode: FILES_ENCRYPTION}})
+		require.NotNil(t, w.EncryptFile)
+		require.Nil(t, w.EncryptRecord)
+	})
+
+	t.Run("missing key file", func(t *testing.T) {
+		cfg := logsampler.LogSampler{Output: FILE_LOGGER_OUTPUT, URI: filepath.Join(dir, "missing.log"), Encryption: logsampler.EncryptionConfig{KeyFile: filepath.Join(dir, "missing.keys")}}
+		_, err := SamplerEmitterFactory(cfg, testutil.NewUnscopedMockPersister(), nil, nil, nil, nil, zap.NewNop())
+		require.Error(t, err)
+	})
+}
+
+func TestRateMode(t *testing.T) {
+	ctx := context.Background()
+	persister := testutil.NewUnscopedMockPersister()
+	statsSampler := &fakeStatsSampler{stats: []scraper.NetworkStats{
+		{ReceivedBytes: 600, TransmittedBytes: 400, ReceivedPackets: 6, TransmittedPackets: 4},
+		{ReceivedBytes: 2600, TransmittedBytes: 2400, ReceivedPackets: 26, TransmittedPackets: 24},
+	}}
+
+	first, commit, err := logEntry(ctx, persister, statsSampler, nil, true)
+	require.NoError(t, err)
+	require.NoError(t, commit(ctx))
+	require.Nil(t, first.Events[0].BytesPerSecond, "the first sample has no rate")
+	require.Nil(t, first.Events[0].PacketsPerSecond)
+
+	// The elapsed time is the one persisted with the counter, not the poll interval, so a missed tick
+	// spreads the usage over both intervals.
+	state, err := loadSamplerState(ctx, persister)
+	require.NoError(t, err)
+	state.Time = time.Now().Add(-4 * time.Second).UnixNano()
+	require.NoError(t, state.save(ctx, persister))
+
+	second, commit, err := logEntry(ctx, persister, statsSampler, nil, true)
+	require.NoError(t, err)
+	require.NoError(t, commit(ctx))
+	require.Equal(t, uint64(4000), second.Events[0].UsageBytes)
+	require.InDelta(t, 1000, *second.Events[0].BytesPerSecond, 10)
+	require.InDelta(t, 10, *second.Events[0].PacketsPerSecond, 0.1)
+
+	state, err = loadSamplerState(ctx, persister)
+	require.NoError(t, err)
+	require.Equal(t, uint64(5000), state.Count)
+	require.Equal(t, uint64(50), *state.Packets)
+}
+
+func TestLegacySamplerState(t *testing.T) {
+	ctx := context.Background()
+	persister := testutil.NewUnscopedMockPersister()
+	lastTime := time.Now().Add(-4 * time.Second)
+	require.NoError(t, persister.Set(ctx, LAST_COUNT_KEY, []byte("1000")))
+	require.NoError(t, persister.Set(ctx, LAST_PACKET_COUNT_KEY, []byte("10")))
+	require.NoError(t, persister.Set(ctx, LAST_SAMPLE_TIME_KEY, []byte(strconv.FormatInt(lastTime.UnixNano(), 10))))
+	statsSampler := &fakeStatsSampler{stats: []scraper.NetworkStats{{ReceivedBytes: 5000, ReceivedPackets: 50}}}
+
+	// The state persisted before the upgrade is continued.
+	logEntry, commit, err := logEntry(ctx, persister, statsSampler, nil, true)
+	require.NoError(t, err)
+	require.NoError(t, commit(ctx))
+	require.Equal(t, uint64(4000), logEntry.Events[0].UsageBytes)
+	require.InDelta(t, 1000, *logEntry.Events[0].BytesPerSecond, 10)
+	require.InDelta(t, 10, *logEntry.Events[0].PacketsPerSecond, 0.1)
+
+	state, err := loadSamplerState(ctx, persister)
+	require.NoError(t, err)
+	require.Equal(t, uint64(5000), state.Count)
+}
+
+func TestDeltaModeHasNoRates(t *testing.T) {
+	ctx := context.Background()
+	persister := testutil.NewUnscopedMockPersister()
+	statsSampler := &fakeStatsSampler{stats: []scraper.NetworkStats{{ReceivedBytes: 1}, {ReceivedBytes: 2}}}
+
+	for i := 0; i < 2; i++ {
+		logEntry, commit, err := logEntry(ctx, persister, statsSampler, nil, false)
+		require.NoError(t, err)
+		require.NoError(t, commit(ctx))
+		require.Nil(t, logEntry.Events[0].BytesPerSecond)
+		require.Nil(t, logEntry.Events[0].PacketsPerSecond)
+	}
+}
+
+func TestFailedWriteKeepsUsage(t *testing.T) {
+	ctx := context.Background()
+	persister := testutil.NewUnscopedMockPersister()
+	writer := &failingWriter{err: errors.New("disk full")}
+	encoder, err := RecordEncoderFactory("")
+	require.NoError(t, err)
+	emitter := &FileLoggerSamplerEmitter{
+		metricsLogger: log.New(writer, "", 0),
+		persister:     persister,
+		sampler:       &fakeSampler{values: []uint64{100, 150}, errs: []error{nil, nil}},
+		encoder:       encoder,
+	}
+
+	require.ErrorIs(t, emitter.Emit(ctx), writer.err)
+	state, err := persister.Get(ctx, SAMPLER_STATE_KEY)
+	require.NoError(t, err)
+	require.Nil(t, state, "the counter of a sample whose record is not written is not persisted")
+
+	writer.err = nil
+	require.NoError(t, emitter.Emit(ctx))
+	require.Contains(t, writer.written.String(), `"usage_bytes":150`, "the next sample includes the usage of the failed one")
+	last, err := loadSamplerState(ctx, persister)
+	require.NoError(t, err)
+	require.Equal(t, uint64(150), last.Count)
+}
+
+func TestPersisterErrorsAreReturned(t *testing.T) {
+	ctx := context.Background()
+	persister := &failingPersister{Persister: testutil.NewUnscopedMockPersister(), err: errors.New("storage closed")}
+
+	_, _, err := logEntry(ctx, persister, &fakeSampler{values: []uint64{100}, errs: []error{nil}}, nil, false)
+	require.ErrorIs(t, err, persister.err)
+}
+
+// failingWriter fails the writes while err is set.
+type failingWriter struct {
+	err     error
+	written bytes.Buffer
+}
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+	return w.written.Write(p)
+}
+
+// failingPersister fails the reads with err.
+type failingPersister struct {
+	operator.Persister
+	err error
+}
+
+func (p *failingPersister) Get(context.Context, string) ([]byte, error) {
+	return nil, p.err
+}
+
+func TestInterfacesUsage(t *testing.T) {
+	ctx := context.Background()
+	persister := testutil.NewUnscopedMockPersister()
+	statsSampler := &fakeInterfacesSampler{interfaces: []map[string]scraper.NetworkStats{
+		{"eth0": {ReceivedBytes: 100, ReceivedPackets: 1}},
+		{"eth0": {ReceivedBytes: 150, ReceivedPackets: 2}, "eth1": {ReceivedBytes: 1000, ReceivedPackets: 10}},
+		{"eth1": {ReceivedBytes: 1100, ReceivedPackets: 12}},
+		{"eth1": {ReceivedBytes: 20, ReceivedPackets: 1}},
+	}}
+	// The rules keep sampling each interface.
+	rules := newRuleSampler(statsSampler, []logsampler.Rule{{Metric: "bytes", Operator: ">", Threshold: 0}})
+
+	var usage []uint64
+	for i := 0; i < 4; i++ {
+		logEntry, commit, err := logEntry(ctx, persister, interfacesRuleSampler{rules}, nil, false)
+		require.NoError(t, err)
+		require.NoError(t, commit(ctx))
+		usage = append(usage, logEntry.Events[0].UsageBytes)
+	}
+	// An added NIC starts from its current counter and a removed one is left out, only a counter going back is
+	// a reset.
+	require.Equal(t, []uint64{100, 50, 100, 20}, usage)
+	require.NotEmpty(t, rules.drain(), "the rules are evaluated on the sum of the interfaces")
+
+	state, err := loadSamplerState(ctx, persister)
+	require.NoError(t, err)
+	require.Equal(t, map[string]interfaceCounters{"eth1": {Bytes: 20, Packets: 1}}, state.Interfaces)
+}
+
+// fakeInterfacesSampler returns the statistics of its interfaces in order.
+type fakeInterfacesSampler struct {
+	interfaces []map[string]scraper.NetworkStats
+	calls      int
+}
+
+func (s *fakeInterfacesSampler) Sample() (uint64, error) {
+	stats, err := s.SampleStats()
+	return stats.ReceivedBytes + stats.TransmittedBytes, err
+}
+
+func (s *fakeInterfacesSampler) SampleStats() (scraper.NetworkStats, error) {
+	interfaces, err := s.SampleInterfaces()
+	return sampler.SumStats(interfaces), err
+}
+
+func (s *fakeInterfacesSampler) SampleInterfaces() (map[string]scraper.NetworkStats, error) {
+	defer func() { s.calls++ }()
+	return s.interfaces[s.calls], nil
+}
+
+// fakeStatsSampler returns its statistics in order.
+type fakeStatsSampler struct {
+	stats []scraper.NetworkStats
+	calls int
+}
+
+func (s *fakeStatsSampler) Sample() (uint64, error) {
+	stats, err := s.SampleStats()
+	return stats.ReceivedBytes + stats.TransmittedBytes, err
+}
+
+func (s *fakeStatsSampler) SampleStats() (scraper.NetworkStats, error) {
+	defer func() { s.calls++ }()
+	return s.stats[s.calls], nil
+}
@@ -0,0 +1,437 @@
+package adapter
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+)
+
+const (
+	V1_ENCODING        = "v1"
+	JSON_ENCODING      = "json"
+	CSV_ENCODING       = "csv"
+	LOGFMT_ENCODING    = "logfmt"
+	OTLP_JSON_ENCODING = "otlp_json"
+)
+
+// RecordEncoder turns a sampled network usage entry into the records written by a sampler output.
+type RecordEncoder interface {
+	// Header returns the bytes written at the start of every output file, or nil if the encoding has none.
+	Header() []byte
+	// Encode returns the records for the entry. Each record is written as a single line.
+	Encode(logEntry networkIOLogEntry) ([][]byte, error)
+}
+
+type encoderOption func(*encoderOptions)
+
+type encoderOptions struct {
+	rates      bool
+	aggregates bool
+	metadata   bool
+}
+
+// withRates adds the rate columns to the csv encoding, whose header lists the columns of every row.
+func withRates() encoderOption {
+	return func(opts *encoderOptions) {
+		opts.rates = true
+	}
+}
+
+// withAggregates adds the rate and the aggregate columns to the csv encoding.
+func withAggregates() encoderOption {
+	return func(opts *encoderOptions) {
+		opts.rates = true
+		opts.aggregates = true
+	}
+}
+
+// withMetadata adds the metadata column to the csv encoding, for the samplers describing their samples.
+func withMetadata() encoderOption {
+	return func(opts *encoderOptions) {
+		opts.metadata = true
+	}
+}
+
+// RecordEncoderFactory returns the RecordEncoder for the given encoding name. An empty name selects the v1 encoding.
+func RecordEncoderFactory(encoding string, opts ...encoderOption) (RecordEncoder, error) {
+	options := encoderOptions{}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	switch encoding {
+	case "", V1_ENCODING:
+		return v1Encoder{}, nil
+	case JSON_ENCODING:
+		return jsonEncoder{}, nil
+	case CSV_ENCODING:
+		return csvEncoder{rates: options.rates, aggregates: options.aggregates, metadata: options.metadata}, nil
+	case LOGFMT_ENCODING:
+		return logfmtEncoder{}, nil
+	case OTLP_JSON_ENCODING:
+		return otlpJSONEncoder{}, nil
+	default:
+		return nil, fmt.Errorf("unknown encoding: %s", encoding)
+	}
+}
+
+// flatNetworkIOLogEntry is a single event merged with the fields of its envelope.
+type flatNetworkIOLogEntry struct {
+	Format   string `json:"format"`
+	SchemaID string `json:"schema_id"`
+	networkIOLogEntryEvent
+	// Metadata is the metadata of the envelope but the schema id, such as the link of the sysfs source.
+	Metadata map[string]string `json:"-"`
+}
+
+// MarshalJSON writes the metadata as fields following the ones of the event.
+func (f flatNetworkIOLogEntry) MarshalJSON() ([]byte, error) {
+	type fields flatNetworkIOLogEntry
+	record, err := json.Marshal(fields(f))
+	if err != nil || len(f.Metadata) == 0 {
+		return record, err
+	}
+	metadata, err := json.Marshal(f.Metadata)
+	if err != nil {
+		return nil, err
+	}
+	// Both are JSON objects, the closing brace of the event is replaced by the metadata fields.
+	return append(append(record[:len(record)-1], ','), metadata[1:]...), nil
+}
+
+// metadataKeys returns the keys of the metadata, sorted.
+func (f flatNetworkIOLogEntry) metadataKeys() []string {
+	keys := make([]string, 0, len(f.Metadata))
+	for key := range f.Metadata {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// flatFieldNames lists the columns of the flat encodings in output order.
+var flatFieldNames = []string{
+	"format", "schema_id", "id", "timestamp", "root_org_id", "org_id", "env_id", "asset_id", "worker_id", "usage_bytes", "billable",
+}
+
+func flatten(logEntry networkIOLogEntry) []flatNetworkIOLogEntry {
+	var metadata map[string]string
+	for key, value := range logEntry.Metadata {
+		if key == SCHEMA_ID {
+			continue
+		}
+		if metadata == nil {
+			metadata = make(map[string]string, len(logEntry.Metadata))
+		}
+		metadata[key] = value
+	}
+
+	flat := make([]flatNetworkIOLogEntry, 0, len(logEntry.Events))
+	for _, evt := range logEntry.Events {
+		flat = append(flat, flatNetworkIOLogEntry{
+			Format:                 logEntry.Format,
+			SchemaID:               logEntry.Metadata[SCHEMA_ID],
+			networkIOLogEntryEvent: evt,
+			Metadata:               metadata,
+		})
+	}
+	return flat
+}
+
+// metadataFieldName is the csv column holding the metadata as a JSON object.
+const metadataFieldName = "metadata"
+
+// rateFieldNames lists the columns following flatFieldNames in rate mode.
+var rateFieldNames = []string{"bytes_per_second", "packets_per_second"}
+
+// rateValues returns the rates of the entry in the order of rateFieldNames, empty if unknown.
+func (f flatNetworkIOLogEntry) rateValues() []string {
+	values := make([]string, 0, len(rateFieldNames))
+	for _, rate := range []*float64{f.BytesPerSecond, f.PacketsPerSecond} {
+		if rate == nil {
+			values = append(values, "")
+			continue
+		}
+		values = append(values, strconv.FormatFloat(*rate, 'f', -1, 64))
+	}
+	return values
+}
+
+// aggregateFieldNames lists the columns following rateFieldNames with aggregation, the rates then being the means.
+var aggregateFieldNames = []string{
+	"window_start", "window_end", "samples",
+	"bytes_per_second_min", "bytes_per_second_max", "bytes_per_second_p95",
+	"packets_per_second_min", "packets_per_second_max", "packets_per_second_p95",
+}
+
+// aggregateValues returns the aggregate of the entry with its native types in the order of aggregateFieldNames,
+// nil for the rates that are unknown, or nil if the entry is not aggregated.
+func (f flatNetworkIOLogEntry) aggregateValues() []any {
+	if f.Aggregate == nil {
+		return nil
+	}
+	values := []any{f.Aggregate.WindowStart, f.Aggregate.WindowEnd, f.Aggregate.Samples}
+	for _, rates := range []*rateAggregate{f.Aggregate.BytesPerSecond, f.Aggregate.PacketsPerSecond} {
+		if rates == nil {
+			values = append(values, nil, nil, nil)
+			continue
+		}
+		values = append(values, rates.Min, rates.Max, rates.P95)
+	}
+	return values
+}
+
+// aggregateStrings returns the aggregateValues as strings, empty when unknown.
+func (f flatNetworkIOLogEntry) aggregateStrings() []string {
+	values := f.aggregateValues()
+	if values == nil {
+		return make([]string, len(aggregateFieldNames))
+	}
+	strs := make([]string, 0, len(values))
+	for _, value := range values {
+		switch v := value.(type) {
+		case float64:
+			strs = append(strs, strconv.FormatFloat(v, 'f', -1, 64))
+		case nil:
+			strs = append(strs, "")
+		default:
+			strs = append(strs, fmt.Sprint(v))
+		}
+	}
+	return strs
+}
+
+// values returns the field values of the entry in the order of flatFieldNames.
+func (f flatNetworkIOLogEntry) values() []string {
+	return []string{
+		f.Format,
+		f.SchemaID,
+		f.ID,
+		strconv.FormatInt(f.Timestamp, 10),
+		f.RootOrgID,
+		f.OrgID,
+		f.EnvID,
+		f.AssetID,
+		f.WorkerID,
+		strconv.FormatUint(f.UsageBytes, 10),
+		strconv.FormatBool(f.Billable),
+	}
+}
+
+// asMap returns the event fields, keyed by their flat field name, with their native types.
+func (f flatNetworkIOLogEntry) asMap() map[string]any {
+	m := map[string]any{
+		"format":      f.Format,
+		"schema_id":   f.SchemaID,
+		"id":          f.ID,
+		"timestamp":   f.Timestamp,
+		"root_org_id": f.RootOrgID,
+		"org_id":      f.OrgID,
+		"env_id":      f.EnvID,
+		"asset_id":    f.AssetID,
+		"worker_id":   f.WorkerID,
+		"usage_bytes": f.UsageBytes,
+		"billable":    f.Billable,
+	}
+	if f.BytesPerSecond != nil {
+		m["bytes_per_second"] = *f.BytesPerSecond
+	}
+	if f.PacketsPerSecond != nil {
+		m["packets_per_second"] = *f.PacketsPerSecond
+	}
+	for i, value := range f.aggregateValues() {
+		if value != nil {
+			m[aggregateFieldNames[i]] = value
+		}
+	}
+	return m
+}
+
+// v1Encoder writes the whole entry as the versioned JSON envelope.
+type v1Encoder struct{}
+
+func (v1Encoder) Header() []byte {
+	return nil
+}
+
+func (v1Encoder) Encode(logEntry networkIOLogEntry) ([][]byte, error) {
+	record, err := json.Marshal(logEntry)
+	if err != nil {
+		return nil, err
+	}
+	return [][]byte{record}, nil
+}
+
+// jsonEncoder writes one flat JSON object per event.
+type jsonEncoder struct{}
+
+func (jsonEncoder) Header() []byte {
+	return nil
+}
+
+func (jsonEncoder) Encode(logEntry networkIOLogEntry) ([][]byte, error) {
+	var records [][]byte
+	for _, flat := range flatten(logEntry) {
+		record, err := json.Marshal(flat)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// csvEncoder writes one CSV row per event. The header row is only written at the start of output files.
+type csvEncoder struct {
+	// rates appends the rate columns, empty when unknown, to every row.
+	rates bool
+	// aggregates appends the aggregate columns after the rate ones.
+	aggregates bool
+	// metadata appends the metadata column, whose keys depend on the sampled interfaces, as a JSON object.
+	metadata bool
+}
+
+func (e csvEncoder) Header() []byte {
+	names := flatFieldNames
+	if e.rates {
+		names = append(append([]string{}, flatFieldNames...), rateFieldNames...)
+	}
+	if e.aggregates {
+		names = append(names, aggregateFieldNames...)
+	}
+	if e.metadata {
+		names = append(append([]string{}, names...), metadataFieldName)
+	}
+	header, _ := csvLine(names)
+	return append(header, '\n')
+}
+
+func (e csvEncoder) Encode(logEntry networkIOLogEntry) ([][]byte, error) {
+	var records [][]byte
+	for _, flat := range flatten(logEntry) {
+		values := flat.values()
+		if e.rates {
+			values = append(values, flat.rateValues()...)
+		}
+		if e.aggregates {
+			values = append(values, flat.aggregateStrings()...)
+		}
+		if e.metadata {
+			metadata := ""
+			if len(flat.Metadata) > 0 {
+				encoded, err := json.Marshal(flat.Metadata)
+				if err != nil {
+					return nil, err
+				}
+				metadata = string(encoded)
+			}
+			values = append(values, metadata)
+		}
+		record, err := csvLine(values)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+func csvLine(fields []string) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	if err := w.Write(fields); err != nil {
+		return nil, err
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// logfmtEncoder writes one line of key=value pairs per event.
+type logfmtEncoder struct{}
+
+func (logfmtEncoder) Header() []byte {
+	return nil
+}
+
+func (logfmtEncoder) Encode(logEntry networkIOLogEntry) ([][]byte, error) {
+	var records [][]byte
+	for _, flat := range flatten(logEntry) {
+		var sb strings.Builder
+		for i, value := range flat.values() {
+			if i > 0 {
+				sb.WriteByte(' ')
+			}
+			sb.WriteString(flatFieldNames[i])
+			sb.WriteByte('=')
+			sb.WriteString(logfmtValue(value))
+		}
+		for i, value := range flat.rateValues() {
+			if value != "" {
+				sb.WriteString(" " + rateFieldNames[i] + "=" + value)
+			}
+		}
+		if flat.Aggregate != nil {
+			for i, value := range flat.aggregateStrings() {
+				if value != "" {
+					sb.WriteString(" " + aggregateFieldNames[i] + "=" + value)
+				}
+			}
+		}
+		for _, key := range flat.metadataKeys() {
+			sb.WriteString(" " + key + "=" + logfmtValue(flat.Metadata[key]))
+		}
+		records = append(records, []byte(sb.String()))
+	}
+	return records, nil
+}
+
+func logfmtValue(value string) string {
+	if value == "" || strings.ContainsAny(value, " =\"\t\r\n") {
+		return strconv.Quote(value)
+	}
+	return value
+}
+
+// otlpJSONEncoder writes the entry as an OTLP-JSON logs request with one log record per event.
+type otlpJSONEncoder struct{}
+
+func (otlpJSONEncoder) Header() []byte {
+	return nil
+}
+
+func (otlpJSONEncoder) Encode(logEntry networkIOLogEntry) ([][]byte, error) {
+	logs := plog.NewLogs()
+	records := logs.ResourceLogs().AppendEmpty().ScopeLogs().AppendEmpty().LogRecords()
+	for _, flat := range flatten(logEntry) {
+		record := records.AppendEmpty()
+		record.SetTimestamp(pcommon.NewTimestampFromTime(time.UnixMilli(flat.Timestamp)))
+		record.SetObservedTimestamp(pcommon.NewTimestampFromTime(time.UnixMilli(logEntry.Time)))
+		body := flat.asMap()
+		delete(body, "format")
+		delete(body, SCHEMA_ID)
+		record.Attributes().PutStr("format", flat.Format)
+		record.Attributes().PutStr(SCHEMA_ID, flat.SchemaID)
+		for key, value := range flat.Metadata {
+			record.Attributes().PutStr(key, value)
+		}
+		upsertToMap(body, record.Body().SetEmptyMap())
+	}
+
+	marshaler := plog.JSONMarshaler{}
+	record, err := marshaler.MarshalLogs(logs)
+	if err != nil {
+		return nil, err
+	}
+	return [][]byte{record}, nil
+}
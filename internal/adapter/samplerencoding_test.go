@@ -0,0 +1,232 @@
+package adapter
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/plog"
+)
+
+func testNetworkIOLogEntry() networkIOLogEntry {
+	return networkIOLogEntry{
+		Format: FORMAT,
+		Time:   1717000000000,
+		Events: []networkIOLogEntryEvent{{
+			ID:         "0b0e5c1a-2f0e-4a5e-9f43-3f4b5a6c7d8e",
+			Timestamp:  1717000000000,
+			RootOrgID:  "root",
+			OrgID:      "org",
+			EnvID:      "env",
+			AssetID:    "asset",
+			WorkerID:   "worker-0",
+			UsageBytes: 1024,
+			Billable:   true,
+		}},
+		Metadata: map[string]string{SCHEMA_ID: NETWORK_SCHEMA_ID},
+	}
+}
+
+func TestRecordEncoders(t *testing.T) {
+	t.Run("v1 is the default encoding", func(t *testing.T) {
+		encoder, err := RecordEncoderFactory("")
+		require.NoError(t, err)
+		require.Nil(t, encoder.Header())
+
+		records, err := encoder.Encode(testNetworkIOLogEntry())
+		require.NoError(t, err)
+		require.Len(t, records, 1)
+
+		var decoded networkIOLogEntry
+		require.NoError(t, json.Unmarshal(records[0], &decoded))
+		require.Equal(t, testNetworkIOLogEntry(), decoded)
+	})
+
+	t.Run("json flattens the envelope into each event", func(t *testing.T) {
+		encoder, err := RecordEncoderFactory(JSON_ENCODING)
+		require.NoError(t, err)
+
+		records, err := encoder.Encode(testNetworkIOLogEntry())
+		require.NoError(t, err)
+		require.JSONEq(t, `{"format":"v1","schema_id":"network_schema_id","id":"0b0e5c1a-2f0e-4a5e-9f43-3f4b5a6c7d8e",`+
+			`"timestamp":1717000000000,"root_org_id":"root","org_id":"org","env_id":"env","asset_id":"asset",`+
+			`"worker_id":"worker-0","usage_bytes":1024,"billable":true}`, string(records[0]))
+	})
+
+	t.Run("csv writes a header and one row per event", func(t *testing.T) {
+		encoder, err := RecordEncoderFactory(CSV_ENCODING)
+		require.NoError(t, err)
+		require.Equal(t, "format,schema_id,id,timestamp,root_org_id,org_id,env_id,asset_id,worker_id,usage_bytes,billable\n", string(encoder.Header()))
+
+		records, err := encoder.Encode(testNetworkIOLogEntry())
+		require.NoError(t, err)
+		require.Equal(t, "v1,network_schema_id,0b0e5c1a-2f0e-4a5e-9f43-3f4b5a6c7d8e,1717000000000,root,org,env,asset,worker-0,1024,true", string(records[0]))
+	})
+
+	t.Run("csv appends the rate columns in rate mode", func(t *testing.T) {
+		encoder, err := RecordEncoderFactory(CSV_ENCODING, withRates())
+		require.NoError(t, err)
+		require.Equal(t, "format,schema_id,id,timestamp,root_org_id,org_id,env_id,asset_id,worker_id,usage_bytes,billable,"+
+			"bytes_per_second,packets_per_second\n", string(encoder.Header()))
+
+		entry := testNetworkIOLogEntry()
+		bytesPerSecond := 17.5
+		entry.Events[0].BytesPerSecond = &bytesPerSecond
+		records, err := encoder.Encode(entry)
+		require.NoError(t, err)
+		require.Equal(t, "v1,network_schema_id,0b0e5c1a-2f0e-4a5e-9f43-3f4b5a6c7d8e,1717000000000,root,org,env,asset,worker-0,1024,true,17.5,", string(records[0]))
+	})
+
+	t.Run("logfmt and json add the known rates", func(t *testing.T) {
+		entry := testNetworkIOLogEntry()
+		bytesPerSecond, packetsPerSecond := 17.5, 0.25
+		entry.Events[0].BytesPerSecond = &bytesPerSecond
+		entry.Events[0].PacketsPerSecond = &packetsPerSecond
+
+		encoder, err := RecordEncoderFactory(LOGFMT_ENCODING)
+		require.NoError(t, err)
+		records, err := encoder.Encode(entry)
+		require.NoError(t, err)
+		require.Contains(t, string(records[0]), "billable=true bytes_per_second=17.5 packets_per_second=0.25")
+
+		encoder, err = RecordEncoderFactory(JSON_ENCODING)
+		require.NoError(t, err)
+		records, err = encoder.Encode(entry)
+		require.NoError(t, err)
+		require.Contains(t, string(records[0]), `"bytes_per_second":17.5,"packets_per_second":0.25`)
+	})
+
+	t.Run("logfmt quotes empty values", func(t *testing.T) {
+		encoder, err := RecordEncoderFactory(LOGFMT_ENCODING)
+		require.NoError(t, err)
+
+		entry := testNetworkIOLogEntry()
+		entry.Events[0].OrgID = ""
+		records, err := encoder.Encode(entry)
+		require.NoError(t, err)
+		require.Equal(t, `format=v1 schema_id=network_schema_id id=0b0e5c1a-2f0e-4a5e-9f43-3f4b5a6c7d8e timestamp=1717000000000 `+
+			`root_org_id=root org_id="" env_id=env asset_id=asset worker_id=worker-0 usage_bytes=1024 billable=true`, string(records[0]))
+	})
+
+	t.Run("otlp_json round trips through the pdata unmarshaler", func(t *testing.T) {
+		encoder, err := RecordEncoderFactory(OTLP_JSON_ENCODING)
+		require.NoError(t, err)
+
+		records, err := encoder.Encode(testNetworkIOLogEntry())
+		require.NoError(t, err)
+		require.Len(t, records, 1)
+
+		unmarshaler := plog.JSONUnmarshaler{}
+		logs, err := unmarshaler.UnmarshalLogs(records[0])
+		require.NoError(t, err)
+		require.Equal(t, 1, logs.LogRecordCount())
+
+		record := logs.ResourceLogs().At(0).ScopeLogs().At(0).LogRecords().At(0)
+		usage, ok := record.Body().Map().Get("usage_bytes")
+		require.True(t, ok)
+		require.Equal(t, int64(1024), usage.Int())
+	})
+
+	t.Run("the flat encodings keep the metadata", func(t *testing.T) {
+		entry := testNetworkIOLogEntry()
+		entry.Metadata["link.operstate"] = "up"
+		entry.Metadata["eth0.link.speed_mbps"] = "10000"
+
+		encoder, err := RecordEncoderFactory(JSON_ENCODING)
+		require.NoError(t, err)
+		records, err := encoder.Encode(entry)
+		require.NoError(t, err)
+		require.JSONEq(t, `{"format":"v1","schema_id":"network_schema_id","id":"0b0e5c1a-2f0e-4a5e-9f43-3f4b5a6c7d8e",`+
+			`"timestamp":1717000000000,"root_org_id":"root","org_id":"org","env_id":"env","asset_id":"asset",`+
+			`"worker_id":"worker-0","usage_bytes":1024,"billable":true,"eth0.link.speed_mbps":"10000","link.operstate":"up"}`, string(records[0]))
+
+		encoder, err = RecordEncoderFactory(LOGFMT_ENCODING)
+		require.NoError(t, err)
+		records, err = encoder.Encode(entry)
+		require.NoError(t, err)
+		require.Contains(t, string(records[0]), "billable=true eth0.link.speed_mbps=10000 link.operstate=up")
+
+		encoder, err = RecordEncoderFactory(CSV_ENCODING, withMetadata())
+		require.NoError(t, err)
+		require.Equal(t, "format,schema_id,id,timestamp,root_org_id,org_id,env_id,asset_id,worker_id,usage_bytes,billable,metadata\n", string(encoder.Header()))
+		records, err = encoder.Encode(entry)
+		require.NoError(t, err)
+		require.Equal(t, `v1,network_schema_id,0b0e5c1a-2f0e-4a5e-9f43-3f4b5a6c7d8e,1717000000000,root,org,env,asset,worker-0,1024,true,`+
+			`"{""eth0.link.speed_mbps"":""10000"",""link.operstate"":""up""}"`, string(records[0]))
+
+		encoder, err = RecordEncoderFactory(OTLP_JSON_ENCODING)
+		require.NoError(t, err)
+		records, err = encoder.Encode(entry)
+		require.NoError(t, err)
+		unmarshaler := plog.JSONUnmarshaler{}
+		logs, err := unmarshaler.UnmarshalLogs(records[0])
+		require.NoError(t, err)
+		require.Equal(t, map[string]any{
+			"format":               "v1",
+			SCHEMA_ID:              NETWORK_SCHEMA_ID,
+			"link.operstate":       "up",
+			"eth0.link.speed_mbps": "10000",
+		}, logs.ResourceLogs().At(0).ScopeLogs().At(0).LogRecords().At(0).Attributes().AsRaw())
+	})
+
+	t.Run("unknown encodings are rejected", func(t *testing.T) {
+		_, err := RecordEncoderFactory("xml")
+		require.EqualError(t, err, "unknown encoding: xml")
+	})
+}
+
+func TestAggregateEncoding(t *testing.T) {
+	entry := testNetworkIOLogEntry()
+	mean := 20.0
+	entry.Events[0].BytesPerSecond = &mean
+	entry.Events[0].Aggregate = &networkIOAggregate{
+		WindowStart:    1717000000000,
+		WindowEnd:      1717003600000,
+		Samples:        360,
+		BytesPerSecond: &rateAggregate{Min: 10, Max: 30, Mean: 20, P95: 29.5},
+	}
+
+	t.Run("csv appends the aggregate columns", func(t *testing.T) {
+		encoder, err := RecordEncoderFactory(CSV_ENCODING, withAggregates())
+		require.NoError(t, err)
+		require.Equal(t, "format,schema_id,id,timestamp,root_org_id,org_id,env_id,asset_id,worker_id,usage_bytes,billable,"+
+			"bytes_per_second,packets_per_second,window_start,window_end,samples,bytes_per_second_min,bytes_per_second_max,"+
+			"bytes_per_second_p95,packets_per_second_min,packets_per_second_max,packets_per_second_p95\n", string(encoder.Header()))
+
+		records, err := encoder.Encode(entry)
+		require.NoError(t, err)
+		require.Equal(t, "v1,network_schema_id,0b0e5c1a-2f0e-4a5e-9f43-3f4b5a6c7d8e,1717000000000,root,org,env,asset,worker-0,1024,true,"+
+			"20,,1717000000000,1717003600000,360,10,30,29.5,,,", string(records[0]))
+	})
+
+	t.Run("v1 nests the aggregate", func(t *testing.T) {
+		encoder, err := RecordEncoderFactory(V1_ENCODING)
+		require.NoError(t, err)
+
+		records, err := encoder.Encode(entry)
+		require.NoError(t, err)
+		require.Contains(t, string(records[0]), `"aggregate":{"window_start":1717000000000,"window_end":1717003600000,"samples":360,`+
+			`"bytes_per_second":{"min":10,"max":30,"mean":20,"p95":29.5}}`)
+	})
+
+	t.Run("otlp_json adds the known aggregate fields to the body", func(t *testing.T) {
+		encoder, err := RecordEncoderFactory(OTLP_JSON_ENCODING)
+		require.NoError(t, err)
+
+		records, err := encoder.Encode(entry)
+		require.NoError(t, err)
+		unmarshaler := plog.JSONUnmarshaler{}
+		logs, err := unmarshaler.UnmarshalLogs(records[0])
+		require.NoError(t, err)
+
+		body := logs.ResourceLogs().At(0).ScopeLogs().At(0).LogRecords().At(0).Body().Map()
+		p95, ok := body.Get("bytes_per_second_p95")
+		require.True(t, ok)
+		require.Equal(t, 29.5, p95.Double())
+		samples, ok := body.Get("samples")
+		require.True(t, ok)
+		require.Equal(t, int64(360), samples.Int())
+		_, ok = body.Get("packets_per_second_p95")
+		require.False(t, ok)
+	})
+}
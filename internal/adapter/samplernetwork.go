@@ -0,0 +1,403 @@
+package adapter
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/fsgonz/otelnetstatsreceiver/internal/logsampler"
+	"github.com/fsgonz/otelnetstatsreceiver/internal/stats/sampler"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/operator"
+	"go.uber.org/zap"
+)
+
+const (
+	UNIX_SOCKET_OUTPUT = "unix_socket"
+	UDP_OUTPUT         = "udp"
+	SYSLOG_OUTPUT      = "syslog"
+
+	defaultNetworkBufferSize           = 1000
+	defaultNetworkReconnectInterval    = time.Second
+	defaultNetworkMaxReconnectInterval = 30 * time.Second
+	networkDialTimeout                 = 5 * time.Second
+	networkWriteTimeout                = 5 * time.Second
+
+	defaultSyslogAppName = "otelnetstats"
+	syslogSeverityInfo   = 6
+)
+
+// syslogFacilities are the codes of the supported syslog facilities.
+var syslogFacilities = map[string]int{
+	"user": 1, "daemon": 3,
+	"local0": 16, "local1": 17, "local2": 18, "local3": 19, "local4": 20, "local5": 21, "local6": 22, "local7": 23,
+}
+
+// NetworkSamplerEmitter writes the records to a unix socket, as lines, or sends them over udp, as datagrams or
+// RFC5424 syslog messages. Records are buffered while the output is unreachable, so Emit never waits for it.
+type NetworkSamplerEmitter struct {
+	output     *networkOutput
+	persister  operator.Persister
+	sampler    sampler.Sampler
+	encoder    RecordEncoder
+	telemetry  *SamplerTelemetry
+	rate       bool
+	aggregator *windowAggregator
+	alerts     *alertEmitter
+}
+
+// Emit buffers the records of a sample and persists the sampler state. The usage of the records dropped from the
+// buffer since the last sample is persisted with the state, so that it is added to the next sample.
+func (e *NetworkSamplerEmitter) Emit(ctx context.Context) error {
+	logEntry, ok, commit, err := sampledEntry(ctx, e.persister, e.sampler, e.telemetry, e.rate, e.aggregator)
+	if alertErr := e.alerts.emit(ctx); alertErr != nil {
+		return alertErr
+	}
+	if err != nil {
+		return err
+	}
+	if ok {
+		records, err := e.encoder.Encode(logEntry)
+		if err != nil {
+			return err
+		}
+		for i, usage := range recordUsages(logEntry, records) {
+			e.output.send(ctx, records[i], usage)
+		}
+	}
+	if err := commit(ctx); err != nil {
+		return err
+	}
+	return e.keepDropped(ctx)
+}
+
+// Close sends the buffered records if the output is connected, closes the connection and persists the usage of
+// the records it dropped.
+func (e *NetworkSamplerEmitter) Close() error {
+	e.output.close()
+	return e.keepDropped(context.Background())
+}
+
+// keepDropped adds the usage of the records dropped by the output to the persisted sampler state, so that the next
+// sample includes it.
+func (e *NetworkSamplerEmitter) keepDropped(ctx context.Context) error {
+	usage := e.output.droppedUsage.Swap(0)
+	if usage == 0 {
+		return nil
+	}
+	state, err := loadSamplerState(ctx, e.persister)
+	if err == nil {
+		state.Dropped += usage
+		err = state.save(ctx, e.persister)
+	}
+	if err != nil {
+		// The usage is kept again on the next sample.
+		e.output.droppedUsage.Add(usage)
+		return err
+	}
+	return nil
+}
+
+// recordUsages returns the usage of each record of logEntry: the one of its event for the encodings writing a
+// record per event, otherwise the usage of all the events on the first record.
+func recordUsages(logEntry networkIOLogEntry, records [][]byte) []uint64 {
+	usages := make([]uint64, len(records))
+	if len(records) == len(logEntry.Events) {
+		for i, evt := range logEntry.Events {
+			usages[i] = evt.UsageBytes
+		}
+		return usages
+	}
+	if len(records) > 0 {
+		for _, evt := range logEntry.Events {
+			usages[0] += evt.UsageBytes
+		}
+	}
+	return usages
+}
+
+// newNetworkOutput creates the networkOutput of a unix_socket, udp or syslog sampler output.
+func newNetworkOutput(cfg logsampler.LogSampler, header []byte, telemetry *SamplerTelemetry, logger *zap.Logger) *networkOutput {
+	output := &networkOutput{
+		name:                 cfg.Output,
+		address:              cfg.URI,
+		reconnectInterval:    cfg.Network.ReconnectInterval,
+		maxReconnectInterval: cfg.Network.MaxReconnectInterval,
+		telemetry:            telemetry,
+		logger:               logger,
+	}
+	if output.reconnectInterval == 0 {
+		output.reconnectInterval = defaultNetworkReconnectInterval
+	}
+	if output.maxReconnectInterval == 0 {
+		output.maxReconnectInterval = defaultNetworkMaxReconnectInterval
+	}
+	output.maxReconnectInterval = max(output.maxReconnectInterval, output.reconnectInterval)
+	bufferSize := cfg.Network.BufferSize
+	if bufferSize == 0 {
+		bufferSize = defaultNetworkBufferSize
+	}
+
+	switch cfg.Output {
+	case UNIX_SOCKET_OUTPUT:
+		output.network = "unix"
+		// The peer reads a stream, which starts with the header on every connection.
+		output.header = header
+		output.frame = func(record []byte) []byte {
+			return append(append([]byte{}, record...), '\n')
+		}
+	case UDP_OUTPUT:
+		output.network = "udp"
+		output.frame = func(record []byte) []byte {
+			return record
+		}
+	case SYSLOG_OUTPUT:
+		output.network = "udp"
+		output.frame = newSyslogFormatter(cfg.Syslog, cfg.Metric).format
+	}
+
+	output.queue = make(chan networkRecord, bufferSize)
+	output.stop = make(chan struct{})
+	output.done = make(chan struct{})
+	go output.run()
+	return output
+}
+
+// networkOutput sends records to an address from a goroutine, through a bounded buffer, connecting again with
+// a backoff when the connection fails.
+type networkOutput struct {
+	name                 string
+	network              string
+	address              string
+	header               []byte
+	frame                func(record []byte) []byte
+	reconnectInterval    time.Duration
+	maxReconnectInterval time.Duration
+	telemetry            *SamplerTelemetry
+	logger               *zap.Logger
+
+	// mu serializes the senders, so that dropping the oldest record always makes room for the new one.
+	mu        sync.Mutex
+	queue     chan networkRecord
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+	// droppedUsage is the usage of the records dropped and not yet persisted by the emitter.
+	droppedUsage atomic.Uint64
+}
+
+// networkRecord is a framed record with the usage it carries.
+type networkRecord struct {
+	framed []byte
+	usage  uint64
+}
+
+// send buffers the record carrying usage, dropping the oldest buffered one if the buffer is full.
+func (o *networkOutput) send(ctx context.Context, record []byte, usage uint64) {
+	framed := networkRecord{framed: o.frame(record), usage: usage}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for {
+		select {
+		case o.queue <- framed:
+			return
+		default:
+		}
+		select {
+		case oldest := <-o.queue:
+			o.dropped(ctx, oldest)
+		default:
+		}
+	}
+}
+
+// dropped counts the records dropped and keeps their usage.
+func (o *networkOutput) dropped(ctx context.Context, records ...networkRecord) {
+	for _, record := range records {
+		o.droppedUsage.Add(record.usage)
+	}
+	o.telemetry.recordDroppedRecords(ctx, len(records), o.name)
+}
+
+// close stops the goroutine once it has sent the buffered records, if connected, and closes the connection.
+func (o *networkOutput) close() {
+	o.closeOnce.Do(func() {
+		close(o.stop)
+		<-o.done
+	})
+}
+
+func (o *networkOutput) run() {
+	defer close(o.done)
+	ctx := context.Background()
+	var conn net.Conn
+	defer func() {
+		if conn != nil {
+			conn.Close()
+		}
+	}()
+
+	var pending *networkRecord
+	delay := o.reconnectInterval
+	failing := false
+	for {
+		if pending == nil {
+			select {
+			case record := <-o.queue:
+				pending = &record
+			case <-o.stop:
+				o.drain(ctx, conn)
+				return
+			}
+		}
+
+		if conn == nil {
+			var err error
+			if conn, err = o.connect(); err != nil {
+				conn = nil
+				if !failing {
+					o.logger.Warn("Failed to connect to sampler output, buffering records", zap.String("output", o.name), zap.String("address", o.address), zap.Error(err))
+					failing = true
+				}
+				select {
+				case <-time.After(delay):
+				case <-o.stop:
+					o.dropped(ctx, append(o.buffered(), *pending)...)
+					return
+				}
+				delay = min(2*delay, o.maxReconnectInterval)
+				continue
+			}
+			if failing {
+				o.logger.Info("Connected to sampler output", zap.String("output", o.name), zap.String("address", o.address))
+				failing = false
+			}
+			delay = o.reconnectInterval
+		}
+
+		if err := o.write(conn, pending.framed); err != nil {
+			o.logger.Warn("Failed to send record to sampler output, reconnecting", zap.String("output", o.name), zap.String("address", o.address), zap.Error(err))
+			conn.Close()
+			conn = nil
+			failing = true
+			continue
+		}
+		o.telemetry.recordRecords(ctx, 1, o.name)
+		pending = nil
+	}
+}
+
+// connect dials the address and writes the header, if any.
+func (o *networkOutput) connect() (net.Conn, error) {
+	conn, err := net.DialTimeout(o.network, o.address, networkDialTimeout)
+	if err != nil {
+		return nil, err
+	}
+	if len(o.header) > 0 {
+		if err := o.write(conn, o.header); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+func (o *networkOutput) write(conn net.Conn, p []byte) error {
+	if err := conn.SetWriteDeadline(time.Now().Add(networkWriteTimeout)); err != nil {
+		return err
+	}
+	_, err := conn.Write(p)
+	return err
+}
+
+// drain sends the buffered records on shutdown, without connecting again.
+func (o *networkOutput) drain(ctx context.Context, conn net.Conn) {
+	for {
+		select {
+		case record := <-o.queue:
+			if conn == nil || o.write(conn, record.framed) != nil {
+				o.dropped(ctx, append(o.buffered(), record)...)
+				return
+			}
+			o.telemetry.recordRecords(ctx, 1, o.name)
+		default:
+			return
+		}
+	}
+}
+
+// buffered empties the buffer, returning its records.
+func (o *networkOutput) buffered() []networkRecord {
+	var records []networkRecord
+	for {
+		select {
+		case record := <-o.queue:
+			records = append(records, record)
+		default:
+			return records
+		}
+	}
+}
+
+// syslogFormatter formats records as RFC5424 messages without structured data.
+type syslogFormatter struct {
+	// header is the part of the message following the timestamp.
+	header   string
+	priority int
+	now      func() time.Time
+}
+
+func newSyslogFormatter(cfg logsampler.SyslogConfig, msgID string) *syslogFormatter {
+	facility, ok := syslogFacilities[cfg.Facility]
+	if !ok {
+		facility = syslogFacilities["user"]
+	}
+	hostname := cfg.Hostname
+	if hostname == "" {
+		hostname, _ = os.Hostname()
+	}
+	appName := cfg.AppName
+	if appName == "" {
+		appName = defaultSyslogAppName
+	}
+	header := strings.Join([]string{
+		syslogHeaderField(hostname, 255),
+		syslogHeaderField(appName, 48),
+		strconv.Itoa(os.Getpid()),
+		syslogHeaderField(msgID, 32),
+		"-",
+	}, " ")
+	return &syslogFormatter{
+		header:   header,
+		priority: facility*8 + syslogSeverityInfo,
+		now:      time.Now,
+	}
+}
+
+func (f *syslogFormatter) format(record []byte) []byte {
+	timestamp := f.now().UTC().Format("2006-01-02T15:04:05.000000Z07:00")
+	return append([]byte(fmt.Sprintf("<%d>1 %s %s ", f.priority, timestamp, f.header)), record...)
+}
+
+// syslogHeaderField returns value truncated to maxLen with the characters not allowed in RFC5424 header fields
+// replaced, or "-" if empty.
+func syslogHeaderField(value string, maxLen int) string {
+	field := []byte(value)
+	for i, c := range field {
+		if c < 33 || c > 126 {
+			field[i] = '_'
+		}
+	}
+	if len(field) > maxLen {
+		field = field[:maxLen]
+	}
+	if len(field) == 0 {
+		return "-"
+	}
+	return string(field)
+}
@@ -0,0 +1,234 @@
+package adapter
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/fsgonz/otelnetstatsreceiver/internal/logsampler"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/testutil"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componenttest"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.uber.org/zap"
+)
+
+func TestUnixSocketOutputReconnects(t *testing.T) {
+	dir, err := os.MkdirTemp("", "sock")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "metering.sock")
+
+	cfg := logsampler.LogSampler{Output: UNIX_SOCKET_OUTPUT, URI: path, Network: logsampler.NetworkOutputConfig{
+		ReconnectInterval:    10 * time.Millisecond,
+		MaxReconnectInterval: 20 * time.Millisecond,
+	}}
+	output := newNetworkOutput(cfg, []byte("header\n"), nil, zap.NewNop())
+	defer output.close()
+
+	// Records sent before the agent listens are buffered.
+	output.send(context.Background(), []byte("first"), 1)
+
+	listener, err := net.Listen("unix", path)
+	require.NoError(t, err)
+	defer listener.Close()
+
+	conn, err := listener.Accept()
+	require.NoError(t, err)
+	lines := bufio.NewScanner(conn)
+	require.Equal(t, []string{"header", "first"}, scanLines(t, conn, lines, 2))
+
+	// Once the agent closes the connection, records reach the next one, after the header.
+	conn.Close()
+	accepted := make(chan net.Conn)
+	go func() {
+		next, err := listener.Accept()
+		if err == nil {
+			accepted <- next
+		}
+	}()
+	var next net.Conn
+	for next == nil {
+		output.send(context.Background(), []byte("after"), 1)
+		select {
+		case next = <-accepted:
+		case <-time.After(20 * time.Millisecond):
+		}
+	}
+	defer next.Close()
+	lines = bufio.NewScanner(next)
+	require.Equal(t, []string{"header", "after"}, scanLines(t, next, lines, 2))
+}
+
+func TestUDPOutput(t *testing.T) {
+	listener, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+
+	output := newNetworkOutput(logsampler.LogSampler{Output: UDP_OUTPUT, URI: listener.LocalAddr().String()}, []byte("header\n"), nil, zap.NewNop())
+	defer output.close()
+	output.send(context.Background(), []byte(`{"usage_bytes":1}`), 1)
+
+	require.Equal(t, `{"usage_bytes":1}`, readDatagram(t, listener), "datagrams carry a record each, without the header")
+}
+
+func TestSyslogOutput(t *testing.T) {
+	listener, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+
+	cfg := logsampler.LogSampler{
+		Metric: "netstats",
+		Output: SYSLOG_OUTPUT,
+		URI:    listener.LocalAddr().String(),
+		Syslog: logsampler.SyslogConfig{Facility: "local0", AppName: "metering agent", Hostname: "node-1"},
+	}
+	output := newNetworkOutput(cfg, nil, nil, zap.NewNop())
+	defer output.close()
+	output.send(context.Background(), []byte(`{"usage_bytes":1}`), 1)
+
+	message := readDatagram(t, listener)
+	require.Regexp(t, regexp.MustCompile(`^<134>1 \d{4}-\d\d-\d\dT\d\d:\d\d:\d\d\.\d{6}Z node-1 metering_agent \d+ netstats - \{"usage_bytes":1\}$`), message)
+}
+
+func TestNetworkOutputBufferIsBounded(t *testing.T) {
+	dir, err := os.MkdirTemp("", "sock")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "metering.sock")
+
+	reader := sdkmetric.NewManualReader()
+	set := componenttest.NewNopTelemetrySettings()
+	set.MeterProvider = sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	telemetry, err := NewSamplerTelemetry(set, component.MustNewID("otelnetstatsreceiver"), "netstats")
+	require.NoError(t, err)
+
+	cfg := logsampler.LogSampler{Output: UNIX_SOCKET_OUTPUT, URI: path, Network: logsampler.NetworkOutputConfig{
+		BufferSize:        2,
+		ReconnectInterval: 10 * time.Millisecond,
+	}}
+	output := newNetworkOutput(cfg, nil, telemetry, zap.NewNop())
+	defer output.close()
+	output.send(context.Background(), []byte("1"), 1)
+	// The goroutine holds the first record while it connects, the buffer then keeps the newest ones.
+	require.Eventually(t, func() bool { return len(output.queue) == 0 }, 5*time.Second, time.Millisecond)
+	for _, record := range []string{"2", "3", "4", "5"} {
+		output.send(context.Background(), []byte(record), 1)
+	}
+
+	listener, err := net.Listen("unix", path)
+	require.NoError(t, err)
+	defer listener.Close()
+	conn, err := listener.Accept()
+	require.NoError(t, err)
+	defer conn.Close()
+
+	lines := scanLines(t, conn, bufio.NewScanner(conn), 3)
+	require.Equal(t, []string{"1", "4", "5"}, lines)
+
+	dropped := collectMetrics(t, reader)["otelnetstats_sampler_dropped_records"].(metricdata.Sum[int64]).DataPoints
+	require.Len(t, dropped, 1)
+	require.Equal(t, int64(2), dropped[0].Value)
+	outputName, _ := dropped[0].Attributes.Value("output")
+	require.Equal(t, UNIX_SOCKET_OUTPUT, outputName.AsString())
+	require.Equal(t, uint64(2), output.droppedUsage.Load())
+}
+
+func TestNetworkSamplerEmitter(t *testing.T) {
+	listener, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+
+	ctx := context.Background()
+	persister := testutil.NewUnscopedMockPersister()
+	cfg := logsampler.LogSampler{Metric: "netstats", Output: UDP_OUTPUT, URI: listener.LocalAddr().String(), Encoding: JSON_ENCODING}
+	samplerEmitter, err := SamplerEmitterFactory(cfg, persister, nil, nil, nil, nil, zap.NewNop())
+	require.NoError(t, err)
+	emitter, ok := samplerEmitter.(*NetworkSamplerEmitter)
+	require.True(t, ok, "the udp output is a network emitter")
+	defer emitter.Close()
+	emitter.sampler = &fakeSampler{values: []uint64{100, 150}, errs: []error{nil, nil}}
+
+	usage := regexp.MustCompile(`"usage_bytes":(\d+)`)
+	for _, want := range []string{"100", "50"} {
+		require.NoError(t, emitter.Emit(ctx))
+		require.Equal(t, want, usage.FindStringSubmatch(readDatagram(t, listener))[1])
+	}
+	state, err := loadSamplerState(ctx, persister)
+	require.NoError(t, err)
+	require.Equal(t, uint64(150), state.Count, "the sampler state advances once the records are buffered")
+}
+
+func TestNetworkSamplerEmitterKeepsDroppedUsage(t *testing.T) {
+	dir, err := os.MkdirTemp("", "sock")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	ctx := context.Background()
+	persister := testutil.NewUnscopedMockPersister()
+	cfg := logsampler.LogSampler{Metric: "netstats", Output: UNIX_SOCKET_OUTPUT, URI: filepath.Join(dir, "metering.sock"), Encoding: JSON_ENCODING,
+		Network: logsampler.NetworkOutputConfig{BufferSize: 1, ReconnectInterval: time.Hour}}
+	samplerEmitter, err := SamplerEmitterFactory(cfg, persister, nil, nil, nil, nil, zap.NewNop())
+	require.NoError(t, err)
+	emitter := samplerEmitter.(*NetworkSamplerEmitter)
+	emitter.sampler = &fakeSampler{values: []uint64{100, 150, 300, 310}, errs: []error{nil, nil, nil, nil}}
+
+	// Nothing listens: the goroutine holds the first record while it waits to connect again, and the buffer keeps
+	// the newest record, dropping the others.
+	require.NoError(t, emitter.Emit(ctx))
+	require.Eventually(t, func() bool { return len(emitter.output.queue) == 0 }, 5*time.Second, time.Millisecond)
+	for i := 0; i < 3; i++ {
+		require.NoError(t, emitter.Emit(ctx))
+	}
+	state, err := loadSamplerState(ctx, persister)
+	require.NoError(t, err)
+	require.Equal(t, uint64(150), state.Dropped, "the usage dropped with the second record is carried by the fourth one")
+
+	// The records still held on shutdown are dropped too.
+	require.NoError(t, emitter.Close())
+	state, err = loadSamplerState(ctx, persister)
+	require.NoError(t, err)
+	require.Equal(t, uint64(310), state.Dropped)
+
+	// After a restart, the next record carries the usage of all the dropped ones.
+	listener, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+	cfg = logsampler.LogSampler{Metric: "netstats", Output: UDP_OUTPUT, URI: listener.LocalAddr().String(), Encoding: JSON_ENCODING}
+	samplerEmitter, err = SamplerEmitterFactory(cfg, persister, nil, nil, nil, nil, zap.NewNop())
+	require.NoError(t, err)
+	emitter = samplerEmitter.(*NetworkSamplerEmitter)
+	defer emitter.Close()
+	emitter.sampler = &fakeSampler{values: []uint64{310}, errs: []error{nil}}
+
+	require.NoError(t, emitter.Emit(ctx))
+	require.Contains(t, readDatagram(t, listener), `"usage_bytes":310`)
+	state, err = loadSamplerState(ctx, persister)
+	require.NoError(t, err)
+	require.Zero(t, state.Dropped)
+}
+
+func scanLines(t *testing.T, conn net.Conn, scanner *bufio.Scanner, n int) []string {
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	var lines []string
+	for len(lines) < n && scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	require.NoError(t, scanner.Err())
+	return lines
+}
+
+func readDatagram(t *testing.T, conn net.PacketConn) string {
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	buf := make([]byte, 64*1024)
+	n, _, err := conn.ReadFrom(buf)
+	require.NoError(t, err)
+	return string(buf[:n])
+}
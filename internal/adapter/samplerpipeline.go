@@ -0,0 +1,87 @@
+package adapter
+
+import (
+	"context"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/entry"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/operator"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/operator/helper"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/pipeline"
+	"go.opentelemetry.io/collector/component"
+)
+
+const (
+	samplerInputType  = "sampler_input"
+	samplerOutputType = "sampler_output"
+)
+
+// SamplerInput is the operator sampler emitters write their entries into.
+type SamplerInput interface {
+	operator.Operator
+	NewEntry(value any) (*entry.Entry, error)
+	Write(ctx context.Context, e *entry.Entry)
+}
+
+// samplerInputConfig builds the first operator of a sampler pipeline.
+type samplerInputConfig struct {
+	helper.InputConfig
+}
+
+func (c samplerInputConfig) Build(set component.TelemetrySettings) (operator.Operator, error) {
+	inputOperator, err := c.InputConfig.Build(set)
+	if err != nil {
+		return nil, err
+	}
+	return &samplerInput{InputOperator: inputOperator}, nil
+}
+
+// samplerInput is an input operator that only receives the entries written by a sampler emitter.
+type samplerInput struct {
+	helper.InputOperator
+}
+
+// samplerOutputConfig builds the last operator of a sampler pipeline.
+type samplerOutputConfig struct {
+	helper.OutputConfig
+	next operator.Operator
+}
+
+func (c samplerOutputConfig) Build(set component.TelemetrySettings) (operator.Operator, error) {
+	outputOperator, err := c.OutputConfig.Build(set)
+	if err != nil {
+		return nil, err
+	}
+	return &samplerOutput{OutputOperator: outputOperator, next: c.next}, nil
+}
+
+// samplerOutput forwards the entries of a sampler pipeline to an operator owned by another
+// pipeline, so that stopping the sampler pipeline does not stop the shared log emitter.
+type samplerOutput struct {
+	helper.OutputOperator
+	next operator.Operator
+}
+
+func (o *samplerOutput) Process(ctx context.Context, e *entry.Entry) error {
+	return o.next.Process(ctx, e)
+}
+
+// buildSamplerPipeline builds a pipeline made of a sampler input, the given operators and
+// an output forwarding to next.
+func buildSamplerPipeline(set component.TelemetrySettings, operators []operator.Config, next operator.Operator) (pipeline.Pipeline, error) {
+	output, err := samplerOutputConfig{
+		OutputConfig: helper.NewOutputConfig(samplerOutputType, samplerOutputType),
+		next:         next,
+	}.Build(set)
+	if err != nil {
+		return nil, err
+	}
+
+	input := operator.NewConfig(&samplerInputConfig{
+		InputConfig: helper.NewInputConfig(samplerInputType, samplerInputType),
+	})
+
+	return pipeline.Config{
+		Operators:     append([]operator.Config{input}, operators...),
+		DefaultOutput: output,
+	}.Build(set)
+}
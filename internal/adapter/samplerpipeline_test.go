@@ -0,0 +1,88 @@
+package adapter
+
+import (
+	"context"
+	"testing"
+
+	"github.com/fsgonz/otelnetstatsreceiver/internal/logsampler"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/entry"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/operator"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/operator/transformer/add"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/testutil"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component/componenttest"
+)
+
+func TestSamplerPipeline(t *testing.T) {
+	addCfg := add.NewConfig()
+	addCfg.Field = entry.NewAttributeField("source")
+	addCfg.Value = "sampler"
+
+	next := testutil.NewFakeOutput(t)
+	pipe, err := buildSamplerPipeline(componenttest.NewNopTelemetrySettings(), []operator.Config{operator.NewConfig(addCfg)}, next)
+	require.NoError(t, err)
+
+	input, err := findInput(pipe, samplerInputType)
+	require.NoError(t, err)
+
+	require.NoError(t, pipe.Start(testutil.NewUnscopedMockPersister()))
+	defer func() {
+		require.NoError(t, pipe.Stop())
+	}()
+
+	ent, err := input.NewEntry("record")
+	require.NoError(t, err)
+	input.Write(context.Background(), ent)
+
+	got := <-next.Received
+	require.Equal(t, "record", got.Body)
+	require.Equal(t, "sampler", got.Attributes["source"])
+}
+
+func TestSamplerInputs(t *testing.T) {
+	set := componenttest.NewNopTelemetrySettings()
+	addCfg := add.NewConfig()
+	addCfg.Field = entry.NewAttributeField("source")
+	addCfg.Value = "file"
+
+	// The file input is stood in by a sampler pipeline with the operators of the tailed files.
+	next := testutil.NewFakeOutput(t)
+	filePipe, err := buildSamplerPipeline(set, []operator.Config{operator.NewConfig(addCfg)}, next)
+	require.NoError(t, err)
+	fileInput, err := findInput(filePipe, samplerInputType)
+	require.NoError(t, err)
+	require.NoError(t, filePipe.Start(testutil.NewUnscopedMockPersister()))
+	defer func() {
+		require.NoError(t, filePipe.Stop())
+	}()
+
+	samplerPipe, records, alerts, err := samplerInputs(set, &logsampler.LogSampler{}, fileInput, next)
+	require.NoError(t, err)
+	require.Nil(t, samplerPipe)
+	require.Equal(t, fileInput, records)
+	require.Equal(t, fileInput, alerts)
+
+	// Without sampler operators, records go through the file input operators and alerts around them.
+	samplerPipe, records, alerts, err = samplerInputs(set, &logsampler.LogSampler{Rules: []logsampler.Rule{{}}}, fileInput, next)
+	require.NoError(t, err)
+	require.NotNil(t, samplerPipe)
+	require.NoError(t, samplerPipe.Start(testutil.NewUnscopedMockPersister()))
+	defer func() {
+		require.NoError(t, samplerPipe.Stop())
+	}()
+
+	for _, tc := range []struct {
+		input  SamplerInput
+		source any
+	}{
+		{input: records, source: "file"},
+		{input: alerts, source: nil},
+	} {
+		ent, err := tc.input.NewEntry("record")
+		require.NoError(t, err)
+		tc.input.Write(context.Background(), ent)
+
+		got := <-next.Received
+		require.Equal(t, tc.source, got.Attributes["source"])
+	}
+}
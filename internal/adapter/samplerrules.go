@@ -0,0 +1,275 @@
+package adapter
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/fsgonz/otelnetstatsreceiver/internal/logsampler"
+	"github.com/fsgonz/otelnetstatsreceiver/internal/stats/sampler"
+	"github.com/fsgonz/otelnetstatsreceiver/internal/stats/scraper"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/entry"
+)
+
+const (
+	ALERT_SCHEMA_ID = "network_alert_schema_id"
+
+	ALERT_RULE_ATTRIBUTE      = "alert.rule"
+	ALERT_METRIC_ATTRIBUTE    = "alert.metric"
+	ALERT_OPERATOR_ATTRIBUTE  = "alert.operator"
+	ALERT_THRESHOLD_ATTRIBUTE = "alert.threshold"
+	ALERT_PER_ATTRIBUTE       = "alert.per"
+	ALERT_FOR_ATTRIBUTE       = "alert.for"
+	ALERT_VALUE_ATTRIBUTE     = "alert.value"
+	ALERT_SINCE_ATTRIBUTE     = "alert.since"
+)
+
+// ruleState tracks whether the condition of a rule holds and since when.
+type ruleState struct {
+	rule logsampler.Rule
+	// since is the start of the first interval of the samples meeting the condition, zero if not met.
+	since time.Time
+	// fired tells whether an alert was emitted since the condition is met, so it is emitted once.
+	fired bool
+}
+
+// alert is a rule that fired.
+type alert struct {
+	rule  logsampler.Rule
+	value float64
+	since time.Time
+	at    time.Time
+}
+
+// ruleSampler samples with the wrapped sampler and evaluates the rules on every sample, comparing
+// it with the previous one. The alerts of the rules that fired are kept until drained.
+type ruleSampler struct {
+	sampler sampler.StatsSampler
+	// now returns the time of a sample, time.Now unless replaced by tests.
+	now func() time.Time
+
+	mu       sync.Mutex
+	rules    []*ruleState
+	last     *scraper.NetworkStats
+	lastTime time.Time
+	alerts   []alert
+}
+
+func newRuleSampler(statsSampler sampler.StatsSampler, rules []logsampler.Rule) *ruleSampler {
+	states := make([]*ruleState, 0, len(rules))
+	for _, rule := range rules {
+		states = append(states, &ruleState{rule: rule})
+	}
+	return &ruleSampler{
+		sampler: statsSampler,
+		now:     time.Now,
+		rules:   states,
+	}
+}
+
+func (s *ruleSampler) Sample() (uint64, error) {
+	stats, err := s.SampleStats()
+	if err != nil {
+		return 0, err
+	}
+	return stats.ReceivedBytes + stats.TransmittedBytes, nil
+}
+
+// SampleStats samples the statistics with the wrapped sampler and evaluates the rules.
+func (s *ruleSampler) SampleStats() (scraper.NetworkStats, error) {
+	stats, err := s.sampler.SampleStats()
+	if err != nil {
+		return scraper.NetworkStats{}, err
+	}
+	s.observe(stats)
+	return stats, nil
+}
+
+// observe evaluates the rules on the sampled statistics.
+func (s *ruleSampler) observe(stats scraper.NetworkStats) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	if s.last != nil && now.After(s.lastTime) {
+		s.evaluate(stats, now)
+	}
+	s.last = &stats
+	s.lastTime = now
+}
+
+// Metadata returns the metadata of the wrapped sampler, if it has any.
+func (s *ruleSampler) Metadata() map[string]string {
+	if metadataSampler, ok := s.sampler.(sampler.MetadataSampler); ok {
+		return metadataSampler.Metadata()
+	}
+	return nil
+}
+
+// interfacesRuleSampler is a ruleSampler of a sampler of several interfaces, which keeps sampling each
+// interface and evaluates the rules on their sum.
+type interfacesRuleSampler struct {
+	*ruleSampler
+}
+
+// SampleInterfaces samples each interface with the wrapped sampler and evaluates the rules on their sum.
+func (s interfacesRuleSampler) SampleInterfaces() (map[string]scraper.NetworkStats, error) {
+	interfaces, err := s.sampler.(sampler.InterfacesSampler).SampleInterfaces()
+	if err != nil {
+		return nil, err
+	}
+	s.observe(sampler.SumStats(interfaces))
+	return interfaces, nil
+}
+
+// drain returns the alerts of the rules that fired since the last call.
+func (s *ruleSampler) drain() []alert {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	alerts := s.alerts
+	s.alerts = nil
+	return alerts
+}
+
+func (s *ruleSampler) evaluate(stats scraper.NetworkStats, now time.Time) {
+	for _, state := range s.rules {
+		value, ok := s.metric(state.rule, stats, now)
+		if !ok || !compare(state.rule.Operator, value, state.rule.Threshold) {
+			state.since, state.fired = time.Time{}, false
+			continue
+		}
+		if state.since.IsZero() {
+			state.since = s.lastTime
+		}
+		if !state.fired && now.Sub(state.since) >= state.rule.For {
+			state.fired = true
+			s.alerts = append(s.alerts, alert{rule: state.rule, value: value, since: state.since, at: now})
+		}
+	}
+}
+
+// metric returns the value of the rule metric over the interval since the last sample, and false if it is
+// unknown, such as after a counter reset or for the utilization of a link without speed.
+func (s *ruleSampler) metric(rule logsampler.Rule, stats scraper.NetworkStats, now time.Time) (float64, bool) {
+	elapsed := now.Sub(s.lastTime).Seconds()
+	if rule.Per == "minute" {
+		elapsed /= 60
+	}
+
+	last := *s.last
+	var current, previous uint64
+	switch rule.Metric {
+	case "bytes":
+		current, previous = stats.ReceivedBytes+stats.TransmittedBytes, last.ReceivedBytes+last.TransmittedBytes
+	case "rx_bytes", "rx_utilization_pct":
+		current, previous = stats.ReceivedBytes, last.ReceivedBytes
+	case "tx_bytes", "tx_utilization_pct":
+		current, previous = stats.TransmittedBytes, last.TransmittedBytes
+	case "packets":
+		current, previous = stats.ReceivedPackets+stats.TransmittedPackets, last.ReceivedPackets+last.TransmittedPackets
+	case "rx_packets":
+		current, previous = stats.ReceivedPackets, last.ReceivedPackets
+	case "tx_packets":
+		current, previous = stats.TransmittedPackets, last.TransmittedPackets
+	case "rx_dropped":
+		current, previous = stats.ReceivedDropped, last.ReceivedDropped
+	case "tx_dropped":
+		current, previous = stats.TransmittedDropped, last.TransmittedDropped
+	default:
+		return 0, false
+	}
+	if current < previous {
+		return 0, false
+	}
+	rate := float64(current-previous) / elapsed
+
+	switch rule.Metric {
+	case "rx_utilization_pct", "tx_utilization_pct":
+		speed, err := strconv.ParseInt(s.Metadata()[sampler.LinkSpeedKey], 10, 64)
+		if err != nil || speed <= 0 {
+			return 0, false
+		}
+		return rate * 8 / float64(speed*1_000_000) * 100, true
+	default:
+		return rate, true
+	}
+}
+
+func compare(operator string, value float64, threshold float64) bool {
+	switch operator {
+	case ">=":
+		return value >= threshold
+	case "<":
+		return value < threshold
+	case "<=":
+		return value <= threshold
+	default:
+		return value > threshold
+	}
+}
+
+// alertEmitter writes the alerts of a ruleSampler as entries into the sampler input, so they go through the
+// pipeline whatever the sampler output.
+type alertEmitter struct {
+	rules *ruleSampler
+	input SamplerInput
+	// hostname is the host.name resource of the alerts, not set if empty.
+	hostname string
+}
+
+// emit writes the alerts fired since the last call. It does nothing on a nil alertEmitter.
+func (e *alertEmitter) emit(ctx context.Context) error {
+	if e == nil {
+		return nil
+	}
+	for _, fired := range e.rules.drain() {
+		ent, err := e.input.NewEntry(fired.message())
+		if err != nil {
+			return err
+		}
+		ent.Timestamp = fired.at
+		ent.Severity, ent.SeverityText = entry.Warn, "WARN"
+		if fired.rule.Severity == "error" {
+			ent.Severity, ent.SeverityText = entry.Error, "ERROR"
+		}
+		if ent.Attributes == nil {
+			ent.Attributes = map[string]any{}
+		}
+		ent.Attributes[SCHEMA_ID] = ALERT_SCHEMA_ID
+		ent.Attributes[ALERT_RULE_ATTRIBUTE] = fired.rule.Name
+		ent.Attributes[ALERT_METRIC_ATTRIBUTE] = fired.rule.Metric
+		ent.Attributes[ALERT_OPERATOR_ATTRIBUTE] = ruleOperator(fired.rule)
+		ent.Attributes[ALERT_THRESHOLD_ATTRIBUTE] = fired.rule.Threshold
+		if fired.rule.Per != "" {
+			ent.Attributes[ALERT_PER_ATTRIBUTE] = fired.rule.Per
+		}
+		ent.Attributes[ALERT_FOR_ATTRIBUTE] = fired.rule.For.String()
+		ent.Attributes[ALERT_VALUE_ATTRIBUTE] = fired.value
+		ent.Attributes[ALERT_SINCE_ATTRIBUTE] = fired.since.UnixMilli()
+		if e.hostname != "" {
+			addResourceIfAbsent(ent, HOST_NAME_RESOURCE, e.hostname)
+		}
+
+		e.input.Write(ctx, ent)
+	}
+	return nil
+}
+
+// message describes the alert, such as "rule rx_drops: rx_dropped 150 > 100 per minute for 5m0s".
+func (a alert) message() string {
+	msg := fmt.Sprintf("rule %s: %s %s %s %s", a.rule.Name, a.rule.Metric,
+		strconv.FormatFloat(a.value, 'f', -1, 64), ruleOperator(a.rule), strconv.FormatFloat(a.rule.Threshold, 'f', -1, 64))
+	if a.rule.Per != "" {
+		msg += " per " + a.rule.Per
+	}
+	return msg + " for " + a.at.Sub(a.since).String()
+}
+
+// ruleOperator returns the operator of the rule, > if not set.
+func ruleOperator(rule logsampler.Rule) string {
+	if rule.Operator == "" {
+		return ">"
+	}
+	return rule.Operator
+}
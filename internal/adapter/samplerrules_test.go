@@ -0,0 +1,124 @@
+package adapter
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/fsgonz/otelnetstatsreceiver/internal/logsampler"
+	"github.com/fsgonz/otelnetstatsreceiver/internal/stats/sampler"
+	"github.com/fsgonz/otelnetstatsreceiver/internal/stats/scraper"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/entry"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/testutil"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component/componenttest"
+)
+
+func TestRuleSamplerDrops(t *testing.T) {
+	var stats []scraper.NetworkStats
+	for _, dropped := range []uint64{0, 50, 250, 450, 460, 700} {
+		stats = append(stats, scraper.NetworkStats{ReceivedDropped: dropped})
+	}
+	rules := newRuleSampler(&fakeStatsSampler{stats: stats},
+		[]logsampler.Rule{{Name: "rx_drops", Metric: "rx_dropped", Threshold: 100, Per: "minute"}})
+	now := time.Date(2024, 6, 1, 10, 0, 0, 0, time.UTC)
+	rules.now = func() time.Time { return now }
+
+	var fired [][]alert
+	for range stats {
+		_, err := rules.Sample()
+		require.NoError(t, err)
+		fired = append(fired, rules.drain())
+		now = now.Add(time.Minute)
+	}
+
+	require.Empty(t, fired[0], "the first sample only primes the rules")
+	require.Empty(t, fired[1], "50 drops per minute")
+	require.Len(t, fired[2], 1, "200 drops per minute")
+	require.Equal(t, 200.0, fired[2][0].value)
+	require.Empty(t, fired[3], "an alert is emitted once while the condition holds")
+	require.Empty(t, fired[4], "10 drops per minute clear the condition")
+	require.Len(t, fired[5], 1, "the rule fires again once the condition is met again")
+}
+
+func TestRuleSamplerUtilizationFor(t *testing.T) {
+	// 110 MB/s is 88% of a 1000 Mbps link.
+	var stats []scraper.NetworkStats
+	for minute := uint64(0); minute <= 6; minute++ {
+		stats = append(stats, scraper.NetworkStats{TransmittedBytes: minute * 60 * 110_000_000})
+	}
+	statsSampler := &fakeMetadataStatsSampler{
+		fakeStatsSampler: fakeStatsSampler{stats: stats},
+		metadata:         map[string]string{sampler.LinkSpeedKey: "1000"},
+	}
+	rules := newRuleSampler(statsSampler, []logsampler.Rule{
+		{Name: "egress_saturation", Metric: "tx_utilization_pct", Threshold: 80, For: 5 * time.Minute, Severity: "error"},
+	})
+	start := time.Date(2024, 6, 1, 10, 0, 0, 0, time.UTC)
+	now := start
+	rules.now = func() time.Time { return now }
+
+	firedAt := -1
+	for minute := range stats {
+		_, err := rules.Sample()
+		require.NoError(t, err)
+		if alerts := rules.drain(); len(alerts) > 0 {
+			require.Equal(t, -1, firedAt, "the rule fires once")
+			require.InDelta(t, 88, alerts[0].value, 0.001)
+			require.Equal(t, start, alerts[0].since)
+			firedAt = minute
+		}
+		now = now.Add(time.Minute)
+	}
+	require.Equal(t, 5, firedAt, "the condition must hold for 5 minutes")
+	require.Equal(t, map[string]string{sampler.LinkSpeedKey: "1000"}, rules.Metadata())
+}
+
+func TestAlertEmitter(t *testing.T) {
+	output := testutil.NewFakeOutput(t)
+	pipe, err := buildSamplerPipeline(componenttest.NewNopTelemetrySettings(), nil, output)
+	require.NoError(t, err)
+	input, err := findInput(pipe, samplerInputType)
+	require.NoError(t, err)
+	require.NoError(t, pipe.Start(testutil.NewUnscopedMockPersister()))
+	defer func() {
+		require.NoError(t, pipe.Stop())
+	}()
+
+	at := time.Date(2024, 6, 1, 10, 5, 0, 0, time.UTC)
+	rule := logsampler.Rule{Name: "rx_drops", Metric: "rx_dropped", Threshold: 100, Per: "minute", For: 2 * time.Minute, Severity: "error"}
+	rules := newRuleSampler(nil, nil)
+	rules.alerts = []alert{{rule: rule, value: 150, since: at.Add(-3 * time.Minute), at: at}}
+
+	require.NoError(t, (&alertEmitter{rules: rules, input: input, hostname: "host-a"}).emit(context.Background()))
+	require.NoError(t, (*alertEmitter)(nil).emit(context.Background()))
+
+	got := <-output.Received
+	require.Equal(t, "rule rx_drops: rx_dropped 150 > 100 per minute for 3m0s", got.Body)
+	require.Equal(t, entry.Error, got.Severity)
+	require.Equal(t, "ERROR", got.SeverityText)
+	require.Equal(t, at, got.Timestamp)
+	require.Equal(t, map[string]any{
+		SCHEMA_ID:                 ALERT_SCHEMA_ID,
+		ALERT_RULE_ATTRIBUTE:      "rx_drops",
+		ALERT_METRIC_ATTRIBUTE:    "rx_dropped",
+		ALERT_OPERATOR_ATTRIBUTE:  ">",
+		ALERT_THRESHOLD_ATTRIBUTE: 100.0,
+		ALERT_PER_ATTRIBUTE:       "minute",
+		ALERT_FOR_ATTRIBUTE:       "2m0s",
+		ALERT_VALUE_ATTRIBUTE:     150.0,
+		ALERT_SINCE_ATTRIBUTE:     at.Add(-3 * time.Minute).UnixMilli(),
+	}, got.Attributes)
+	require.Equal(t, map[string]any{HOST_NAME_RESOURCE: "host-a"}, got.Resource)
+	require.Empty(t, rules.drain())
+}
+
+// fakeMetadataStatsSampler is a fakeStatsSampler describing its samples with fixed metadata.
+type fakeMetadataStatsSampler struct {
+	fakeStatsSampler
+	metadata map[string]string
+}
+
+func (s *fakeMetadataStatsSampler) Metadata() map[string]string {
+	return s.metadata
+}
@@ -0,0 +1,76 @@
+package adapter
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/fsgonz/otelnetstatsreceiver/internal/signing"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/operator"
+)
+
+// SIGNING_CHAIN_KEY persists the sequence number and MAC of the last signed record, so the chain continues
+// across restarts.
+const SIGNING_CHAIN_KEY = "signing_chain"
+
+// recordSigner signs the records of a file_logger output, restoring the chain from the persister on first use.
+type recordSigner struct {
+	key       []byte
+	persister operator.Persister
+	signer    *signing.Signer
+}
+
+func newRecordSigner(keyFile string, persister operator.Persister) (*recordSigner, error) {
+	key, err := signing.LoadKey(keyFile)
+	if err != nil {
+		return nil, err
+	}
+	return &recordSigner{key: key, persister: persister}, nil
+}
+
+// sign returns the signed record, or the record itself if the signer is nil, and the stateCommit advancing the
+// chain to it once it is written, so that a record which could not be written is signed again. A record written
+// just before a crash is reported as reordered after the restart, as the next record reuses its seq.
+func (s *recordSigner) sign(ctx context.Context, record []byte) ([]byte, stateCommit, error) {
+	if s == nil {
+		return record, noCommit, nil
+	}
+	if s.signer == nil {
+		seq, prev, err := s.restore(ctx)
+		if err != nil {
+			return nil, nil, err
+		}
+		s.signer = signing.NewSigner(s.key, seq, prev)
+	}
+
+	seq, prev := s.signer.State()
+	next := signing.NewSigner(s.key, seq, prev)
+	signed, err := next.Sign(record)
+	if err != nil {
+		return nil, nil, err
+	}
+	commit := func(ctx context.Context) error {
+		// The record is written, so the chain goes on from it even if its state cannot be persisted.
+		s.signer = next
+		seq, prev := next.State()
+		if err := s.persister.Set(ctx, SIGNING_CHAIN_KEY, []byte(strconv.FormatUint(seq, 10)+" "+prev)); err != nil {
+			return fmt.Errorf("persist signing chain: %w", err)
+		}
+		return nil
+	}
+	return signed, commit, nil
+}
+
+func (s *recordSigner) restore(ctx context.Context) (uint64, string, error) {
+	state, err := s.persister.Get(ctx, SIGNING_CHAIN_KEY)
+	if err != nil || len(state) == 0 {
+		return 0, "", err
+	}
+	seq, prev, _ := strings.Cut(string(state), " ")
+	parsed, err := strconv.ParseUint(seq, 10, 64)
+	if err != nil {
+		return 0, "", fmt.Errorf("malformed signing chain state: %w", err)
+	}
+	return parsed, prev, nil
+}
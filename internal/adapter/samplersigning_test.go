@@ -0,0 +1,70 @@
+package adapter
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/fsgonz/otelnetstatsreceiver/internal/signing"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordSignerContinuesChain(t *testing.T) {
+	ctx := context.Background()
+	keyFile := filepath.Join(t.TempDir(), "signing.key")
+	require.NoError(t, os.WriteFile(keyFile, []byte("0123456789abcdef0123456789abcdef\n"), 0600))
+	persister := testutil.NewUnscopedMockPersister()
+
+	var signed [][]byte
+	// A new signer, as after a restart, continues the chain persisted by the previous one.
+	for i := 0; i < 2; i++ {
+		signer, err := newRecordSigner(keyFile, persister)
+		require.NoError(t, err)
+		record, commit, err := signer.sign(ctx, []byte(`{"usage_bytes":1}`))
+		require.NoError(t, err)
+		require.NoError(t, commit(ctx))
+		signed = append(signed, record)
+	}
+
+	_, first, err := signing.Split(signed[0])
+	require.NoError(t, err)
+	_, second, err := signing.Split(signed[1])
+	require.NoError(t, err)
+	require.Equal(t, uint64(2), second.Seq)
+	require.Equal(t, first.MAC, second.Prev)
+
+	var nilSigner *recordSigner
+	record, commit, err := nilSigner.sign(ctx, []byte("a,b"))
+	require.NoError(t, err)
+	require.NoError(t, commit(ctx))
+	require.Equal(t, "a,b", string(record))
+}
+
+func TestRecordSignerAdvancesOnCommit(t *testing.T) {
+	ctx := context.Background()
+	keyFile := filepath.Join(t.TempDir(), "signing.key")
+	require.NoError(t, os.WriteFile(keyFile, []byte("0123456789abcdef0123456789abcdef\n"), 0600))
+	persister := testutil.NewUnscopedMockPersister()
+	signer, err := newRecordSigner(keyFile, persister)
+	require.NoError(t, err)
+
+	// A record which was not written is signed again with the same seq, and nothing is persisted.
+	unwritten, _, err := signer.sign(ctx, []byte(`{"usage_bytes":1}`))
+	require.NoError(t, err)
+	state, err := persister.Get(ctx, SIGNING_CHAIN_KEY)
+	require.NoError(t, err)
+	require.Empty(t, state)
+
+	written, commit, err := signer.sign(ctx, []byte(`{"usage_bytes":1}`))
+	require.NoError(t, err)
+	require.Equal(t, string(unwritten), string(written))
+	require.NoError(t, commit(ctx))
+
+	_, signature, err := signing.Split(written)
+	require.NoError(t, err)
+	state, err = persister.Get(ctx, SIGNING_CHAIN_KEY)
+	require.NoError(t, err)
+	require.Equal(t, "1 "+signature.MAC, string(state))
+}
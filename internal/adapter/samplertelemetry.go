@@ -0,0 +1,135 @@
+package adapter // import "github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/adapter"
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/noop"
+)
+
+// SamplerTelemetry records the self-metrics of a sampler, so that a stalled sampler can be detected.
+type SamplerTelemetry struct {
+	attrs attribute.Set
+
+	scrapeDuration metric.Float64Histogram
+	scrapeFailures metric.Int64Counter
+	records        metric.Int64Counter
+	droppedRecords metric.Int64Counter
+	counterResets  metric.Int64Counter
+
+	// lastSuccess is the unix nanoseconds of the last sample written, zero before the first one.
+	lastSuccess atomic.Int64
+	// registration observes lastSuccess until the receiver shuts down.
+	registration metric.Registration
+}
+
+// NewSamplerTelemetry creates the sampler metrics of the receiver id for the sampled metric.
+func NewSamplerTelemetry(set component.TelemetrySettings, id component.ID, sampledMetric string) (*SamplerTelemetry, error) {
+	meterProvider := set.MeterProvider
+	if meterProvider == nil {
+		meterProvider = noop.NewMeterProvider()
+	}
+	meter := meterProvider.Meter(scopeName)
+	t := &SamplerTelemetry{
+		attrs: attribute.NewSet(attribute.String("receiver", id.String()), attribute.String("metric", sampledMetric)),
+	}
+
+	var err error
+	if t.scrapeDuration, err = meter.Float64Histogram("otelnetstats_sampler_scrape_duration",
+		metric.WithDescription("Time taken to read the sampled counters."),
+		metric.WithUnit("s"),
+		metric.WithExplicitBucketBoundaries(0.0005, 0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1)); err != nil {
+		return nil, err
+	}
+	if t.scrapeFailures, err = meter.Int64Counter("otelnetstats_sampler_scrape_failures",
+		metric.WithDescription("Number of times the sampled counters could not be read."),
+		metric.WithUnit("{failures}")); err != nil {
+		return nil, err
+	}
+	if t.records, err = meter.Int64Counter("otelnetstats_sampler_records",
+		metric.WithDescription("Number of records written by the sampler."),
+		metric.WithUnit("{records}")); err != nil {
+		return nil, err
+	}
+	if t.droppedRecords, err = meter.Int64Counter("otelnetstats_sampler_dropped_records",
+		metric.WithDescription("Number of records dropped because the buffer of the output was full."),
+		metric.WithUnit("{records}")); err != nil {
+		return nil, err
+	}
+	if t.counterResets, err = meter.Int64Counter("otelnetstats_sampler_counter_resets",
+		metric.WithDescription("Number of times the sampled counter went backwards, such as after a reboot."),
+		metric.WithUnit("{resets}")); err != nil {
+		return nil, err
+	}
+	lastSuccess, err := meter.Float64ObservableGauge("otelnetstats_sampler_last_success",
+		metric.WithDescription("Unix time of the last sample written, in seconds."),
+		metric.WithUnit("s"))
+	if err != nil {
+		return nil, err
+	}
+	t.registration, err = meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		if last := t.lastSuccess.Load(); last != 0 {
+			o.ObserveFloat64(lastSuccess, float64(last)/float64(time.Second), metric.WithAttributeSet(t.attrs))
+		}
+		return nil
+	}, lastSuccess)
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// shutdown stops observing the last success, so that a stopped receiver no longer reports it.
+func (t *SamplerTelemetry) shutdown() error {
+	if t == nil {
+		return nil
+	}
+	return t.registration.Unregister()
+}
+
+// recordScrape records a scrape that took duration and failed if err is not nil.
+func (t *SamplerTelemetry) recordScrape(ctx context.Context, duration time.Duration, err error) {
+	if t == nil {
+		return
+	}
+	t.scrapeDuration.Record(ctx, duration.Seconds(), metric.WithAttributeSet(t.attrs))
+	if err != nil {
+		t.scrapeFailures.Add(ctx, 1, metric.WithAttributeSet(t.attrs))
+	}
+}
+
+// recordCounterReset records that the sampled counter went backwards.
+func (t *SamplerTelemetry) recordCounterReset(ctx context.Context) {
+	if t == nil {
+		return
+	}
+	t.counterResets.Add(ctx, 1, metric.WithAttributeSet(t.attrs))
+}
+
+// recordRecords records n records written to output.
+func (t *SamplerTelemetry) recordRecords(ctx context.Context, n int, output string) {
+	if t == nil {
+		return
+	}
+	t.records.Add(ctx, int64(n), metric.WithAttributeSet(t.attrs), metric.WithAttributes(attribute.String("output", output)))
+}
+
+// recordDroppedRecords records n records dropped by output.
+func (t *SamplerTelemetry) recordDroppedRecords(ctx context.Context, n int, output string) {
+	if t == nil || n == 0 {
+		return
+	}
+	t.droppedRecords.Add(ctx, int64(n), metric.WithAttributeSet(t.attrs), metric.WithAttributes(attribute.String("output", output)))
+}
+
+// recordSuccess records that a sample was written.
+func (t *SamplerTelemetry) recordSuccess() {
+	if t == nil {
+		return
+	}
+	t.lastSuccess.Store(time.Now().UnixNano())
+}
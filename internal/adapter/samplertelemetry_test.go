@@ -0,0 +1,87 @@
+package adapter
+
+import (
+	"context"
+	"errors"
+	"log"
+	"regexp"
+	"testing"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/testutil"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componenttest"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestSamplerTelemetry(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	set := componenttest.NewNopTelemetrySettings()
+	set.MeterProvider = sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	telemetry, err := NewSamplerTelemetry(set, component.MustNewID("otelnetstatsreceiver"), "netstats")
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	encoder, err := RecordEncoderFactory("")
+	require.NoError(t, err)
+	writer := &failingWriter{}
+	emitter := &FileLoggerSamplerEmitter{
+		metricsLogger: log.New(writer, "", 0),
+		persister:     testutil.NewUnscopedMockPersister(),
+		sampler:       &fakeSampler{values: []uint64{100, 150, 40}, errs: []error{nil, nil, nil, errors.New("interface 'eth0' not found")}},
+		encoder:       encoder,
+		telemetry:     telemetry,
+	}
+	for i := 0; i < 4; i++ {
+		if err := emitter.Emit(ctx); err == nil {
+			telemetry.recordSuccess()
+		}
+	}
+	usage := regexp.MustCompile(`"usage_bytes":(\d+)`).FindAllStringSubmatch(writer.written.String(), -1)
+	require.Len(t, usage, 3)
+	require.Equal(t, []string{"100", "50", "40"}, []string{usage[0][1], usage[1][1], usage[2][1]}, "a counter reset counts from zero")
+
+	metrics := collectMetrics(t, reader)
+	require.Equal(t, uint64(4), metrics["otelnetstats_sampler_scrape_duration"].(metricdata.Histogram[float64]).DataPoints[0].Count)
+	require.Equal(t, int64(1), metrics["otelnetstats_sampler_scrape_failures"].(metricdata.Sum[int64]).DataPoints[0].Value)
+	require.Equal(t, int64(1), metrics["otelnetstats_sampler_counter_resets"].(metricdata.Sum[int64]).DataPoints[0].Value)
+	records := metrics["otelnetstats_sampler_records"].(metricdata.Sum[int64]).DataPoints
+	require.Len(t, records, 1)
+	require.Equal(t, int64(3), records[0].Value)
+	output, _ := records[0].Attributes.Value("output")
+	require.Equal(t, FILE_LOGGER_OUTPUT, output.AsString())
+	require.Contains(t, metrics, "otelnetstats_sampler_last_success")
+
+	// A stopped receiver no longer reports its last success.
+	require.NoError(t, telemetry.shutdown())
+	require.NotContains(t, collectMetrics(t, reader), "otelnetstats_sampler_last_success")
+}
+
+// collectMetrics returns the data of the metrics collected by reader, by name.
+func collectMetrics(t *testing.T, reader *sdkmetric.ManualReader) map[string]metricdata.Aggregation {
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+	metrics := map[string]metricdata.Aggregation{}
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			metrics[m.Name] = m.Data
+		}
+	}
+	return metrics
+}
+
+// fakeSampler returns its values and errors in order.
+type fakeSampler struct {
+	values []uint64
+	errs   []error
+	calls  int
+}
+
+func (s *fakeSampler) Sample() (uint64, error) {
+	defer func() { s.calls++ }()
+	if err := s.errs[s.calls]; err != nil {
+		return 0, err
+	}
+	return s.values[s.calls], nil
+}
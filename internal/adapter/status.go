@@ -0,0 +1,133 @@
+package adapter // import "github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/adapter"
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/bmatcuk/doublestar/v4"
+	"go.opentelemetry.io/collector/component"
+)
+
+const (
+	tailerStatusSource   = "tailer"
+	consumerStatusSource = "consumer"
+	samplerStatusSource  = "sampler"
+)
+
+// statusReporter reports the status of the receiver from the status of its parts: a permanent
+// error of any part first, then recoverable errors, and OK once every part is OK.
+type statusReporter struct {
+	report func(*component.StatusEvent)
+
+	mu       sync.Mutex
+	statuses map[string]*component.StatusEvent
+	// reported is the last event reported, to report changes only.
+	reported *component.StatusEvent
+}
+
+func newStatusReporter(set component.TelemetrySettings) *statusReporter {
+	report := set.ReportStatus
+	if report == nil {
+		report = func(*component.StatusEvent) {}
+	}
+	return &statusReporter{
+		report:   report,
+		statuses: map[string]*component.StatusEvent{},
+	}
+}
+
+// ok records that source works.
+func (s *statusReporter) ok(source string) {
+	s.update(source, component.NewStatusEvent(component.StatusOK))
+}
+
+// recoverable records that source failed with err, but may recover.
+func (s *statusReporter) recoverable(source string, err error) {
+	s.update(source, component.NewRecoverableErrorEvent(fmt.Errorf("%s: %w", source, err)))
+}
+
+// permanent records that source failed with err and stopped.
+func (s *statusReporter) permanent(source string, err error) {
+	s.update(source, component.NewPermanentErrorEvent(fmt.Errorf("%s: %w", source, err)))
+}
+
+func (s *statusReporter) update(source string, ev *component.StatusEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if previous, ok := s.statuses[source]; ok && sameStatus(previous, ev) {
+		return
+	}
+	s.statuses[source] = ev
+
+	aggregated := s.aggregate()
+	if s.reported != nil && sameStatus(s.reported, aggregated) {
+		return
+	}
+	s.reported = aggregated
+	s.report(aggregated)
+}
+
+// aggregate returns the event of the worst status, joining the errors of the parts in that status.
+func (s *statusReporter) aggregate() *component.StatusEvent {
+	sources := make([]string, 0, len(s.statuses))
+	for source := range s.statuses {
+		sources = append(sources, source)
+	}
+	sort.Strings(sources)
+
+	var permanent, recoverable []error
+	for _, source := range sources {
+		switch ev := s.statuses[source]; ev.Status() {
+		case component.StatusPermanentError:
+			permanent = append(permanent, ev.Err())
+		case component.StatusRecoverableError:
+			recoverable = append(recoverable, ev.Err())
+		}
+	}
+	switch {
+	case len(permanent) > 0:
+		return component.NewPermanentErrorEvent(errors.Join(permanent...))
+	case len(recoverable) > 0:
+		return component.NewRecoverableErrorEvent(errors.Join(recoverable...))
+	default:
+		return component.NewStatusEvent(component.StatusOK)
+	}
+}
+
+func sameStatus(a, b *component.StatusEvent) bool {
+	if a.Status() != b.Status() {
+		return false
+	}
+	if a.Err() == nil || b.Err() == nil {
+		return a.Err() == b.Err()
+	}
+	return a.Err().Error() == b.Err().Error()
+}
+
+// matchFiles tells whether a file matches the include patterns and none of the exclude ones, as the file input does.
+func matchFiles(include, exclude []string) (bool, error) {
+	for _, pattern := range include {
+		matches, err := doublestar.FilepathGlob(pattern, doublestar.WithFilesOnly())
+		if err != nil {
+			return false, err
+		}
+		for _, match := range matches {
+			if !excluded(match, exclude) {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+func excluded(path string, exclude []string) bool {
+	for _, pattern := range exclude {
+		if ok, _ := doublestar.PathMatch(pattern, path); ok {
+			return true
+		}
+	}
+	return false
+}
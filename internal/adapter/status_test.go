@@ -0,0 +1,54 @@
+package adapter
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componenttest"
+)
+
+func TestStatusReporter(t *testing.T) {
+	var events []*component.StatusEvent
+	set := componenttest.NewNopTelemetrySettings()
+	set.ReportStatus = func(ev *component.StatusEvent) {
+		events = append(events, ev)
+	}
+	status := newStatusReporter(set)
+
+	status.ok(tailerStatusSource)
+	status.ok(samplerStatusSource)
+	require.Len(t, events, 1, "only changes are reported")
+	require.Equal(t, component.StatusOK, events[0].Status())
+
+	status.recoverable(samplerStatusSource, errors.New("interface 'eth0' not found"))
+	require.Len(t, events, 2)
+	require.Equal(t, component.StatusRecoverableError, events[1].Status())
+	require.EqualError(t, events[1].Err(), "sampler: interface 'eth0' not found")
+
+	status.ok(samplerStatusSource)
+	require.Len(t, events, 3)
+	require.Equal(t, component.StatusOK, events[2].Status())
+
+	status.recoverable(tailerStatusSource, errors.New("no files match the include patterns"))
+	status.permanent(samplerStatusSource, errors.New("unknown output type"))
+	require.Len(t, events, 5)
+	require.Equal(t, component.StatusPermanentError, events[4].Status())
+	require.EqualError(t, events[4].Err(), "sampler: unknown output type")
+}
+
+func TestMatchFiles(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "usage.log"), nil, 0600))
+
+	matched, err := matchFiles([]string{filepath.Join(dir, "*.log")}, nil)
+	require.NoError(t, err)
+	require.True(t, matched)
+
+	matched, err = matchFiles([]string{filepath.Join(dir, "*.log")}, []string{filepath.Join(dir, "usage.*")})
+	require.NoError(t, err)
+	require.False(t, matched)
+}
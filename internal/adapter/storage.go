@@ -11,7 +11,14 @@ import (
 	"go.opentelemetry.io/collector/extension/experimental/storage"
 )
 
+// retryQueueStorageName names the storage client of the persistent retry queue.
+const retryQueueStorageName = "retry_queue"
+
 func GetStorageClient(ctx context.Context, host component.Host, storageID *component.ID, componentID component.ID) (storage.Client, error) {
+	return getStorageClient(ctx, host, storageID, componentID, "")
+}
+
+func getStorageClient(ctx context.Context, host component.Host, storageID *component.ID, componentID component.ID, name string) (storage.Client, error) {
 	if storageID == nil {
 		return storage.NewNopClient(), nil
 	}
@@ -26,7 +33,7 @@ func GetStorageClient(ctx context.Context, host component.Host, storageID *compo
 		return nil, fmt.Errorf("non-storage extension '%s' found", storageID)
 	}
 
-	return storageExtension.GetClient(ctx, component.KindReceiver, componentID, "")
+	return storageExtension.GetClient(ctx, component.KindReceiver, componentID, name)
 
 }
 
@@ -38,3 +45,19 @@ func (r *receiver) setStorageClient(ctx context.Context, host component.Host) er
 	r.storageClient = client
 	return nil
 }
+
+// startConsumer starts the retrying consumer with its own storage client for the persistent queue, if enabled.
+func (r *receiver) startConsumer(ctx context.Context, host component.Host) error {
+	if !r.persistentQueue {
+		return r.consumer.Start(ctx, nil)
+	}
+	if r.storageID == nil {
+		return fmt.Errorf("persistent queue requires storage to be set")
+	}
+
+	client, err := getStorageClient(ctx, host, r.storageID, r.id, retryQueueStorageName)
+	if err != nil {
+		return err
+	}
+	return r.consumer.Start(ctx, client)
+}
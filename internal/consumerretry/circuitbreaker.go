@@ -0,0 +1,116 @@
+package consumerretry
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/status"
+)
+
+// circuitBreaker pauses the sending of logs while the next consumer is unhealthy.
+// It opens after a number of consecutive failures, or when the next consumer asks
+// to retry later, and lets a new attempt through once the open duration is over.
+// A failure of that attempt opens it again, a success closes it.
+//
+// While it is open, ConsumeLogs blocks, which in turn blocks the converter, the
+// log emitter, the file readers and the samplers writing into the pipeline.
+type circuitBreaker struct {
+	threshold    int
+	openDuration time.Duration
+
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+}
+
+func newCircuitBreaker(cfg CircuitBreakerConfig) *circuitBreaker {
+	return &circuitBreaker{
+		threshold:    cfg.FailureThreshold,
+		openDuration: cfg.OpenDuration,
+	}
+}
+
+// wait blocks while the breaker is open. It returns the time spent waiting.
+func (b *circuitBreaker) wait(ctx context.Context) (time.Duration, error) {
+	var waited time.Duration
+	for {
+		b.mu.Lock()
+		remaining := time.Until(b.openUntil)
+		b.mu.Unlock()
+		if remaining <= 0 {
+			return waited, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return waited, ctx.Err()
+		case <-time.After(remaining):
+			waited += remaining
+		}
+	}
+}
+
+// success closes the breaker. It returns true if the breaker was open.
+func (b *circuitBreaker) success() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	wasOpen := b.failures >= b.threshold || !b.openUntil.IsZero()
+	b.failures = 0
+	b.openUntil = time.Time{}
+	return wasOpen
+}
+
+// failure records a failed attempt, opening the breaker for at least retryAfter.
+// It returns true if the breaker got opened.
+func (b *circuitBreaker) failure(retryAfter time.Duration) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	until := time.Time{}
+	if b.failures >= b.threshold {
+		until = time.Now().Add(b.openDuration)
+	}
+	if retryAfter > 0 {
+		if throttled := time.Now().Add(retryAfter); throttled.After(until) {
+			until = throttled
+		}
+	}
+	if until.After(b.openUntil) {
+		b.openUntil = until
+		return true
+	}
+	return false
+}
+
+// retryAfter is implemented by errors that carry the delay requested by the next consumer before retrying.
+type retryAfter interface {
+	RetryAfter() time.Duration
+}
+
+// retryAfterDelay returns the delay the next consumer asked to wait before retrying, if any.
+// The consumererror package does not carry it yet, so it is taken from errors implementing
+// RetryAfter() time.Duration, or from the RetryInfo details of a wrapped gRPC status, as
+// returned by exporters sending logs synchronously over OTLP/gRPC.
+func retryAfterDelay(err error) (time.Duration, bool) {
+	var ra retryAfter
+	if errors.As(err, &ra) && ra.RetryAfter() > 0 {
+		return ra.RetryAfter(), true
+	}
+
+	st, ok := status.FromError(err)
+	if !ok {
+		return 0, false
+	}
+	for _, detail := range st.Details() {
+		if info, ok := detail.(*errdetails.RetryInfo); ok && info.GetRetryDelay() != nil {
+			if delay := info.GetRetryDelay().AsDuration(); delay > 0 {
+				return delay, true
+			}
+		}
+	}
+	return 0, false
+}
@@ -3,7 +3,10 @@
 
 package consumerretry // import "github.com/open-telemetry/opentelemetry-collector-contrib/internal/coreinternal/consumerretry"
 
-import "time"
+import (
+	"errors"
+	"time"
+)
 
 // Config defines configuration for retrying batches in case of receiving a retryable error from a downstream
 // consumer. If the retryable error doesn't provide a delay, exponential backoff is applied.
@@ -20,6 +23,78 @@ type Config struct {
 	// a downstream consumer. Once this value is reached, the data is discarded. It never stops if MaxElapsedTime == 0.
 	// Default value is 5 minutes.
 	MaxElapsedTime time.Duration `mapstructure:"max_elapsed_time"`
+	// PersistentQueue keeps the logs that could not be delivered within MaxElapsedTime in the receiver storage
+	// instead of discarding them, and replays them in order, also after a restart.
+	PersistentQueue PersistentQueueConfig `mapstructure:"persistent_queue"`
+	// DeadLetter writes the logs that are discarded to a file, so that they can be sent again later.
+	DeadLetter DeadLetterConfig `mapstructure:"dead_letter"`
+	// CircuitBreaker pauses sending logs while the downstream consumer keeps failing, so that reading is slowed
+	// down instead of retrying every batch on its own.
+	CircuitBreaker CircuitBreakerConfig `mapstructure:"circuit_breaker"`
+}
+
+// CircuitBreakerConfig defines configuration for pausing deliveries while the downstream consumer is unhealthy.
+type CircuitBreakerConfig struct {
+	// Enabled indicates whether the circuit breaker is used. Default is false.
+	Enabled bool `mapstructure:"enabled"`
+	// FailureThreshold is the number of consecutive failed attempts after which deliveries are paused.
+	// Default value is 5.
+	FailureThreshold int `mapstructure:"failure_threshold"`
+	// OpenDuration is how long deliveries are paused before a new attempt is made. A longer delay requested by
+	// the downstream consumer takes precedence. Default value is 30 seconds.
+	OpenDuration time.Duration `mapstructure:"open_duration"`
+}
+
+// PersistentQueueConfig defines configuration for queueing undelivered logs in the storage extension.
+type PersistentQueueConfig struct {
+	// Enabled indicates whether undelivered logs are queued. It requires the receiver storage to be set.
+	// Default is false.
+	Enabled bool `mapstructure:"enabled"`
+	// MaxSize is the maximum number of logs batches kept in the queue. Once reached, new batches are discarded.
+	// Default value is 10000.
+	MaxSize int `mapstructure:"max_size"`
+}
+
+// DeadLetterConfig defines configuration for writing discarded logs to a rotating file as OTLP-JSON.
+type DeadLetterConfig struct {
+	// Enabled indicates whether discarded logs are written to Path. Default is false.
+	Enabled bool `mapstructure:"enabled"`
+	// Path is the file discarded logs are written to. Rotated files are kept next to it.
+	Path string `mapstructure:"path"`
+	// MaxSize is the maximum size in kilobytes of the file before it gets rotated. Default value is 10240.
+	MaxSize int `mapstructure:"max_size"`
+	// MaxBackups is the maximum number of rotated files to keep. All are kept if MaxBackups == 0.
+	// Default value is 10.
+	MaxBackups int `mapstructure:"max_backups"`
+	// Compress indicates whether rotated files are compressed with gzip. Default is false.
+	Compress bool `mapstructure:"compress"`
+}
+
+// Validate checks the persistent queue, dead letter and circuit breaker settings.
+func (cfg *Config) Validate() error {
+	if cfg.PersistentQueue.Enabled && !cfg.Enabled {
+		return errors.New("persistent_queue requires retry_on_failure to be enabled")
+	}
+	if cfg.PersistentQueue.Enabled && cfg.PersistentQueue.MaxSize <= 0 {
+		return errors.New("persistent_queue max_size must be positive")
+	}
+	// The breaker is consulted between the attempts of the retry loop, it has nothing to pause without retries.
+	if cfg.CircuitBreaker.Enabled && !cfg.Enabled {
+		return errors.New("circuit_breaker requires retry_on_failure to be enabled")
+	}
+	if cfg.CircuitBreaker.Enabled && cfg.CircuitBreaker.FailureThreshold <= 0 {
+		return errors.New("circuit_breaker failure_threshold must be positive")
+	}
+	if cfg.CircuitBreaker.Enabled && cfg.CircuitBreaker.OpenDuration <= 0 {
+		return errors.New("circuit_breaker open_duration must be positive")
+	}
+	if cfg.DeadLetter.Enabled && cfg.DeadLetter.Path == "" {
+		return errors.New("dead_letter requires a path")
+	}
+	if cfg.DeadLetter.Enabled && cfg.DeadLetter.MaxSize <= 0 {
+		return errors.New("dead_letter max_size must be positive")
+	}
+	return nil
 }
 
 // NewDefaultConfig returns the default Config.
@@ -29,5 +104,19 @@ func NewDefaultConfig() Config {
 		InitialInterval: 1 * time.Second,
 		MaxInterval:     30 * time.Second,
 		MaxElapsedTime:  5 * time.Minute,
+		PersistentQueue: PersistentQueueConfig{
+			Enabled: false,
+			MaxSize: 10000,
+		},
+		DeadLetter: DeadLetterConfig{
+			Enabled:    false,
+			MaxSize:    10240,
+			MaxBackups: 10,
+		},
+		CircuitBreaker: CircuitBreakerConfig{
+			Enabled:          false,
+			FailureThreshold: 5,
+			OpenDuration:     30 * time.Second,
+		},
 	}
 }
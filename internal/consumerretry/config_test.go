@@ -0,0 +1,25 @@
+package consumerretry
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigValidate(t *testing.T) {
+	cfg := NewDefaultConfig()
+	require.NoError(t, cfg.Validate())
+
+	cfg.CircuitBreaker.Enabled = true
+	require.EqualError(t, cfg.Validate(), "circuit_breaker requires retry_on_failure to be enabled")
+	cfg.Enabled = true
+	require.NoError(t, cfg.Validate())
+	cfg.CircuitBreaker.FailureThreshold = 0
+	require.Error(t, cfg.Validate())
+
+	cfg = NewDefaultConfig()
+	cfg.PersistentQueue.Enabled = true
+	require.EqualError(t, cfg.Validate(), "persistent_queue requires retry_on_failure to be enabled")
+	cfg.Enabled = true
+	require.NoError(t, cfg.Validate())
+}
@@ -0,0 +1,114 @@
+package consumerretry
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/fsgonz/otelnetstatsreceiver/internal/lumberjack"
+	"go.opentelemetry.io/collector/pdata/plog"
+)
+
+const (
+	// ReasonPermanentError is recorded for logs refused with a permanent error.
+	ReasonPermanentError = "permanent_error"
+	// ReasonMaxElapsedTime is recorded for logs that could not be delivered within MaxElapsedTime.
+	ReasonMaxElapsedTime = "max_elapsed_time"
+	// ReasonQueueFull is recorded for logs that did not fit in the persistent queue.
+	ReasonQueueFull = "queue_full"
+	// ReasonRetryDisabled is recorded for logs refused while retry_on_failure is disabled.
+	ReasonRetryDisabled = "retry_disabled"
+	// ReasonCancelled is recorded for logs whose retries were interrupted by shutdown.
+	ReasonCancelled = "cancelled"
+)
+
+// DeadLetterRecord is a line of a dead letter file.
+type DeadLetterRecord struct {
+	// Time is when the logs were dropped.
+	Time time.Time `json:"time"`
+	// Reason is why the logs were dropped.
+	Reason string `json:"reason"`
+	// Error is the last error returned by the next consumer.
+	Error string `json:"error"`
+	// Logs are the dropped logs in OTLP-JSON.
+	Logs json.RawMessage `json:"logs"`
+}
+
+// deadLetterWriter writes dropped logs to a rotating file.
+type deadLetterWriter struct {
+	writer    *lumberjack.Logger
+	marshaler plog.JSONMarshaler
+}
+
+func newDeadLetterWriter(cfg DeadLetterConfig) *deadLetterWriter {
+	return &deadLetterWriter{
+		writer: &lumberjack.Logger{
+			Filename:   cfg.Path,
+			MaxSize:    cfg.MaxSize,
+			MaxBackups: cfg.MaxBackups,
+			Compress:   cfg.Compress,
+		},
+	}
+}
+
+func (w *deadLetterWriter) write(logs plog.Logs, reason string, cause error) error {
+	data, err := w.marshaler.MarshalLogs(logs)
+	if err != nil {
+		return err
+	}
+	record := DeadLetterRecord{
+		Time:   time.Now().UTC(),
+		Reason: reason,
+		Logs:   data,
+	}
+	if cause != nil {
+		record.Error = cause.Error()
+	}
+
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	_, err = w.writer.Write(append(line, '\n'))
+	return err
+}
+
+func (w *deadLetterWriter) close() error {
+	return w.writer.Close()
+}
+
+// ReadDeadLetters calls fn for every record of a dead letter file, gzip compressed or not,
+// stopping at the first error.
+func ReadDeadLetters(r io.Reader, fn func(DeadLetterRecord) error) error {
+	reader := bufio.NewReader(r)
+	if magic, err := reader.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(reader)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		reader = bufio.NewReader(gz)
+	}
+
+	for {
+		line, err := reader.ReadString('\n')
+		if strings.TrimSpace(line) != "" {
+			var record DeadLetterRecord
+			if jsonErr := json.Unmarshal([]byte(line), &record); jsonErr != nil {
+				return jsonErr
+			}
+			if fnErr := fn(record); fnErr != nil {
+				return fnErr
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
@@ -7,28 +7,115 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/cenkalti/backoff/v4"
+	"go.opentelemetry.io/collector/component"
 	"go.opentelemetry.io/collector/consumer"
 	"go.opentelemetry.io/collector/consumer/consumererror"
+	"go.opentelemetry.io/collector/extension/experimental/storage"
 	"go.opentelemetry.io/collector/pdata/plog"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 )
 
+// Logs is a consumer.Logs that retries sending logs to the next consumer.
+type Logs interface {
+	consumer.Logs
+	// Start starts replaying the persistent queue kept in the given client, if enabled.
+	Start(ctx context.Context, client storage.Client) error
+	// Shutdown stops the replay, closes the persistent queue client and stops reporting the gauges.
+	Shutdown(ctx context.Context) error
+}
+
 type logsConsumer struct {
 	consumer.Logs
 	cfg    Config
+	id     component.ID
+	set    component.TelemetrySettings
 	logger *zap.Logger
+
+	queue      *persistentQueue
+	deadLetter *deadLetterWriter
+	breaker    *circuitBreaker
+	telemetry  *retryTelemetry
+	cancel     context.CancelFunc
+	wg         sync.WaitGroup
+}
+
+func NewLogs(config Config, id component.ID, set component.TelemetrySettings, next consumer.Logs) (Logs, error) {
+	telemetry, err := newRetryTelemetry(set, id)
+	if err != nil {
+		return nil, err
+	}
+	lc := &logsConsumer{
+		Logs:      next,
+		cfg:       config,
+		id:        id,
+		set:       set,
+		logger:    set.Logger,
+		telemetry: telemetry,
+	}
+	if config.DeadLetter.Enabled {
+		lc.deadLetter = newDeadLetterWriter(config.DeadLetter)
+	}
+	if config.CircuitBreaker.Enabled {
+		lc.breaker = newCircuitBreaker(config.CircuitBreaker)
+	}
+	return lc, nil
+}
+
+func (lc *logsConsumer) Start(ctx context.Context, client storage.Client) error {
+	if !lc.cfg.Enabled || !lc.cfg.PersistentQueue.Enabled {
+		return nil
+	}
+	if client == nil {
+		return errors.New("persistent queue requires a storage extension")
+	}
+
+	queue := newPersistentQueue(client, lc.cfg.PersistentQueue.MaxSize)
+	if err := queue.load(ctx); err != nil {
+		return fmt.Errorf("load persistent queue: %w", err)
+	}
+	if err := lc.telemetry.registerQueue(queue); err != nil {
+		return fmt.Errorf("persistent queue metrics: %w", err)
+	}
+	lc.queue = queue
+	if size := queue.size(); size > 0 {
+		lc.logger.Info("Replaying persistent queue", zap.Uint64("queue_size", size))
+	}
+
+	rctx, cancel := context.WithCancel(context.Background())
+	lc.cancel = cancel
+	lc.wg.Add(1)
+	go lc.replayLoop(rctx)
+	return nil
 }
 
-func NewLogs(config Config, logger *zap.Logger, next consumer.Logs) consumer.Logs {
-	return &logsConsumer{
-		Logs:   next,
-		cfg:    config,
-		logger: logger,
+func (lc *logsConsumer) Shutdown(ctx context.Context) error {
+	var err error
+	if lc.queue != nil {
+		lc.cancel()
+		lc.wg.Wait()
+		err = lc.queue.client.Close(ctx)
+	}
+	if lc.deadLetter != nil {
+		err = errors.Join(err, lc.deadLetter.close())
+	}
+	return errors.Join(err, lc.telemetry.shutdown())
+}
+
+// drop records logs that are discarded and writes them to the dead letter file, if enabled.
+func (lc *logsConsumer) drop(ctx context.Context, logs plog.Logs, reason string, cause error) {
+	lc.telemetry.recordDropped(ctx, logs.LogRecordCount(), reason)
+	if lc.deadLetter == nil {
+		return
+	}
+	if err := lc.deadLetter.write(logs, reason, cause); err != nil {
+		lc.logger.Error("Could not write dropped logs to the dead letter file", zap.Error(err),
+			zap.String("reason", reason), zap.Int("dropped_items", logs.LogRecordCount()))
 	}
 }
 
@@ -38,14 +125,125 @@ func (lc *logsConsumer) ConsumeLogs(ctx context.Context, logs plog.Logs) error {
 		if err != nil {
 			lc.logger.Error("ConsumeLogs() failed. "+
 				"Enable retry_on_failure to slow down reading logs and avoid dropping.", zap.Error(err))
+			lc.drop(ctx, logs, ReasonRetryDisabled, err)
 		}
 		return err
 	}
 
+	if lc.queue == nil {
+		return lc.consumeWithRetry(ctx, logs, lc.cfg.MaxElapsedTime)
+	}
+
+	// Keep the order of delivery: while older logs are queued, new ones are queued behind them.
+	if lc.queue.size() > 0 {
+		return lc.enqueue(ctx, logs)
+	}
+
+	err := lc.consumeWithRetry(ctx, logs, lc.cfg.MaxElapsedTime)
+	if err == nil || consumererror.IsPermanent(err) {
+		return err
+	}
+	var retryableErr consumererror.Logs
+	if errors.As(err, &retryableErr) {
+		logs = retryableErr.Data()
+	}
+	return lc.enqueue(ctx, logs)
+}
+
+// enqueue stores logs in the persistent queue. The queue is written even if ctx is
+// cancelled, as that is the last chance to keep the logs on shutdown.
+func (lc *logsConsumer) enqueue(ctx context.Context, logs plog.Logs) error {
+	if err := lc.queue.push(context.WithoutCancel(ctx), logs); err != nil {
+		lc.logger.Error("Could not add logs to the persistent queue. Dropping data.",
+			zap.Error(err), zap.Int("dropped_items", logs.LogRecordCount()))
+		lc.drop(ctx, logs, ReasonQueueFull, err)
+		return err
+	}
+	lc.logger.Debug("Logs added to the persistent queue", zap.Int("logs_count", logs.LogRecordCount()))
+	return nil
+}
+
+// replayLoop sends the queued logs in order, retrying each batch until it is delivered. Storage
+// errors are retried with backoff, so that the queue is only given up on at shutdown.
+func (lc *logsConsumer) replayLoop(ctx context.Context) {
+	defer lc.wg.Done()
+
+	storageBackoff := backoff.ExponentialBackOff{
+		InitialInterval:     lc.cfg.InitialInterval,
+		MaxInterval:         lc.cfg.MaxInterval,
+		RandomizationFactor: backoff.DefaultRandomizationFactor,
+		Multiplier:          backoff.DefaultMultiplier,
+		Stop:                backoff.Stop,
+		Clock:               backoff.SystemClock,
+	}
+	storageBackoff.Reset()
+	// storageFailed logs err and waits before the storage is used again, returning false once ctx is done.
+	storageFailed := func(msg string, err error) bool {
+		delay := storageBackoff.NextBackOff()
+		lc.logger.Error(msg, zap.Error(err), zap.Duration("retry_in", delay))
+		select {
+		case <-ctx.Done():
+			return false
+		case <-time.After(delay):
+			return true
+		}
+	}
+	// pop removes the head of the queue, retrying until it succeeds or ctx is done.
+	pop := func() bool {
+		for {
+			// The head is removed even if ctx is cancelled, so that delivered logs are not replayed.
+			err := lc.queue.pop(context.WithoutCancel(ctx))
+			if err == nil {
+				return true
+			}
+			if !storageFailed("Could not remove logs from the persistent queue", err) {
+				return false
+			}
+		}
+	}
+
+	for {
+		logs, ok, err := lc.queue.peek(ctx)
+		switch {
+		case err != nil && ok:
+			lc.logger.Error("Could not read logs from the persistent queue. Dropping data.", zap.Error(err))
+			if !pop() {
+				return
+			}
+			continue
+		case err != nil:
+			if !storageFailed("Could not read the persistent queue", err) {
+				return
+			}
+			continue
+		case !ok:
+			select {
+			case <-ctx.Done():
+				return
+			case <-lc.queue.notify:
+			}
+			continue
+		}
+		storageBackoff.Reset()
+
+		// The queue is never given up on, retries only stop on shutdown or on a permanent error,
+		// in which case consumeWithRetry drops the logs.
+		if err = lc.consumeWithRetry(ctx, logs, 0); err != nil && ctx.Err() != nil {
+			return
+		}
+		if !pop() {
+			return
+		}
+	}
+}
+
+// consumeWithRetry sends logs to the next consumer, retrying with exponential backoff
+// until maxElapsedTime is reached. It never stops if maxElapsedTime == 0.
+func (lc *logsConsumer) consumeWithRetry(ctx context.Context, logs plog.Logs, maxElapsedTime time.Duration) error {
 	// Do not use NewExponentialBackOff since it calls Reset and the code here must
 	// call Reset after changing the InitialInterval (this saves an unnecessary call to Now).
 	expBackoff := backoff.ExponentialBackOff{
-		MaxElapsedTime:      lc.cfg.MaxElapsedTime,
+		MaxElapsedTime:      maxElapsedTime,
 		InitialInterval:     lc.cfg.InitialInterval,
 		MaxInterval:         lc.cfg.MaxInterval,
 		RandomizationFactor: backoff.DefaultRandomizationFactor,
@@ -54,17 +252,29 @@ func (lc *logsConsumer) ConsumeLogs(ctx context.Context, logs plog.Logs) error {
 		Clock:               backoff.SystemClock,
 	}
 	expBackoff.Reset()
+	defer lc.telemetry.clearBackoff()
 
 	span := trace.SpanFromContext(ctx)
 	retryNum := int64(0)
 	retryableErr := consumererror.Logs{}
 	for {
+		if lc.breaker != nil {
+			blocked, err := lc.breaker.wait(ctx)
+			lc.telemetry.recordBlocked(ctx, blocked)
+			if err != nil {
+				return lc.cancelled(ctx, fmt.Errorf("circuit breaker is open: %w", err), logs)
+			}
+		}
+
 		span.AddEvent(
 			"Sending logs.",
 			trace.WithAttributes(attribute.Int64("retry_num", retryNum)))
 
 		err := lc.Logs.ConsumeLogs(ctx, logs)
 		if err == nil {
+			if lc.breaker != nil && lc.breaker.success() {
+				lc.logger.Info("ConsumeLogs() succeeded. Resuming sending logs.")
+			}
 			return nil
 		}
 
@@ -74,6 +284,7 @@ func (lc *logsConsumer) ConsumeLogs(ctx context.Context, logs plog.Logs) error {
 				zap.Error(err),
 				zap.Int("dropped_items", logs.LogRecordCount()),
 			)
+			lc.drop(ctx, logs, ReasonPermanentError, err)
 			return err
 		}
 
@@ -81,11 +292,24 @@ func (lc *logsConsumer) ConsumeLogs(ctx context.Context, logs plog.Logs) error {
 			logs = retryableErr.Data()
 		}
 
-		// TODO: take delay from the error once it is available in the consumererror package.
+		// The delay requested by the downstream consumer takes precedence over the backoff.
 		backoffDelay := expBackoff.NextBackOff()
+		retryDelay, throttled := retryAfterDelay(err)
+		if throttled && backoffDelay != backoff.Stop {
+			backoffDelay = retryDelay
+		}
+		if lc.breaker != nil && backoffDelay != backoff.Stop && lc.breaker.failure(retryDelay) {
+			lc.logger.Warn("ConsumeLogs() keeps failing. Pausing sending logs.", zap.Error(err))
+		}
 		if backoffDelay == backoff.Stop {
+			if lc.queue != nil {
+				lc.logger.Warn("Max elapsed time expired. Queueing data.", zap.Error(err), zap.Int("queued_items",
+					logs.LogRecordCount()))
+				return consumererror.NewLogs(err, logs)
+			}
 			lc.logger.Error("Max elapsed time expired. Dropping data.", zap.Error(err), zap.Int("dropped_items",
 				logs.LogRecordCount()))
+			lc.drop(ctx, logs, ReasonMaxElapsedTime, err)
 			return err
 		}
 
@@ -104,10 +328,25 @@ func (lc *logsConsumer) ConsumeLogs(ctx context.Context, logs plog.Logs) error {
 		retryNum++
 
 		// back-off, but get interrupted when shutting down or request is cancelled or timed out.
+		lc.telemetry.recordBackoff(backoffDelay)
+		waitStart := time.Now()
 		select {
 		case <-ctx.Done():
-			return fmt.Errorf("context is cancelled or timed out %w", err)
+			lc.telemetry.recordBlocked(ctx, time.Since(waitStart))
+			return lc.cancelled(ctx, fmt.Errorf("context is cancelled or timed out %w", err), logs)
 		case <-time.After(backoffDelay):
 		}
+		lc.telemetry.recordBlocked(ctx, time.Since(waitStart))
+		lc.telemetry.recordRetry(ctx, logs.LogRecordCount())
+	}
+}
+
+// cancelled returns the error for logs whose delivery got interrupted, carrying the logs
+// so that they can be queued when the persistent queue is enabled. Otherwise they are dropped.
+func (lc *logsConsumer) cancelled(ctx context.Context, err error, logs plog.Logs) error {
+	if lc.queue != nil {
+		return consumererror.NewLogs(err, logs)
 	}
+	lc.drop(ctx, logs, ReasonCancelled, err)
+	return err
 }
@@ -0,0 +1,314 @@
+package consumerretry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/consumer/consumererror"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/extension/experimental/storage"
+	"go.opentelemetry.io/collector/pdata/plog"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+func TestPersistentQueue(t *testing.T) {
+	cfg := NewDefaultConfig()
+	cfg.Enabled = true
+	cfg.InitialInterval = time.Millisecond
+	cfg.MaxInterval = time.Millisecond
+	cfg.MaxElapsedTime = 5 * time.Millisecond
+	cfg.PersistentQueue.Enabled = true
+	cfg.PersistentQueue.MaxSize = 2
+
+	client := newMemoryClient()
+	id := component.MustNewID("otelnetstatsreceiver")
+
+	// The downstream fails, so the batches outliving MaxElapsedTime are queued until the queue is full.
+	failing, err := NewLogs(cfg, id, componenttest.NewNopTelemetrySettings(), consumertest.NewErr(errors.New("unavailable")))
+	require.NoError(t, err)
+	require.NoError(t, failing.Start(context.Background(), client))
+	require.NoError(t, failing.ConsumeLogs(context.Background(), testLogs("first")))
+	require.NoError(t, failing.ConsumeLogs(context.Background(), testLogs("second")))
+	require.ErrorIs(t, failing.ConsumeLogs(context.Background(), testLogs("third")), errQueueFull)
+	require.NoError(t, failing.Shutdown(context.Background()))
+
+	// After a restart with a healthy downstream, the queued batches are replayed in order.
+	sink := new(consumertest.LogsSink)
+	healthy, err := NewLogs(cfg, id, componenttest.NewNopTelemetrySettings(), sink)
+	require.NoError(t, err)
+	require.NoError(t, healthy.Start(context.Background(), client))
+	require.Eventually(t, func() bool { return sink.LogRecordCount() == 2 }, time.Second, time.Millisecond)
+	require.NoError(t, healthy.ConsumeLogs(context.Background(), testLogs("fourth")))
+	require.NoError(t, healthy.Shutdown(context.Background()))
+
+	var bodies []string
+	for _, logs := range sink.AllLogs() {
+		bodies = append(bodies, logs.ResourceLogs().At(0).ScopeLogs().At(0).LogRecords().At(0).Body().Str())
+	}
+	require.Equal(t, []string{"first", "second", "fourth"}, bodies)
+}
+
+func TestPersistentQueueStorageErrors(t *testing.T) {
+	cfg := NewDefaultConfig()
+	cfg.Enabled = true
+	cfg.InitialInterval = time.Millisecond
+	cfg.MaxInterval = time.Millisecond
+	cfg.MaxElapsedTime = 5 * time.Millisecond
+	cfg.PersistentQueue.Enabled = true
+
+	client := newMemoryClient()
+	id := component.MustNewID("otelnetstatsreceiver")
+	failing, err := NewLogs(cfg, id, componenttest.NewNopTelemetrySettings(), consumertest.NewErr(errors.New("unavailable")))
+	require.NoError(t, err)
+	require.NoError(t, failing.Start(context.Background(), client))
+	require.NoError(t, failing.ConsumeLogs(context.Background(), testLogs("first")))
+	require.NoError(t, failing.Shutdown(context.Background()))
+
+	// Reading and removing the queued batch fail a few times, the replay keeps retrying.
+	client.itemFailures = 5
+	sink := new(consumertest.LogsSink)
+	healthy, err := NewLogs(cfg, id, componenttest.NewNopTelemetrySettings(), sink)
+	require.NoError(t, err)
+	require.NoError(t, healthy.Start(context.Background(), client))
+	require.Eventually(t, func() bool { return sink.LogRecordCount() == 1 }, 5*time.Second, time.Millisecond)
+	require.Eventually(t, func() bool { return healthy.(*logsConsumer).queue.size() == 0 }, 5*time.Second, time.Millisecond)
+	require.NoError(t, healthy.Shutdown(context.Background()))
+}
+
+func TestDeadLetter(t *testing.T) {
+	cfg := NewDefaultConfig()
+	cfg.Enabled = true
+	cfg.DeadLetter.Enabled = true
+	cfg.DeadLetter.Path = filepath.Join(t.TempDir(), "dead_letter.log")
+
+	next := consumertest.NewErr(consumererror.NewPermanent(errors.New("invalid")))
+	lc, err := NewLogs(cfg, component.MustNewID("otelnetstatsreceiver"), componenttest.NewNopTelemetrySettings(), next)
+	require.NoError(t, err)
+	require.NoError(t, lc.Start(context.Background(), nil))
+	require.Error(t, lc.ConsumeLogs(context.Background(), testLogs("dropped")))
+	require.NoError(t, lc.Shutdown(context.Background()))
+
+	f, err := os.Open(cfg.DeadLetter.Path)
+	require.NoError(t, err)
+	defer f.Close()
+
+	var records []DeadLetterRecord
+	require.NoError(t, ReadDeadLetters(f, func(record DeadLetterRecord) error {
+		records = append(records, record)
+		return nil
+	}))
+	require.Len(t, records, 1)
+	require.Equal(t, ReasonPermanentError, records[0].Reason)
+	require.Equal(t, "Permanent error: invalid", records[0].Error)
+
+	unmarshaler := plog.JSONUnmarshaler{}
+	logs, err := unmarshaler.UnmarshalLogs(records[0].Logs)
+	require.NoError(t, err)
+	require.Equal(t, "dropped", logs.ResourceLogs().At(0).ScopeLogs().At(0).LogRecords().At(0).Body().Str())
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	cfg := NewDefaultConfig()
+	cfg.Enabled = true
+	cfg.InitialInterval = time.Millisecond
+	cfg.MaxInterval = time.Millisecond
+	cfg.CircuitBreaker.Enabled = true
+	cfg.CircuitBreaker.FailureThreshold = 2
+	cfg.CircuitBreaker.OpenDuration = 50 * time.Millisecond
+
+	next := &flakyConsumer{failures: 2, err: errors.New("unavailable")}
+	lc, err := NewLogs(cfg, component.MustNewID("otelnetstatsreceiver"), componenttest.NewNopTelemetrySettings(), next)
+	require.NoError(t, err)
+
+	start := time.Now()
+	require.NoError(t, lc.ConsumeLogs(context.Background(), testLogs("paused")))
+	require.GreaterOrEqual(t, time.Since(start), cfg.CircuitBreaker.OpenDuration)
+	require.Equal(t, 3, next.calls)
+}
+
+func TestTelemetry(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	set := componenttest.NewNopTelemetrySettings()
+	set.MeterProvider = sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+
+	cfg := NewDefaultConfig()
+	cfg.Enabled = true
+	cfg.InitialInterval = time.Millisecond
+	cfg.MaxElapsedTime = time.Second
+	next := &flakyConsumer{failures: 2, err: errors.New("unavailable")}
+	lc, err := NewLogs(cfg, component.MustNewID("otelnetstatsreceiver"), set, next)
+	require.NoError(t, err)
+	require.NoError(t, lc.ConsumeLogs(context.Background(), testLogs("retried")))
+
+	next = &flakyConsumer{failures: 1, err: consumererror.NewPermanent(errors.New("invalid"))}
+	lc, err = NewLogs(cfg, component.MustNewID("otelnetstatsreceiver"), set, next)
+	require.NoError(t, err)
+	require.Error(t, lc.ConsumeLogs(context.Background(), testLogs("dropped")))
+
+	var rm metricdata.ResourceMetrics
+	require.NoError(t, reader.Collect(context.Background(), &rm))
+	metrics := map[string]metricdata.Aggregation{}
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			metrics[m.Name] = m.Data
+		}
+	}
+
+	require.Equal(t, int64(2), metrics["otelnetstats_retry_attempts"].(metricdata.Sum[int64]).DataPoints[0].Value)
+	require.Equal(t, int64(2), metrics["otelnetstats_retry_items"].(metricdata.Sum[int64]).DataPoints[0].Value)
+	require.Positive(t, metrics["otelnetstats_retry_blocked_time"].(metricdata.Sum[float64]).DataPoints[0].Value)
+	require.Zero(t, metrics["otelnetstats_retry_backoff"].(metricdata.Gauge[float64]).DataPoints[0].Value)
+
+	dropped := metrics["otelnetstats_retry_dropped_items"].(metricdata.Sum[int64]).DataPoints
+	require.Len(t, dropped, 1)
+	require.Equal(t, int64(1), dropped[0].Value)
+	reason, _ := dropped[0].Attributes.Value("reason")
+	require.Equal(t, ReasonPermanentError, reason.AsString())
+}
+
+func TestBackoffTelemetry(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	set := componenttest.NewNopTelemetrySettings()
+	set.MeterProvider = sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	backoff := func() []metricdata.DataPoint[float64] {
+		var rm metricdata.ResourceMetrics
+		require.NoError(t, reader.Collect(context.Background(), &rm))
+		for _, sm := range rm.ScopeMetrics {
+			for _, m := range sm.Metrics {
+				if m.Name == "otelnetstats_retry_backoff" {
+					return m.Data.(metricdata.Gauge[float64]).DataPoints
+				}
+			}
+		}
+		return nil
+	}
+
+	cfg := NewDefaultConfig()
+	cfg.Enabled = true
+	cfg.InitialInterval = time.Hour
+	cfg.MaxInterval = time.Hour
+	cfg.MaxElapsedTime = 0
+	next := &flakyConsumer{failures: 1, err: errors.New("unavailable")}
+	lc, err := NewLogs(cfg, component.MustNewID("otelnetstatsreceiver"), set, next)
+	require.NoError(t, err)
+
+	// The delay is reported while waiting for it, and cleared once the logs are dropped.
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- lc.ConsumeLogs(ctx, testLogs("waiting"))
+	}()
+	require.Eventually(t, func() bool {
+		points := backoff()
+		return len(points) == 1 && points[0].Value >= (30*time.Minute).Seconds()
+	}, 5*time.Second, 10*time.Millisecond)
+	cancel()
+	require.Error(t, <-done)
+	require.Zero(t, backoff()[0].Value)
+
+	require.NoError(t, lc.Shutdown(context.Background()))
+	require.Empty(t, backoff(), "the gauge is no longer reported after shutdown")
+}
+
+func TestRetryAfterDelay(t *testing.T) {
+	st, err := status.New(codes.Unavailable, "throttled").WithDetails(&errdetails.RetryInfo{
+		RetryDelay: durationpb.New(3 * time.Second),
+	})
+	require.NoError(t, err)
+
+	delay, ok := retryAfterDelay(fmt.Errorf("export failed: %w", st.Err()))
+	require.True(t, ok)
+	require.Equal(t, 3*time.Second, delay)
+
+	_, ok = retryAfterDelay(errors.New("unavailable"))
+	require.False(t, ok)
+}
+
+// flakyConsumer fails the first calls with err.
+type flakyConsumer struct {
+	consumertest.LogsSink
+	failures int
+	calls    int
+	err      error
+}
+
+func (c *flakyConsumer) ConsumeLogs(ctx context.Context, logs plog.Logs) error {
+	c.calls++
+	if c.calls <= c.failures {
+		return c.err
+	}
+	return c.LogsSink.ConsumeLogs(ctx, logs)
+}
+
+func testLogs(body string) plog.Logs {
+	logs := plog.NewLogs()
+	logs.ResourceLogs().AppendEmpty().ScopeLogs().AppendEmpty().LogRecords().AppendEmpty().Body().SetStr(body)
+	return logs
+}
+
+// memoryClient is a storage.Client keeping its values in memory, which survive Close.
+type memoryClient struct {
+	mu   sync.Mutex
+	data map[string][]byte
+	// itemFailures is the number of next operations on queue items that fail.
+	itemFailures int
+}
+
+func newMemoryClient() *memoryClient {
+	return &memoryClient{data: map[string][]byte{}}
+}
+
+func (c *memoryClient) Get(ctx context.Context, key string) ([]byte, error) {
+	op := storage.GetOperation(key)
+	err := c.Batch(ctx, op)
+	return op.Value, err
+}
+
+func (c *memoryClient) Set(ctx context.Context, key string, value []byte) error {
+	return c.Batch(ctx, storage.SetOperation(key, value))
+}
+
+func (c *memoryClient) Delete(ctx context.Context, key string) error {
+	return c.Batch(ctx, storage.DeleteOperation(key))
+}
+
+func (c *memoryClient) Batch(_ context.Context, ops ...storage.Operation) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, op := range ops {
+		if c.itemFailures > 0 && strings.HasPrefix(op.Key, queueItemKeyPrefix) {
+			c.itemFailures--
+			return errors.New("storage unavailable")
+		}
+	}
+	for _, op := range ops {
+		switch op.Type {
+		case storage.Get:
+			op.Value = c.data[op.Key]
+		case storage.Set:
+			c.data[op.Key] = op.Value
+		case storage.Delete:
+			delete(c.data, op.Key)
+		}
+	}
+	return nil
+}
+
+func (c *memoryClient) Close(context.Context) error {
+	return nil
+}
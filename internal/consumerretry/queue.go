@@ -0,0 +1,192 @@
+package consumerretry
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/collector/extension/experimental/storage"
+	"go.opentelemetry.io/collector/pdata/plog"
+)
+
+const (
+	queueHeadKey       = "retry_queue_head"
+	queueTailKey       = "retry_queue_tail"
+	queueItemKeyPrefix = "retry_queue_item_"
+)
+
+var errQueueFull = errors.New("persistent queue is full")
+
+// persistentQueue is a FIFO of plog.Logs kept in a storage client, so that
+// undelivered logs survive restarts. Items are stored under increasing indexes
+// between head (the oldest item) and tail (the next index to write).
+type persistentQueue struct {
+	client  storage.Client
+	maxSize uint64
+
+	mu   sync.Mutex
+	head uint64
+	tail uint64
+	// headTime is the time the oldest item was enqueued, zero if unknown.
+	headTime time.Time
+
+	// notify receives a value whenever an item is pushed.
+	notify chan struct{}
+
+	marshaler   plog.ProtoMarshaler
+	unmarshaler plog.ProtoUnmarshaler
+}
+
+func newPersistentQueue(client storage.Client, maxSize int) *persistentQueue {
+	return &persistentQueue{
+		client:  client,
+		maxSize: uint64(maxSize),
+		notify:  make(chan struct{}, 1),
+	}
+}
+
+// load restores the queue indexes from storage.
+func (q *persistentQueue) load(ctx context.Context) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	head, err := q.getIndex(ctx, queueHeadKey)
+	if err != nil {
+		return err
+	}
+	tail, err := q.getIndex(ctx, queueTailKey)
+	if err != nil {
+		return err
+	}
+	if tail < head {
+		return fmt.Errorf("corrupted persistent queue: head %d is after tail %d", head, tail)
+	}
+	q.head, q.tail = head, tail
+	return nil
+}
+
+// size returns the number of queued items.
+func (q *persistentQueue) size() uint64 {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.tail - q.head
+}
+
+// oldestAge returns how long the oldest item has been queued, zero if the queue is empty.
+func (q *persistentQueue) oldestAge() time.Duration {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.tail == q.head || q.headTime.IsZero() {
+		return 0
+	}
+	return time.Since(q.headTime)
+}
+
+// push appends logs to the tail of the queue.
+func (q *persistentQueue) push(ctx context.Context, logs plog.Logs) error {
+	data, err := q.marshaler.MarshalLogs(logs)
+	if err != nil {
+		return err
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.tail-q.head >= q.maxSize {
+		return errQueueFull
+	}
+
+	now := time.Now()
+	item := make([]byte, 8, 8+len(data))
+	binary.BigEndian.PutUint64(item, uint64(now.UnixNano()))
+	item = append(item, data...)
+
+	err = q.client.Batch(ctx,
+		storage.SetOperation(itemKey(q.tail), item),
+		storage.SetOperation(queueTailKey, indexValue(q.tail+1)),
+	)
+	if err != nil {
+		return err
+	}
+	if q.tail == q.head {
+		q.headTime = now
+	}
+	q.tail++
+
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// peek returns the oldest item without removing it. ok is false if the queue is empty.
+func (q *persistentQueue) peek(ctx context.Context) (logs plog.Logs, ok bool, err error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.tail == q.head {
+		return plog.Logs{}, false, nil
+	}
+
+	item, err := q.client.Get(ctx, itemKey(q.head))
+	if err != nil {
+		return plog.Logs{}, false, err
+	}
+	if len(item) < 8 {
+		return plog.Logs{}, true, fmt.Errorf("corrupted persistent queue item %d", q.head)
+	}
+	q.headTime = time.Unix(0, int64(binary.BigEndian.Uint64(item[:8])))
+
+	logs, err = q.unmarshaler.UnmarshalLogs(item[8:])
+	if err != nil {
+		return plog.Logs{}, true, fmt.Errorf("corrupted persistent queue item %d: %w", q.head, err)
+	}
+	return logs, true, nil
+}
+
+// pop removes the oldest item.
+func (q *persistentQueue) pop(ctx context.Context) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.tail == q.head {
+		return nil
+	}
+
+	err := q.client.Batch(ctx,
+		storage.DeleteOperation(itemKey(q.head)),
+		storage.SetOperation(queueHeadKey, indexValue(q.head+1)),
+	)
+	if err != nil {
+		return err
+	}
+	q.head++
+	q.headTime = time.Time{}
+	return nil
+}
+
+func (q *persistentQueue) getIndex(ctx context.Context, key string) (uint64, error) {
+	value, err := q.client.Get(ctx, key)
+	if err != nil || value == nil {
+		return 0, err
+	}
+	if len(value) != 8 {
+		return 0, fmt.Errorf("corrupted persistent queue index '%s'", key)
+	}
+	return binary.BigEndian.Uint64(value), nil
+}
+
+func itemKey(index uint64) string {
+	return queueItemKeyPrefix + strconv.FormatUint(index, 10)
+}
+
+func indexValue(index uint64) []byte {
+	value := make([]byte, 8)
+	binary.BigEndian.PutUint64(value, index)
+	return value
+}
@@ -0,0 +1,144 @@
+package consumerretry
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/noop"
+)
+
+const scopeName = "github.com/fsgonz/otelnetstatsreceiver/internal/consumerretry"
+
+// retryTelemetry records the self-metrics of the retrying consumer.
+type retryTelemetry struct {
+	meter metric.Meter
+	// attrs identifies the receiver in every measurement.
+	attrs attribute.Set
+
+	retries      metric.Int64Counter
+	retriedItems metric.Int64Counter
+	droppedItems metric.Int64Counter
+	blockedTime  metric.Float64Counter
+	backoff      metric.Float64ObservableGauge
+
+	// currentBackoff is the delay in nanoseconds before logs are sent again, zero when they are not waiting.
+	currentBackoff atomic.Int64
+	// registrations observe the gauges until the consumer shuts down.
+	registrations []metric.Registration
+}
+
+func newRetryTelemetry(set component.TelemetrySettings, id component.ID) (*retryTelemetry, error) {
+	meterProvider := set.MeterProvider
+	if meterProvider == nil {
+		meterProvider = noop.NewMeterProvider()
+	}
+	t := &retryTelemetry{
+		meter: meterProvider.Meter(scopeName),
+		attrs: attribute.NewSet(attribute.String("receiver", id.String())),
+	}
+
+	var err error
+	if t.retries, err = t.meter.Int64Counter("otelnetstats_retry_attempts",
+		metric.WithDescription("Number of times sending logs was retried."),
+		metric.WithUnit("{retries}")); err != nil {
+		return nil, err
+	}
+	if t.retriedItems, err = t.meter.Int64Counter("otelnetstats_retry_items",
+		metric.WithDescription("Number of log records sent again after a failure."),
+		metric.WithUnit("{records}")); err != nil {
+		return nil, err
+	}
+	if t.droppedItems, err = t.meter.Int64Counter("otelnetstats_retry_dropped_items",
+		metric.WithDescription("Number of log records dropped, by reason."),
+		metric.WithUnit("{records}")); err != nil {
+		return nil, err
+	}
+	if t.blockedTime, err = t.meter.Float64Counter("otelnetstats_retry_blocked_time",
+		metric.WithDescription("Time spent waiting to send logs again, in backoff or with the circuit breaker open."),
+		metric.WithUnit("s")); err != nil {
+		return nil, err
+	}
+	if t.backoff, err = t.meter.Float64ObservableGauge("otelnetstats_retry_backoff",
+		metric.WithDescription("Current delay before sending logs again, zero when they are being sent."),
+		metric.WithUnit("s")); err != nil {
+		return nil, err
+	}
+	registration, err := t.meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		o.ObserveFloat64(t.backoff, time.Duration(t.currentBackoff.Load()).Seconds(), metric.WithAttributeSet(t.attrs))
+		return nil
+	}, t.backoff)
+	if err != nil {
+		return nil, err
+	}
+	t.registrations = append(t.registrations, registration)
+	return t, nil
+}
+
+// shutdown stops observing the gauges, so that a stopped consumer no longer reports them.
+func (t *retryTelemetry) shutdown() error {
+	var err error
+	for _, registration := range t.registrations {
+		err = errors.Join(err, registration.Unregister())
+	}
+	t.registrations = nil
+	return err
+}
+
+// recordBackoff records that logs wait for backoff before being sent again.
+func (t *retryTelemetry) recordBackoff(backoff time.Duration) {
+	t.currentBackoff.Store(int64(backoff))
+}
+
+// recordRetry records a retry of items log records.
+func (t *retryTelemetry) recordRetry(ctx context.Context, items int) {
+	t.retries.Add(ctx, 1, metric.WithAttributeSet(t.attrs))
+	t.retriedItems.Add(ctx, int64(items), metric.WithAttributeSet(t.attrs))
+}
+
+// clearBackoff records that logs no longer wait, as they were sent, dropped or queued.
+func (t *retryTelemetry) clearBackoff() {
+	t.currentBackoff.Store(0)
+}
+
+// recordDropped records items log records dropped for reason.
+func (t *retryTelemetry) recordDropped(ctx context.Context, items int, reason string) {
+	t.droppedItems.Add(ctx, int64(items), metric.WithAttributeSet(t.attrs), metric.WithAttributes(attribute.String("reason", reason)))
+}
+
+// recordBlocked records time spent waiting to send logs.
+func (t *retryTelemetry) recordBlocked(ctx context.Context, blocked time.Duration) {
+	if blocked > 0 {
+		t.blockedTime.Add(ctx, blocked.Seconds(), metric.WithAttributeSet(t.attrs))
+	}
+}
+
+// registerQueue reports the depth of the persistent queue and the age of its oldest batch.
+func (t *retryTelemetry) registerQueue(queue *persistentQueue) error {
+	size, err := t.meter.Int64ObservableGauge("otelnetstats_retry_queue_size",
+		metric.WithDescription("Number of logs batches in the persistent retry queue."),
+		metric.WithUnit("{batches}"))
+	if err != nil {
+		return err
+	}
+	age, err := t.meter.Float64ObservableGauge("otelnetstats_retry_queue_oldest_age",
+		metric.WithDescription("Time the oldest logs batch has spent in the persistent retry queue."),
+		metric.WithUnit("s"))
+	if err != nil {
+		return err
+	}
+	registration, err := t.meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		o.ObserveInt64(size, int64(queue.size()), metric.WithAttributeSet(t.attrs))
+		o.ObserveFloat64(age, queue.oldestAge().Seconds(), metric.WithAttributeSet(t.attrs))
+		return nil
+	}, size, age)
+	if err != nil {
+		return err
+	}
+	t.registrations = append(t.registrations, registration)
+	return nil
+}
@@ -0,0 +1,271 @@
+// Package envelope encrypts the records written by samplers at rest with AES-GCM, either record by record
+// or whole files at once. Every ciphertext names the key it was encrypted with, so keys can be rotated
+// while the files encrypted with the previous ones can still be decrypted.
+package envelope
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+const (
+	// recordPrefix starts every encrypted record, followed by the key id, a colon and the base64 encoded
+	// nonce and ciphertext.
+	recordPrefix = "enc1:"
+	// fileMagic starts every encrypted file, followed by the length of the key id on one byte, the key id,
+	// the nonce and the ciphertext.
+	fileMagic = "OTNSENC1"
+)
+
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// Keyring holds the keys records and files are encrypted with, by key id.
+type Keyring struct {
+	keys   map[string]cipher.AEAD
+	active string
+}
+
+// LoadKeyring reads a keyring from a file. See ParseKeyring.
+func LoadKeyring(path string) (*Keyring, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("encryption keys: %w", err)
+	}
+	keyring, err := ParseKeyring(content)
+	if err != nil {
+		return nil, fmt.Errorf("encryption keys %s: %w", path, err)
+	}
+	return keyring, nil
+}
+
+// ParseKeyring parses a keyring with a key per line, its id followed by the base64 encoded 16, 24 or 32 bytes
+// AES key. Empty lines and lines starting with # are ignored. The last key encrypts, the previous ones are only
+// used to decrypt, so a key is rotated by appending the new one.
+func ParseKeyring(content []byte) (*Keyring, error) {
+	keyring := &Keyring{keys: map[string]cipher.AEAD{}}
+	for i, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("line %d: expected a key id and a key", i+1)
+		}
+		id := fields[0]
+		if len(id) > 255 || strings.Contains(id, ":") {
+			return nil, fmt.Errorf("line %d: key id must have at most 255 bytes and no colon", i+1)
+		}
+		if _, ok := keyring.keys[id]; ok {
+			return nil, fmt.Errorf("line %d: duplicate key id %s", i+1, id)
+		}
+		key, err := base64.StdEncoding.DecodeString(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", i+1, err)
+		}
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", i+1, err)
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", i+1, err)
+		}
+		keyring.keys[id] = aead
+		keyring.active = id
+	}
+	if keyring.active == "" {
+		return nil, errors.New("no key")
+	}
+	return keyring, nil
+}
+
+// ActiveKeyID returns the id of the key used to encrypt.
+func (k *Keyring) ActiveKeyID() string {
+	return k.active
+}
+
+// EncryptRecord encrypts every line of p, keeping the line breaks so that the encrypted records remain one per
+// line.
+func (k *Keyring) EncryptRecord(p []byte) ([]byte, error) {
+	aead := k.keys[k.active]
+	var encrypted []byte
+	for _, line := range bytes.SplitAfter(p, []byte{'\n'}) {
+		record := bytes.TrimSuffix(line, []byte{'\n'})
+		if len(record) == 0 {
+			encrypted = append(encrypted, line...)
+			continue
+		}
+		nonce, err := newNonce(aead)
+		if err != nil {
+			return nil, err
+		}
+		prefix := recordPrefix + k.active + ":"
+		sealed := aead.Seal(nonce, nonce, record, []byte(prefix))
+		encrypted = append(encrypted, prefix...)
+		encrypted = append(encrypted, base64.StdEncoding.EncodeToString(sealed)...)
+		encrypted = append(encrypted, line[len(record):]...)
+	}
+	return encrypted, nil
+}
+
+// DecryptRecord decrypts a line written by EncryptRecord.
+func (k *Keyring) DecryptRecord(line []byte) ([]byte, error) {
+	rest, ok := bytes.CutPrefix(bytes.TrimSpace(line), []byte(recordPrefix))
+	if !ok {
+		return nil, errors.New("record is not encrypted")
+	}
+	id, encoded, ok := bytes.Cut(rest, []byte{':'})
+	if !ok {
+		return nil, errors.New("malformed encrypted record")
+	}
+	aead, err := k.key(string(id))
+	if err != nil {
+		return nil, err
+	}
+	sealed, err := base64.StdEncoding.DecodeString(string(encoded))
+	if err != nil {
+		return nil, fmt.Errorf("malformed encrypted record: %w", err)
+	}
+	return open(aead, sealed, []byte(recordPrefix+string(id)+":"))
+}
+
+// EncryptFile writes the content of src encrypted as a whole to dst.
+func (k *Keyring) EncryptFile(dst io.Writer, src io.Reader) error {
+	content, err := io.ReadAll(src)
+	if err != nil {
+		return err
+	}
+	aead := k.keys[k.active]
+	nonce, err := newNonce(aead)
+	if err != nil {
+		return err
+	}
+	header := append(append([]byte(fileMagic), byte(len(k.active))), k.active...)
+	sealed := aead.Seal(nonce, nonce, content, header)
+	if _, err := dst.Write(header); err != nil {
+		return err
+	}
+	_, err = dst.Write(sealed)
+	return err
+}
+
+// DecryptFile writes the content of a file written by EncryptFile to dst.
+func (k *Keyring) DecryptFile(dst io.Writer, src io.Reader) error {
+	content, err := io.ReadAll(src)
+	if err != nil {
+		return err
+	}
+	if !bytes.HasPrefix(content, []byte(fileMagic)) || len(content) < len(fileMagic)+1 {
+		return errors.New("file is not encrypted")
+	}
+	idLen := int(content[len(fileMagic)])
+	headerLen := len(fileMagic) + 1 + idLen
+	if len(content) < headerLen {
+		return errors.New("malformed encrypted file")
+	}
+	aead, err := k.key(string(content[len(fileMagic)+1 : headerLen]))
+	if err != nil {
+		return err
+	}
+	plain, err := open(aead, content[headerLen:], content[:headerLen])
+	if err != nil {
+		return err
+	}
+	_, err = dst.Write(plain)
+	return err
+}
+
+// errPlainRecord reports a plain record in a file of encrypted records, which may have been added to it.
+var errPlainRecord = errors.New("plain record in a file of encrypted records")
+
+// Decrypt writes the records of src to dst in plain text, whatever the way they were written: an encrypted file,
+// gzip compressed or not, a file of encrypted records, gzip compressed or not, or plain records, which are
+// copied as is. Once a file holds encrypted records, its plain records are an error unless allowPlain is set,
+// such as for files written before encryption was enabled. The records of an encrypted file are authenticated
+// with it, so they are expected in plain text.
+func (k *Keyring) Decrypt(dst io.Writer, src io.Reader, allowPlain bool) error {
+	r := bufio.NewReader(src)
+	authenticated := false
+	if magic, _ := r.Peek(len(fileMagic)); string(magic) == fileMagic {
+		var plain bytes.Buffer
+		if err := k.DecryptFile(&plain, r); err != nil {
+			return err
+		}
+		r = bufio.NewReader(&plain)
+		authenticated = true
+	}
+	if magic, _ := r.Peek(len(gzipMagic)); bytes.Equal(magic, gzipMagic) {
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		r = bufio.NewReader(gz)
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	// encrypted tells whether an encrypted record was read, firstPlain is the first plain line before it.
+	encrypted, firstPlain := false, 0
+	for line := 1; scanner.Scan(); line++ {
+		record := scanner.Bytes()
+		switch {
+		case bytes.HasPrefix(record, []byte(recordPrefix)):
+			if firstPlain != 0 && !allowPlain {
+				return fmt.Errorf("line %d: %w", firstPlain, errPlainRecord)
+			}
+			encrypted = true
+			var err error
+			if record, err = k.DecryptRecord(record); err != nil {
+				return fmt.Errorf("line %d: %w", line, err)
+			}
+		case authenticated || allowPlain || len(record) == 0:
+		case encrypted:
+			return fmt.Errorf("line %d: %w", line, errPlainRecord)
+		case firstPlain == 0:
+			firstPlain = line
+		}
+		if _, err := dst.Write(append(record, '\n')); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+func (k *Keyring) key(id string) (cipher.AEAD, error) {
+	aead, ok := k.keys[id]
+	if !ok {
+		return nil, fmt.Errorf("unknown key id %s", id)
+	}
+	return aead, nil
+}
+
+func newNonce(aead cipher.AEAD) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return nonce, nil
+}
+
+func open(aead cipher.AEAD, sealed []byte, additionalData []byte) ([]byte, error) {
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	plain, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], additionalData)
+	if err != nil {
+		return nil, errors.New("decryption failed, wrong key or modified ciphertext")
+	}
+	return plain, nil
+}
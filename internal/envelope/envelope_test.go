@@ -0,0 +1,185 @@
+package envelope
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fsgonz/otelnetstatsreceiver/internal/lumberjack"
+)
+
+const (
+	oldKeys     = "# rotated keys\nk1 MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY=\n"
+	rotatedKeys = oldKeys + "k2 ZmVkY2JhOTg3NjU0MzIxMGZlZGNiYTk4NzY1NDMyMTA=\n"
+)
+
+func TestRecordRoundTripWithRotatedKeys(t *testing.T) {
+	old := mustParse(t, oldKeys)
+	encrypted, err := old.EncryptRecord([]byte("header\n{\"usage_bytes\":1}\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(string(encrypted), "\n"), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[0], "enc1:k1:") {
+		t.Fatalf("EncryptRecord() = %q, want 2 records encrypted with k1", encrypted)
+	}
+
+	rotated := mustParse(t, rotatedKeys)
+	if rotated.ActiveKeyID() != "k2" {
+		t.Errorf("ActiveKeyID() = %s, want k2", rotated.ActiveKeyID())
+	}
+	plain, err := rotated.DecryptRecord([]byte(lines[1]))
+	if err != nil || string(plain) != `{"usage_bytes":1}` {
+		t.Errorf("DecryptRecord() = %q, %v", plain, err)
+	}
+
+	tampered := []byte(lines[1][:len(lines[1])-4] + "AAA=")
+	if _, err := rotated.DecryptRecord(tampered); err == nil {
+		t.Error("DecryptRecord() of a modified record returned no error")
+	}
+	if _, err := mustParse(t, "k3 ZmVkY2JhOTg3NjU0MzIxMA==").DecryptRecord([]byte(lines[1])); err == nil {
+		t.Error("DecryptRecord() with an unknown key id returned no error")
+	}
+}
+
+func TestFileRoundTrip(t *testing.T) {
+	keyring := mustParse(t, rotatedKeys)
+	var encrypted, plain bytes.Buffer
+	if err := keyring.EncryptFile(&encrypted, strings.NewReader("a\nb\n")); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(encrypted.Bytes(), []byte(fileMagic+"\x02k2")) {
+		t.Errorf("EncryptFile() header = %q", encrypted.Bytes()[:11])
+	}
+	if err := keyring.Decrypt(&plain, &encrypted, false); err != nil || plain.String() != "a\nb\n" {
+		t.Errorf("Decrypt() = %q, %v", plain.String(), err)
+	}
+}
+
+func TestDecryptPlainRecords(t *testing.T) {
+	keyring := mustParse(t, rotatedKeys)
+	encrypted, err := keyring.EncryptRecord([]byte("a\nb\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.SplitAfter(string(encrypted), "\n")
+
+	for _, tc := range []struct {
+		name       string
+		content    string
+		allowPlain bool
+		want       string
+		wantErr    bool
+	}{
+		{name: "plain file", content: "x\ny\n", want: "x\ny\n"},
+		{name: "encrypted records", content: lines[0] + "\n" + lines[1], want: "a\n\nb\n"},
+		{name: "plain record after encrypted ones", content: lines[0] + "x\n" + lines[1], wantErr: true},
+		{name: "plain record before encrypted ones", content: "x\n" + string(encrypted), wantErr: true},
+		{name: "legacy records allowed", content: "x\n" + string(encrypted) + "y\n", allowPlain: true, want: "x\na\nb\ny\n"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var plain bytes.Buffer
+			err := keyring.Decrypt(&plain, strings.NewReader(tc.content), tc.allowPlain)
+			if tc.wantErr {
+				if !errors.Is(err, errPlainRecord) {
+					t.Errorf("Decrypt() = %v, want %v", err, errPlainRecord)
+				}
+				return
+			}
+			if err != nil || plain.String() != tc.want {
+				t.Errorf("Decrypt() = %q, %v, want %q", plain.String(), err, tc.want)
+			}
+		})
+	}
+}
+
+func TestParseKeyringErrors(t *testing.T) {
+	for _, content := range []string{
+		"",
+		"k1",
+		"k1 not-base64",
+		"k1 c2hvcnQ=",
+		"k:1 MDEyMzQ1Njc4OWFiY2RlZg==",
+		"k1 MDEyMzQ1Njc4OWFiY2RlZg==\nk1 MDEyMzQ1Njc4OWFiY2RlZg==",
+	} {
+		if _, err := ParseKeyring([]byte(content)); err == nil {
+			t.Errorf("ParseKeyring(%q) returned no error", content)
+		}
+	}
+}
+
+func TestLumberjackRecordEncryption(t *testing.T) {
+	keyring := mustParse(t, rotatedKeys)
+	name := filepath.Join(t.TempDir(), "usage.log")
+	logger := &lumberjack.Logger{Filename: name, Header: []byte("a,b\n"), EncryptRecord: keyring.EncryptRecord}
+	defer logger.Close()
+
+	n, err := logger.Write([]byte("1,2\n"))
+	if err != nil || n != 4 {
+		t.Fatalf("Write() = %d, %v, want the length of the record", n, err)
+	}
+	content, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(content, []byte("a,b")) || bytes.Contains(content, []byte("1,2")) {
+		t.Errorf("file holds plain text: %q", content)
+	}
+	if got := decrypt(t, keyring, name); got != "a,b\n1,2\n" {
+		t.Errorf("Decrypt() = %q", got)
+	}
+}
+
+func TestLumberjackFileEncryptionAfterCompression(t *testing.T) {
+	keyring := mustParse(t, rotatedKeys)
+	dir := t.TempDir()
+	name := filepath.Join(dir, "usage.log")
+	logger := &lumberjack.Logger{Filename: name, Compress: true, EncryptFile: keyring.EncryptFile}
+	defer logger.Close()
+
+	if _, err := logger.Write([]byte("rotated record\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err := logger.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+
+	var backups []string
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		backups, _ = filepath.Glob(filepath.Join(dir, "usage-*.log*"))
+		if len(backups) == 1 && strings.HasSuffix(backups[0], ".log.gz.enc") {
+			break
+		}
+	}
+	if len(backups) != 1 || !strings.HasSuffix(backups[0], ".log.gz.enc") {
+		t.Fatalf("backups = %v, want a single compressed and encrypted file", backups)
+	}
+	if got := decrypt(t, keyring, backups[0]); got != "rotated record\n" {
+		t.Errorf("Decrypt() = %q", got)
+	}
+}
+
+func mustParse(t *testing.T, content string) *Keyring {
+	keyring, err := ParseKeyring([]byte(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return keyring
+}
+
+func decrypt(t *testing.T, keyring *Keyring, name string) string {
+	f, err := os.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var plain bytes.Buffer
+	if err := keyring.Decrypt(&plain, f, false); err != nil {
+		t.Fatal(err)
+	}
+	return plain.String()
+}
@@ -1,7 +1,11 @@
 package logsampler
 
 import (
+	"os"
+	"strconv"
 	"time"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/operator"
 )
 
 type Config struct {
@@ -21,6 +25,169 @@ type LogSampler struct {
 	Output       string        `mapstructure:"output"`
 	URI          string        `mapstructure:"uri"`
 	PollInterval time.Duration `mapstructure:"poll_interval,omitempty"`
+	// Encoding is the format of the emitted records. Possible values: [v1, json, csv, logfmt, otlp_json]. Defaults to v1.
+	Encoding string `mapstructure:"encoding,omitempty"`
+	// Structured makes a pipeline_emitter output emit entries with a map body, usage attributes, timestamp and
+	// host and worker resource attributes instead of encoded records.
+	Structured bool `mapstructure:"structured,omitempty"`
+	// Operators, when set, are applied to the sampler records instead of the operators of the tailed files.
+	Operators []operator.Config `mapstructure:"operators,omitempty"`
+	// SelfIngest makes the receiver tail the files written by a file_logger output, so that its records reach
+	// the pipeline with the same checkpointing as the included files.
+	SelfIngest bool `mapstructure:"self_ingest,omitempty"`
+	// FileMode is the octal permission set (e.g. "0640") for files created by a file_logger output.
+	FileMode string `mapstructure:"file_mode,omitempty"`
+	// DirMode is the octal permission set (e.g. "0750") for missing parent directories created by a file_logger output.
+	DirMode string `mapstructure:"dir_mode,omitempty"`
+	// UID is the owner given to files and directories created by a file_logger output.
+	UID *int `mapstructure:"uid,omitempty"`
+	// GID is the group given to files and directories created by a file_logger output.
+	GID *int `mapstructure:"gid,omitempty"`
+	// Source is where the netstats counters are read from. Possible values: [procfs, netlink, sysfs]. Defaults to
+	// procfs. netlink falls back to procfs when the netlink socket cannot be used. sysfs also adds the link state
+	// and utilization to the records.
+	Source string `mapstructure:"source,omitempty"`
+	// SysfsRoot is the mount point of sysfs read by the sysfs source and physical_only. Defaults to /sys.
+	SysfsRoot string `mapstructure:"sysfs_root,omitempty"`
+	// Mode is how the sampled counters are reported. Possible values: [delta, rate]. Defaults to delta, the usage
+	// since the last sample. rate also divides it by the seconds elapsed since the last sample.
+	Mode string `mapstructure:"mode,omitempty"`
+	// Aggregation emits a record per window summarizing its samples instead of a record per sample.
+	Aggregation AggregationConfig `mapstructure:"aggregation,omitempty"`
+	// Rules are evaluated on every sample, emitting an alert record through the pipeline when one is met.
+	Rules []Rule `mapstructure:"rules,omitempty"`
+	// PhysicalOnly samples the sum of the interfaces classified as physical from sysfs instead of eth0, so traffic
+	// is not counted again through bridges, veth pairs, bonds, vlans and tunnels.
+	PhysicalOnly bool `mapstructure:"physical_only,omitempty"`
+	// Signing makes the records of a file_logger output tamper-evident.
+	Signing SigningConfig `mapstructure:"signing,omitempty"`
+	// Encryption encrypts the records of a file_logger output at rest.
+	Encryption EncryptionConfig `mapstructure:"encryption,omitempty"`
+	// Network defines the buffering and reconnection of the unix_socket, udp and syslog outputs.
+	Network NetworkOutputConfig `mapstructure:"network,omitempty"`
+	// Syslog defines the RFC5424 header of the messages of the syslog output.
+	Syslog SyslogConfig `mapstructure:"syslog,omitempty"`
+}
+
+// NetworkOutputConfig defines how records are sent to a unix_socket, udp or syslog output.
+type NetworkOutputConfig struct {
+	// BufferSize is the number of records kept while the output is unreachable, the oldest being dropped when
+	// it is full. Defaults to 1000.
+	BufferSize int `mapstructure:"buffer_size,omitempty"`
+	// ReconnectInterval is the delay before connecting again after a failure, doubled after every failed
+	// attempt up to MaxReconnectInterval. Defaults to 1s.
+	ReconnectInterval time.Duration `mapstructure:"reconnect_interval,omitempty"`
+	// MaxReconnectInterval is the longest delay between two connection attempts. Defaults to 30s.
+	MaxReconnectInterval time.Duration `mapstructure:"max_reconnect_interval,omitempty"`
+}
+
+// SyslogConfig defines the RFC5424 header of the syslog messages.
+type SyslogConfig struct {
+	// Facility of the messages. Possible values: [user, daemon, local0, ..., local7]. Defaults to user.
+	Facility string `mapstructure:"facility,omitempty"`
+	// AppName of the messages. Defaults to otelnetstats.
+	AppName string `mapstructure:"app_name,omitempty"`
+	// Hostname of the messages. Defaults to the host name.
+	Hostname string `mapstructure:"hostname,omitempty"`
+}
+
+// SigningConfig defines the signature added to the records.
+type SigningConfig struct {
+	// KeyFile is the file holding the HMAC-SHA256 key, at least 16 bytes. Signing is disabled if empty.
+	KeyFile string `mapstructure:"key_file"`
+}
+
+// AggregationConfig defines the windows the samples are aggregated into.
+type AggregationConfig struct {
+	// Interval is the length of the windows, aligned on the wall clock. Aggregation is disabled if 0.
+	Interval time.Duration `mapstructure:"interval"`
+}
+
+// EncryptionConfig defines how the records are encrypted.
+type EncryptionConfig struct {
+	// KeyFile is the file holding the AES keys by key id, the last one encrypting. Encryption is disabled if empty.
+	KeyFile string `mapstructure:"key_file"`
+	// Mode is what is encrypted. Possible values: [records, files]. Defaults to records, every record as it is
+	// written. files encrypts the rotated files, after their compression, leaving the current file in plain text.
+	Mode string `mapstructure:"mode,omitempty"`
+}
+
+// Rule defines a threshold on a metric computed from the samples.
+type Rule struct {
+	// Name identifies the rule in the alerts.
+	Name string `mapstructure:"name"`
+	// Metric is what the threshold applies to. Possible values: [bytes, rx_bytes, tx_bytes, packets, rx_packets,
+	// tx_packets, rx_dropped, tx_dropped], counted per Per since the last sample, or [rx_utilization_pct,
+	// tx_utilization_pct], the percentage of the link speed, which requires the sysfs source.
+	Metric string `mapstructure:"metric"`
+	// Operator compares the metric with the threshold. Possible values: [>, >=, <, <=]. Defaults to >.
+	Operator string `mapstructure:"operator,omitempty"`
+	// Threshold is the value the metric is compared with.
+	Threshold float64 `mapstructure:"threshold"`
+	// Per is the unit of time of the counted metrics. Possible values: [second, minute]. Defaults to second.
+	Per string `mapstructure:"per,omitempty"`
+	// For is how long the condition must hold before the rule fires. Defaults to 0, the first sample meeting it.
+	For time.Duration `mapstructure:"for,omitempty"`
+	// Severity is the severity of the alerts. Possible values: [warn, error]. Defaults to warn.
+	Severity string `mapstructure:"severity,omitempty"`
+}
+
+// Validate checks the rule.
+func (rule *Rule) Validate(source string, physicalOnly bool) error {
+	if rule.Name == "" {
+		return &LogSamplerError{"Rules in sampler require a name"}
+	}
+	switch rule.Metric {
+	case "bytes", "rx_bytes", "tx_bytes", "packets", "rx_packets", "tx_packets", "rx_dropped", "tx_dropped":
+		break
+	case "rx_utilization_pct", "tx_utilization_pct":
+		if source != "sysfs" || physicalOnly {
+			return &LogSamplerError{"Incorrect metric in rule '" + rule.Name + "'. Utilization requires the sysfs source without physical_only"}
+		}
+		if rule.Per != "" {
+			return &LogSamplerError{"Incorrect per in rule '" + rule.Name + "'. Utilization is not counted per unit of time"}
+		}
+	default:
+		return &LogSamplerError{"Incorrect metric in rule '" + rule.Name + "'. Possible Values: [bytes, rx_bytes, tx_bytes, packets, " +
+			"rx_packets, tx_packets, rx_dropped, tx_dropped, rx_utilization_pct, tx_utilization_pct]"}
+	}
+	switch rule.Operator {
+	case "", ">", ">=", "<", "<=":
+		break
+	default:
+		return &LogSamplerError{"Incorrect operator in rule '" + rule.Name + "'. Possible Values: [>, >=, <, <=]"}
+	}
+	switch rule.Per {
+	case "", "second", "minute":
+		break
+	default:
+		return &LogSamplerError{"Incorrect per in rule '" + rule.Name + "'. Possible Values: [second, minute]"}
+	}
+	if rule.For < 0 {
+		return &LogSamplerError{"Incorrect for in rule '" + rule.Name + "'. It must not be negative"}
+	}
+	switch rule.Severity {
+	case "", "warn", "error":
+		break
+	default:
+		return &LogSamplerError{"Incorrect severity in rule '" + rule.Name + "'. Possible Values: [warn, error]"}
+	}
+	return nil
+}
+
+// DefaultPollInterval is the poll interval of samplers that do not set one.
+const DefaultPollInterval = time.Minute
+
+// ParseFileMode parses an octal permission string such as "0640". An empty string yields 0.
+func ParseFileMode(mode string) (os.FileMode, error) {
+	if mode == "" {
+		return 0, nil
+	}
+	parsed, err := strconv.ParseUint(mode, 8, 32)
+	if err != nil || parsed > uint64(os.ModePerm) {
+		return 0, &LogSamplerError{"Incorrect permission '" + mode + "' in sampler. Expected an octal value such as 0640"}
+	}
+	return os.FileMode(parsed), nil
 }
 
 func (cfg *Config) Validate() error {
@@ -37,8 +204,117 @@ func (cfg *Config) Validate() error {
 		switch logSampler.Output {
 		case "file_logger", "pipeline_emitter":
 			break
+		case "unix_socket", "udp", "syslog":
+			if logSampler.URI == "" {
+				return &LogSamplerError{"The uri is required by the unix_socket, udp and syslog outputs"}
+			}
+		default:
+			return &LogSamplerError{"Incorrect output in sampler. Possible Values: [file_logger, pipeline_emitter, unix_socket, udp, syslog]"}
+		}
+		if logSampler.Network.BufferSize < 0 {
+			return &LogSamplerError{"Incorrect network buffer size in sampler. It must not be negative"}
+		}
+		if logSampler.Network.ReconnectInterval < 0 || logSampler.Network.MaxReconnectInterval < 0 {
+			return &LogSamplerError{"Incorrect network reconnect interval in sampler. It must not be negative"}
+		}
+		switch logSampler.Syslog.Facility {
+		case "", "user", "daemon", "local0", "local1", "local2", "local3", "local4", "local5", "local6", "local7":
+			break
 		default:
-			return &LogSamplerError{"Incorrect output in sampler. Possible Values: [file_logger, pipeline_emitter]"}
+			return &LogSamplerError{"Incorrect syslog facility in sampler. Possible Values: [user, daemon, local0, local1, local2, local3, local4, local5, local6, local7]"}
+		}
+		switch logSampler.Encoding {
+		case "", "v1", "json", "csv", "logfmt", "otlp_json":
+			break
+		default:
+			return &LogSamplerError{"Incorrect encoding in sampler. Possible Values: [v1, json, csv, logfmt, otlp_json]"}
+		}
+		switch logSampler.Source {
+		case "", "procfs", "netlink", "sysfs":
+			break
+		default:
+			return &LogSamplerError{"Incorrect source in sampler. Possible Values: [procfs, netlink, sysfs]"}
+		}
+		switch logSampler.Mode {
+		case "", "delta", "rate":
+			break
+		default:
+			return &LogSamplerError{"Incorrect mode in sampler. Possible Values: [delta, rate]"}
+		}
+		if logSampler.Aggregation.Interval < 0 {
+			return &LogSamplerError{"Incorrect aggregation interval in sampler. It must not be negative"}
+		}
+		pollInterval := logSampler.PollInterval
+		if pollInterval <= 0 {
+			pollInterval = DefaultPollInterval
+		}
+		if logSampler.Aggregation.Interval > 0 && logSampler.Aggregation.Interval < pollInterval {
+			return &LogSamplerError{"Incorrect aggregation interval in sampler. It must not be shorter than the poll interval"}
+		}
+		names := map[string]bool{}
+		for i := range logSampler.Rules {
+			rule := &logSampler.Rules[i]
+			if err := rule.Validate(logSampler.Source, logSampler.PhysicalOnly); err != nil {
+				return err
+			}
+			if names[rule.Name] {
+				return &LogSamplerError{"Duplicate rule '" + rule.Name + "' in sampler"}
+			}
+			names[rule.Name] = true
+		}
+		if logSampler.SysfsRoot != "" && logSampler.Source != "sysfs" && !logSampler.PhysicalOnly {
+			return &LogSamplerError{"Sysfs root is only supported by the sysfs source and physical_only"}
+		}
+		if len(logSampler.Operators) > 0 && logSampler.Output != "pipeline_emitter" {
+			return &LogSamplerError{"Operators are only supported by the pipeline_emitter output, the records of the other outputs do not go through the pipeline"}
+		}
+		if logSampler.Structured && logSampler.Output != "pipeline_emitter" {
+			return &LogSamplerError{"Structured records are only supported by the pipeline_emitter output"}
+		}
+		if logSampler.Encoding == "csv" && (logSampler.Output == "udp" || logSampler.Output == "syslog") {
+			return &LogSamplerError{"The csv encoding is not supported by the udp and syslog outputs, whose records have no header"}
+		}
+		if logSampler.Structured && logSampler.Encoding != "" {
+			return &LogSamplerError{"Encoding cannot be set for structured records"}
+		}
+		if logSampler.Signing.KeyFile != "" && logSampler.Output != "file_logger" {
+			return &LogSamplerError{"Signing is only supported by the file_logger output"}
+		}
+		if logSampler.Signing.KeyFile != "" && (logSampler.Encoding == "csv" || logSampler.Encoding == "logfmt") {
+			return &LogSamplerError{"Signing is only supported by the JSON encodings. Possible Values: [v1, json, otlp_json]"}
+		}
+		switch logSampler.Encryption.Mode {
+		case "", "records", "files":
+			break
+		default:
+			return &LogSamplerError{"Incorrect encryption mode in sampler. Possible Values: [records, files]"}
+		}
+		if logSampler.Encryption.Mode != "" && logSampler.Encryption.KeyFile == "" {
+			return &LogSamplerError{"The encryption mode requires an encryption key file"}
+		}
+		if logSampler.Encryption.KeyFile != "" && logSampler.Output != "file_logger" {
+			return &LogSamplerError{"Encryption is only supported by the file_logger output"}
+		}
+		if logSampler.Encryption.KeyFile != "" && logSampler.SelfIngest {
+			return &LogSamplerError{"Self ingest cannot be combined with encryption"}
+		}
+		if logSampler.SelfIngest && (logSampler.Output != "file_logger" || logSampler.URI == "") {
+			return &LogSamplerError{"Self ingest is only supported by the file_logger output with an uri"}
+		}
+		if logSampler.SelfIngest && logSampler.Encoding == "csv" {
+			return &LogSamplerError{"Self ingest cannot be combined with the csv encoding, whose header would be read as a record"}
+		}
+		if _, err := ParseFileMode(logSampler.FileMode); err != nil {
+			return err
+		}
+		if _, err := ParseFileMode(logSampler.DirMode); err != nil {
+			return err
+		}
+		if logSampler.UID != nil && *logSampler.UID < 0 {
+			return &LogSamplerError{"Incorrect uid in sampler. It must not be negative"}
+		}
+		if logSampler.GID != nil && *logSampler.GID < 0 {
+			return &LogSamplerError{"Incorrect gid in sampler. It must not be negative"}
 		}
 	}
 	return nil
@@ -0,0 +1,149 @@
+package logsampler
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/operator"
+)
+
+func TestValidate(t *testing.T) {
+	fileLogger := func(update func(*LogSampler)) LogSampler {
+		logSampler := LogSampler{Metric: "netstats", Output: "file_logger", URI: "/var/log/metering/usage.log"}
+		update(&logSampler)
+		return logSampler
+	}
+
+	for _, tc := range []struct {
+		name    string
+		sampler LogSampler
+		wantErr string
+	}{
+		{
+			name: "records encryption",
+			sampler: fileLogger(func(s *LogSampler) {
+				s.Encryption = EncryptionConfig{KeyFile: "encryption.keys"}
+			}),
+		},
+		{
+			name: "files encryption",
+			sampler: fileLogger(func(s *LogSampler) {
+				s.Encryption = EncryptionConfig{KeyFile: "encryption.keys", Mode: "files"}
+			}),
+		},
+		{
+			name: "encryption mode without key file",
+			sampler: fileLogger(func(s *LogSampler) {
+				s.Encryption = EncryptionConfig{Mode: "files"}
+			}),
+			wantErr: "requires an encryption key file",
+		},
+		{
+			name: "bad encryption mode",
+			sampler: fileLogger(func(s *LogSampler) {
+				s.Encryption = EncryptionConfig{KeyFile: "encryption.keys", Mode: "blocks"}
+			}),
+			wantErr: "Incorrect encryption mode",
+		},
+		{
+			name: "encryption with self ingest",
+			sampler: fileLogger(func(s *LogSampler) {
+				s.Encryption = EncryptionConfig{KeyFile: "encryption.keys"}
+				s.SelfIngest = true
+			}),
+			wantErr: "Self ingest cannot be combined with encryption",
+		},
+		{
+			name: "csv with self ingest",
+			sampler: fileLogger(func(s *LogSampler) {
+				s.Encoding = "csv"
+				s.SelfIngest = true
+			}),
+			wantErr: "Self ingest cannot be combined with the csv encoding",
+		},
+		{
+			name: "encryption of another output",
+			sampler: fileLogger(func(s *LogSampler) {
+				s.Output = "pipeline_emitter"
+				s.Encryption = EncryptionConfig{KeyFile: "encryption.keys"}
+			}),
+			wantErr: "Encryption is only supported by the file_logger output",
+		},
+		{
+			name: "csv over a unix socket",
+			sampler: fileLogger(func(s *LogSampler) {
+				s.Output = "unix_socket"
+				s.Encoding = "csv"
+			}),
+		},
+		{
+			name: "csv over udp",
+			sampler: fileLogger(func(s *LogSampler) {
+				s.Output = "udp"
+				s.URI = "127.0.0.1:5140"
+				s.Encoding = "csv"
+			}),
+			wantErr: "csv encoding is not supported",
+		},
+		{
+			name: "csv over syslog",
+			sampler: fileLogger(func(s *LogSampler) {
+				s.Output = "syslog"
+				s.URI = "127.0.0.1:514"
+				s.Encoding = "csv"
+			}),
+			wantErr: "csv encoding is not supported",
+		},
+		{
+			name: "operators of the pipeline",
+			sampler: fileLogger(func(s *LogSampler) {
+				s.Output = "pipeline_emitter"
+				s.Operators = []operator.Config{{}}
+			}),
+		},
+		{
+			name: "operators of a file",
+			sampler: fileLogger(func(s *LogSampler) {
+				s.Operators = []operator.Config{{}}
+			}),
+			wantErr: "records of the other outputs do not go through the pipeline",
+		},
+		{
+			name: "operators of a unix socket",
+			sampler: fileLogger(func(s *LogSampler) {
+				s.Output = "unix_socket"
+				s.Operators = []operator.Config{{}}
+			}),
+			wantErr: "records of the other outputs do not go through the pipeline",
+		},
+		{
+			name: "operators over udp",
+			sampler: fileLogger(func(s *LogSampler) {
+				s.Output = "udp"
+				s.URI = "127.0.0.1:5140"
+				s.Operators = []operator.Config{{}}
+			}),
+			wantErr: "records of the other outputs do not go through the pipeline",
+		},
+		{
+			name: "operators over syslog",
+			sampler: fileLogger(func(s *LogSampler) {
+				s.Output = "syslog"
+				s.URI = "127.0.0.1:514"
+				s.Operators = []operator.Config{{}}
+			}),
+			wantErr: "records of the other outputs do not go through the pipeline",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := Config{LogSamplers: []LogSampler{tc.sampler}}
+			err := cfg.Validate()
+			switch {
+			case tc.wantErr == "" && err != nil:
+				t.Errorf("Validate() = %v, want no error", err)
+			case tc.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tc.wantErr)):
+				t.Errorf("Validate() = %v, want %q", err, tc.wantErr)
+			}
+		})
+	}
+}
@@ -17,3 +17,9 @@ func chown(name string, info os.FileInfo) error {
 	stat := info.Sys().(*syscall.Stat_t)
 	return osChown(name, int(stat.Uid), int(stat.Gid))
 }
+
+// chownTo changes the ownership of name. A uid or gid of -1 leaves that
+// value unchanged.
+func chownTo(name string, uid, gid int) error {
+	return osChown(name, uid, gid)
+}
@@ -0,0 +1,67 @@
+package lumberjack
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func TestOwnership(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("changing the owner of files requires root")
+	}
+	uid, gid := 4242, 4343
+	dir := filepath.Join(t.TempDir(), "metering")
+	name := filepath.Join(dir, "usage.log")
+	l := &Logger{Filename: name, UID: &uid, GID: &gid}
+	defer l.Close()
+
+	if _, err := l.Write([]byte("record\n")); err != nil {
+		t.Fatal(err)
+	}
+	assertOwner(t, name, uid, gid)
+	assertOwner(t, dir, uid, gid)
+
+	if err := l.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	backups, err := filepath.Glob(BackupPattern(name))
+	if err != nil || len(backups) != 1 {
+		t.Fatalf("backups = %v, %v, want one", backups, err)
+	}
+	assertOwner(t, backups[0], uid, gid)
+	assertOwner(t, name, uid, gid)
+}
+
+func TestOwnershipCopiedOffPreviousFile(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("changing the owner of files requires root")
+	}
+	name := filepath.Join(t.TempDir(), "usage.log")
+	if err := os.WriteFile(name, []byte("previous\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chown(name, 4242, 4343); err != nil {
+		t.Fatal(err)
+	}
+	l := &Logger{Filename: name}
+	defer l.Close()
+
+	if err := l.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	assertOwner(t, name, 4242, 4343)
+}
+
+func assertOwner(t *testing.T, name string, uid int, gid int) {
+	t.Helper()
+	info, err := os.Stat(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stat := info.Sys().(*syscall.Stat_t)
+	if int(stat.Uid) != uid || int(stat.Gid) != gid {
+		t.Errorf("owner of %s = %d:%d, want %d:%d", name, stat.Uid, stat.Gid, uid, gid)
+	}
+}
@@ -41,7 +41,10 @@ import (
 const (
 	backupTimeFormat = "2006-01-02T15-04-05.000"
 	compressSuffix   = ".gz"
+	encryptSuffix    = ".enc"
 	defaultMaxSize   = 100
+	defaultFileMode  = os.FileMode(0600)
+	defaultDirMode   = os.FileMode(0755)
 )
 
 // ensure we always implement io.WriteCloser
@@ -110,6 +113,33 @@ type Logger struct {
 	// using gzip. The default is not to perform compression.
 	Compress bool `json:"compress" yaml:"compress"`
 
+	// FileMode is the permission set used when creating a new log file. The
+	// default is to copy the mode off the previous log file, or 0600 if there
+	// is none.
+	FileMode os.FileMode `json:"filemode" yaml:"filemode"`
+
+	// DirMode is the permission set used when creating missing parent
+	// directories of the log file. The default is 0755.
+	DirMode os.FileMode `json:"dirmode" yaml:"dirmode"`
+
+	// UID and GID, when set, are the owner and group given to new log files
+	// and to any parent directories created for them. The default is to copy
+	// the ownership off the previous log file. Only supported on linux.
+	UID *int `json:"uid" yaml:"uid"`
+	GID *int `json:"gid" yaml:"gid"`
+
+	// Header, when set, is written at the start of every new log file.
+	Header []byte `json:"header" yaml:"header"`
+
+	// EncryptRecord, when set, is applied to every write and to the header,
+	// and its result is written instead. Each write must hold whole records.
+	EncryptRecord func(p []byte) ([]byte, error) `json:"-" yaml:"-"`
+
+	// EncryptFile, when set, encrypts the rotated log files, after their
+	// compression if enabled, into a file named after them with an ".enc"
+	// suffix, removing them.
+	EncryptFile func(dst io.Writer, src io.Reader) error `json:"-" yaml:"-"`
+
 	size int64
 	file *os.File
 	mu   sync.Mutex
@@ -139,6 +169,13 @@ func (l *Logger) Write(p []byte) (n int, err error) {
 	l.mu.Lock()
 	defer l.mu.Unlock()
 
+	written := len(p)
+	if l.EncryptRecord != nil {
+		if p, err = l.EncryptRecord(p); err != nil {
+			return 0, fmt.Errorf("can't encrypt log record: %s", err)
+		}
+	}
+
 	writeLen := int64(len(p))
 	if writeLen > l.max() {
 		return 0, fmt.Errorf(
@@ -160,6 +197,9 @@ func (l *Logger) Write(p []byte) (n int, err error) {
 
 	n, err = l.file.Write(p)
 	l.size += int64(n)
+	if err == nil {
+		n = written
+	}
 
 	return n, err
 }
@@ -209,13 +249,12 @@ func (l *Logger) rotate() error {
 // openNew opens a new log file for writing, moving any old log file out of the
 // way.  This methods assumes the file has already been closed.
 func (l *Logger) openNew() error {
-	err := os.MkdirAll(l.dir(), 0755)
-	if err != nil {
+	if err := l.makeDirs(); err != nil {
 		return fmt.Errorf("can't make directories for new logfile: %s", err)
 	}
 
 	name := l.filename()
-	mode := os.FileMode(0600)
+	mode := defaultFileMode
 	info, err := osStat(name)
 	if err == nil {
 		// Copy the mode off the old logfile.
@@ -231,6 +270,9 @@ func (l *Logger) openNew() error {
 			return err
 		}
 	}
+	if l.FileMode != 0 {
+		mode = l.FileMode
+	}
 
 	// we use truncate here because this should only get called when we've moved
 	// the file ourselves. if someone else creates the file in the meantime,
@@ -239,11 +281,91 @@ func (l *Logger) openNew() error {
 	if err != nil {
 		return fmt.Errorf("can't open new logfile: %s", err)
 	}
+	if err := l.applyPermissions(name, l.FileMode); err != nil {
+		f.Close()
+		return fmt.Errorf("can't set permissions of new logfile: %s", err)
+	}
 	l.file = f
 	l.size = 0
+	return l.writeHeader()
+}
+
+// writeHeader writes the configured header to the current file.
+func (l *Logger) writeHeader() error {
+	if len(l.Header) == 0 {
+		return nil
+	}
+	header := l.Header
+	if l.EncryptRecord != nil {
+		var err error
+		if header, err = l.EncryptRecord(header); err != nil {
+			return fmt.Errorf("can't encrypt header: %s", err)
+		}
+	}
+	n, err := l.file.Write(header)
+	l.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("can't write header to logfile: %s", err)
+	}
+	return nil
+}
+
+// makeDirs creates the missing parent directories of the log file, applying
+// DirMode and the configured ownership to each directory it creates.
+func (l *Logger) makeDirs() error {
+	var missing []string
+	for dir := l.dir(); ; dir = filepath.Dir(dir) {
+		if _, err := osStat(dir); err == nil || !os.IsNotExist(err) {
+			break
+		}
+		missing = append(missing, dir)
+		if parent := filepath.Dir(dir); parent == dir {
+			break
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+
+	mode := l.DirMode
+	if mode == 0 {
+		mode = defaultDirMode
+	}
+	if err := os.MkdirAll(l.dir(), mode); err != nil {
+		return err
+	}
+	// Walk from the outermost directory created so that ownership is never
+	// changed on a directory before its parent.
+	for i := len(missing) - 1; i >= 0; i-- {
+		if err := l.applyPermissions(missing[i], l.DirMode); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
+// applyPermissions sets the given mode, if any, and the configured ownership
+// on name. The explicit chmod makes the result independent of the umask.
+func (l *Logger) applyPermissions(name string, mode os.FileMode) error {
+	if mode != 0 {
+		if err := os.Chmod(name, mode); err != nil {
+			return err
+		}
+	}
+	if l.UID == nil && l.GID == nil {
+		return nil
+	}
+	uid, gid := -1, -1
+	if l.UID != nil {
+		uid = *l.UID
+	}
+	if l.GID != nil {
+		gid = *l.GID
+	}
+	// this is a no-op anywhere but linux
+	return chownTo(name, uid, gid)
+}
+
 // backupName creates a new filename from the given name, inserting a timestamp
 // between the filename and the extension, using the local time if requested
 // (otherwise UTC).
@@ -261,6 +383,16 @@ func backupName(name string, local bool) string {
 	return filepath.Join(dir, fmt.Sprintf("%s-%s%s", prefix, timestamp, ext))
 }
 
+// BackupPattern returns the glob pattern matching the backup files created for
+// the given log file name.
+func BackupPattern(name string) string {
+	dir := filepath.Dir(name)
+	filename := filepath.Base(name)
+	ext := filepath.Ext(filename)
+	prefix := filename[:len(filename)-len(ext)]
+	return filepath.Join(dir, fmt.Sprintf("%s-*%s", prefix, ext))
+}
+
 // openExistingOrNew opens the logfile if it exists and if the current write
 // would not put it over MaxSize.  If there is no such file or the write would
 // put it over the MaxSize, a new file is created.
@@ -288,6 +420,9 @@ func (l *Logger) openExistingOrNew(writeLen int) error {
 	}
 	l.file = file
 	l.size = info.Size()
+	if l.size == 0 {
+		return l.writeHeader()
+	}
 	return nil
 }
 
@@ -300,12 +435,12 @@ func (l *Logger) filename() string {
 	return filepath.Join(os.TempDir(), name)
 }
 
-// millRunOnce performs compression and removal of stale log files.
-// Log files are compressed if enabled via configuration and old log
-// files are removed, keeping at most l.MaxBackups files, as long as
+// millRunOnce performs compression, encryption and removal of stale log files.
+// Log files are compressed and then encrypted if enabled via configuration and
+// old log files are removed, keeping at most l.MaxBackups files, as long as
 // none of them are older than MaxAge.
 func (l *Logger) millRunOnce() error {
-	if l.MaxBackups == 0 && l.MaxAge == 0 && !l.Compress {
+	if l.MaxBackups == 0 && l.MaxAge == 0 && !l.Compress && l.EncryptFile == nil {
 		return nil
 	}
 
@@ -314,15 +449,15 @@ func (l *Logger) millRunOnce() error {
 		return err
 	}
 
-	var compress, remove []logInfo
+	var compress, encrypt, remove []logInfo
 
 	if l.MaxBackups > 0 && l.MaxBackups < len(files) {
 		preserved := make(map[string]bool)
 		var remaining []logInfo
 		for _, f := range files {
 			// Only count the uncompressed log file or the
-			// compressed log file, not both.
-			fn := f.Name()
+			// compressed or encrypted log file, not both.
+			fn := strings.TrimSuffix(f.Name(), encryptSuffix)
 			if strings.HasSuffix(fn, compressSuffix) {
 				fn = fn[:len(fn)-len(compressSuffix)]
 			}
@@ -353,11 +488,18 @@ func (l *Logger) millRunOnce() error {
 
 	if l.Compress {
 		for _, f := range files {
-			if !strings.HasSuffix(f.Name(), compressSuffix) {
+			if !strings.HasSuffix(f.Name(), compressSuffix) && !strings.HasSuffix(f.Name(), encryptSuffix) {
 				compress = append(compress, f)
 			}
 		}
 	}
+	if l.EncryptFile != nil {
+		for _, f := range files {
+			if !strings.HasSuffix(f.Name(), encryptSuffix) {
+				encrypt = append(encrypt, f)
+			}
+		}
+	}
 
 	for _, f := range remove {
 		errRemove := os.Remove(filepath.Join(l.dir(), f.Name()))
@@ -372,6 +514,17 @@ func (l *Logger) millRunOnce() error {
 			err = errCompress
 		}
 	}
+	for _, f := range encrypt {
+		fn := filepath.Join(l.dir(), f.Name())
+		if _, errStat := osStat(fn + compressSuffix); errStat == nil {
+			// compressed above
+			fn += compressSuffix
+		}
+		errEncrypt := l.encryptLogFile(fn, fn+encryptSuffix)
+		if err == nil && errEncrypt != nil {
+			err = errEncrypt
+		}
+	}
 
 	return err
 }
@@ -421,6 +574,14 @@ func (l *Logger) oldLogFiles() ([]logInfo, error) {
 			logFiles = append(logFiles, logInfo{t, f})
 			continue
 		}
+		if t, err := l.timeFromName(f.Name(), prefix, ext+encryptSuffix); err == nil {
+			logFiles = append(logFiles, logInfo{t, f})
+			continue
+		}
+		if t, err := l.timeFromName(f.Name(), prefix, ext+compressSuffix+encryptSuffix); err == nil {
+			logFiles = append(logFiles, logInfo{t, f})
+			continue
+		}
 		// error parsing means that the suffix at the end was not generated
 		// by lumberjack, and therefore it's not a backup file.
 	}
@@ -521,6 +682,56 @@ func compressLogFile(src, dst string) (err error) {
 	return nil
 }
 
+// encryptLogFile encrypts the given log file with EncryptFile, removing the
+// plain log file if successful.
+func (l *Logger) encryptLogFile(src, dst string) (err error) {
+	f, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %v", err)
+	}
+	defer f.Close()
+
+	fi, err := osStat(src)
+	if err != nil {
+		return fmt.Errorf("failed to stat log file: %v", err)
+	}
+
+	if err := chown(dst, fi); err != nil {
+		return fmt.Errorf("failed to chown encrypted log file: %v", err)
+	}
+
+	// If this file already exists, we presume it was created by
+	// a previous attempt to encrypt the log file.
+	encf, err := os.OpenFile(dst, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, fi.Mode())
+	if err != nil {
+		return fmt.Errorf("failed to open encrypted log file: %v", err)
+	}
+	defer encf.Close()
+
+	defer func() {
+		if err != nil {
+			os.Remove(dst)
+			err = fmt.Errorf("failed to encrypt log file: %v", err)
+		}
+	}()
+
+	if err := l.EncryptFile(encf, f); err != nil {
+		return err
+	}
+	if err := encf.Close(); err != nil {
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Remove(src); err != nil {
+		return err
+	}
+
+	return nil
+}
+
 // logInfo is a convenience struct to return the filename and its embedded
 // timestamp.
 type logInfo struct {
@@ -0,0 +1,196 @@
+package lumberjack
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestFileAndDirModes(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "metering", "usage")
+	name := filepath.Join(dir, "usage.log")
+	l := &Logger{Filename: name, FileMode: 0640, DirMode: 0750}
+	defer l.Close()
+
+	if _, err := l.Write([]byte("record\n")); err != nil {
+		t.Fatal(err)
+	}
+	assertMode(t, name, 0640)
+	assertMode(t, dir, os.ModeDir|0750)
+	assertMode(t, filepath.Dir(dir), os.ModeDir|0750)
+
+	if err := l.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	backups, err := filepath.Glob(BackupPattern(name))
+	if err != nil || len(backups) != 1 {
+		t.Fatalf("backups = %v, %v, want one", backups, err)
+	}
+	assertMode(t, backups[0], 0640)
+	assertMode(t, name, 0640)
+}
+
+func TestRotatedFileKeepsMode(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "usage.log")
+	if err := os.WriteFile(name, []byte("previous\n"), 0604); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(name, 0604); err != nil {
+		t.Fatal(err)
+	}
+	l := &Logger{Filename: name}
+	defer l.Close()
+
+	// Without FileMode, the new file copies the mode off the one it replaces.
+	if err := l.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	assertMode(t, name, 0604)
+}
+
+func TestDefaultModes(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "usage")
+	name := filepath.Join(dir, "usage.log")
+	l := &Logger{Filename: name}
+	defer l.Close()
+
+	if _, err := l.Write([]byte("record\n")); err != nil {
+		t.Fatal(err)
+	}
+	assertMode(t, name, defaultFileMode)
+	info, err := os.Stat(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The default directory mode is subject to the umask.
+	if info.Mode().Perm()&^defaultDirMode != 0 {
+		t.Errorf("mode of %s = %v, want at most %v", dir, info.Mode(), defaultDirMode)
+	}
+}
+
+func TestOldLogFilesEncrypted(t *testing.T) {
+	dir := t.TempDir()
+	l := &Logger{Filename: filepath.Join(dir, "usage.log")}
+	backups := []string{
+		backupFile(t, dir, 1, ".log"),
+		backupFile(t, dir, 2, ".log.gz"),
+		backupFile(t, dir, 3, ".log.enc"),
+		backupFile(t, dir, 4, ".log.gz.enc"),
+	}
+	for _, name := range []string{"usage.log", "usage-notatime.log.enc", "usage-" + backupTime(5) + ".log.enc.gz", "other.log"} {
+		writeFile(t, filepath.Join(dir, name))
+	}
+
+	files, err := l.oldLogFiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, f := range files {
+		names = append(names, f.Name())
+	}
+	want := []string{backups[3], backups[2], backups[1], backups[0]}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("old log files = %v, want %v, newest first", names, want)
+	}
+}
+
+func TestMaxBackupsCountsEncryptedFiles(t *testing.T) {
+	dir := t.TempDir()
+	l := &Logger{Filename: filepath.Join(dir, "usage.log"), MaxBackups: 2}
+	backupFile(t, dir, 1, ".log")
+	backupFile(t, dir, 2, ".log.gz")
+	kept := []string{
+		backupFile(t, dir, 3, ".log.enc"),
+		// A compressed file left by a failed encryption counts with its encrypted file.
+		backupFile(t, dir, 4, ".log.gz"),
+		backupFile(t, dir, 4, ".log.gz.enc"),
+	}
+
+	if err := l.millRunOnce(); err != nil {
+		t.Fatal(err)
+	}
+	assertFiles(t, dir, kept)
+}
+
+func TestEncryptRetriesAfterFailure(t *testing.T) {
+	dir := t.TempDir()
+	fail := true
+	l := &Logger{
+		Filename: filepath.Join(dir, "usage.log"),
+		Compress: true,
+		EncryptFile: func(dst io.Writer, src io.Reader) error {
+			if fail {
+				return errors.New("no key")
+			}
+			_, err := io.Copy(dst, src)
+			return err
+		},
+	}
+	backup := backupFile(t, dir, 1, ".log")
+
+	// The file is compressed, but its encryption fails, which leaves the compressed file.
+	if err := l.millRunOnce(); err == nil {
+		t.Fatal("millRunOnce succeeded, want the encryption error")
+	}
+	assertFiles(t, dir, []string{backup + ".gz"})
+
+	// The next run encrypts the compressed file without compressing it again.
+	fail = false
+	if err := l.millRunOnce(); err != nil {
+		t.Fatal(err)
+	}
+	assertFiles(t, dir, []string{backup + ".gz.enc"})
+}
+
+// backupFile creates the backup of usage.log rotated minutes after midnight, with ext, returning its name.
+func backupFile(t *testing.T, dir string, minutes int, ext string) string {
+	t.Helper()
+	name := "usage-" + backupTime(minutes) + ext
+	writeFile(t, filepath.Join(dir, name))
+	return name
+}
+
+func backupTime(minutes int) string {
+	return time.Date(2024, 6, 1, 0, minutes, 0, 0, time.UTC).Format(backupTimeFormat)
+}
+
+func writeFile(t *testing.T, name string) {
+	t.Helper()
+	if err := os.WriteFile(name, []byte("record\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// assertFiles checks that the files of dir are want.
+func assertFiles(t *testing.T, dir string, want []string) {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	sort.Strings(want)
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("files = %v, want %v", names, want)
+	}
+}
+
+func assertMode(t *testing.T, name string, want os.FileMode) {
+	t.Helper()
+	info, err := os.Stat(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode() != want {
+		t.Errorf("mode of %s = %v, want %v", name, info.Mode(), want)
+	}
+}
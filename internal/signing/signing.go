@@ -0,0 +1,135 @@
+// Package signing makes the records written by samplers tamper-evident. Every record, a JSON object,
+// gets a signature member holding its sequence number, the MAC of the previous record and its own
+// HMAC-SHA256, which covers the three, so the records form a hash chain across rotated files.
+package signing
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// MinKeySize is the minimum size in bytes of a signing key.
+const MinKeySize = 16
+
+// signatureMember is the name of the JSON member holding the signature, always the last one of a record.
+const signatureMember = `"signature":`
+
+// Signature is the signature member of a signed record.
+type Signature struct {
+	// Seq is the position of the record in the chain, starting at 1.
+	Seq uint64 `json:"seq"`
+	// Prev is the MAC of the previous record, empty for the first one.
+	Prev string `json:"prev"`
+	// MAC is the hex encoded HMAC-SHA256 of the sequence number, the previous MAC and the record.
+	MAC string `json:"mac"`
+}
+
+// LoadKey reads a signing key from a file. Leading and trailing whitespace is ignored.
+func LoadKey(path string) ([]byte, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("signing key: %w", err)
+	}
+	key := bytes.TrimSpace(content)
+	if len(key) < MinKeySize {
+		return nil, fmt.Errorf("signing key %s must have at least %d bytes", path, MinKeySize)
+	}
+	return key, nil
+}
+
+// Signer signs records, chaining each one to the previous one. It is not safe for concurrent use.
+type Signer struct {
+	key  []byte
+	seq  uint64
+	prev string
+}
+
+// NewSigner creates a Signer continuing a chain whose last record has the given sequence number and MAC,
+// 0 and an empty MAC for a new chain.
+func NewSigner(key []byte, seq uint64, prev string) *Signer {
+	return &Signer{
+		key:  key,
+		seq:  seq,
+		prev: prev,
+	}
+}
+
+// Sign returns the record with its signature added as last member. The record must be a JSON object.
+func (s *Signer) Sign(record []byte) ([]byte, error) {
+	record = bytes.TrimSpace(record)
+	if len(record) < 2 || record[0] != '{' || record[len(record)-1] != '}' {
+		return nil, errors.New("only JSON object records can be signed")
+	}
+
+	signature := Signature{Seq: s.seq + 1, Prev: s.prev}
+	signature.MAC = mac(s.key, signature.Seq, signature.Prev, record)
+	encoded, err := json.Marshal(signature)
+	if err != nil {
+		return nil, err
+	}
+
+	signed := make([]byte, 0, len(record)+len(signatureMember)+len(encoded)+1)
+	signed = append(signed, record[:len(record)-1]...)
+	if len(bytes.TrimSpace(record[1:len(record)-1])) > 0 {
+		signed = append(signed, ',')
+	}
+	signed = append(signed, signatureMember...)
+	signed = append(signed, encoded...)
+	signed = append(signed, '}')
+
+	s.seq, s.prev = signature.Seq, signature.MAC
+	return signed, nil
+}
+
+// State returns the sequence number and the MAC of the last record signed.
+func (s *Signer) State() (uint64, string) {
+	return s.seq, s.prev
+}
+
+// Split returns the record as it was signed and its signature.
+func Split(signed []byte) ([]byte, Signature, error) {
+	signed = bytes.TrimSpace(signed)
+	idx := bytes.LastIndex(signed, []byte(signatureMember))
+	if idx < 1 || signed[len(signed)-1] != '}' {
+		return nil, Signature{}, errors.New("record is not signed")
+	}
+
+	var signature Signature
+	if err := json.Unmarshal(signed[idx+len(signatureMember):len(signed)-1], &signature); err != nil {
+		return nil, Signature{}, fmt.Errorf("malformed signature: %w", err)
+	}
+
+	var record []byte
+	switch signed[idx-1] {
+	case ',':
+		record = append(append([]byte{}, signed[:idx-1]...), '}')
+	case '{':
+		record = append(append([]byte{}, signed[:idx]...), '}')
+	default:
+		return nil, Signature{}, errors.New("record is not signed")
+	}
+	return record, signature, nil
+}
+
+// Valid tells whether the signature matches the record.
+func Valid(key []byte, record []byte, signature Signature) bool {
+	expected := mac(key, signature.Seq, signature.Prev, record)
+	return hmac.Equal([]byte(expected), []byte(signature.MAC))
+}
+
+func mac(key []byte, seq uint64, prev string, record []byte) string {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(strconv.FormatUint(seq, 10)))
+	h.Write([]byte{'\n'})
+	h.Write([]byte(prev))
+	h.Write([]byte{'\n'})
+	h.Write(record)
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,155 @@
+package signing
+
+import (
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var testKey = []byte("0123456789abcdef0123456789abcdef")
+
+func TestSignAndSplit(t *testing.T) {
+	signer := NewSigner(testKey, 0, "")
+	for _, record := range []string{`{"usage_bytes":10}`, `{}`} {
+		signed, err := signer.Sign([]byte(record))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, signature, err := Split(signed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != record {
+			t.Errorf("Split() record = %s, want %s", got, record)
+		}
+		if !Valid(testKey, got, signature) {
+			t.Errorf("Valid() = false for %s", signed)
+		}
+	}
+
+	seq, prev := signer.State()
+	if seq != 2 || prev == "" {
+		t.Errorf("State() = %d, %q", seq, prev)
+	}
+	if _, err := signer.Sign([]byte("a,b,c")); err == nil {
+		t.Error("Sign() of a csv record returned no error")
+	}
+}
+
+func TestVerify(t *testing.T) {
+	lines := signedLines(t, 6)
+
+	tests := []struct {
+		name  string
+		files map[string][]string
+		kinds []string
+	}{
+		{
+			name:  "intact rotated files",
+			files: map[string][]string{"metering.log": lines[3:], "metering-backup.log.gz": lines[:3]},
+		},
+		{
+			name:  "modified",
+			files: map[string][]string{"metering.log": append(append(append([]string{}, lines[:2]...), strings.Replace(lines[2], "30", "31", 1)), lines[3:]...)},
+			kinds: []string{ProblemModified},
+		},
+		{
+			name:  "dropped",
+			files: map[string][]string{"metering.log": append(append([]string{}, lines[:2]...), lines[3:]...)},
+			kinds: []string{ProblemDropped},
+		},
+		{
+			name:  "reordered",
+			files: map[string][]string{"metering.log": {lines[0], lines[1], lines[3], lines[2], lines[4], lines[5]}},
+			kinds: []string{ProblemDropped, ProblemReordered},
+		},
+		{
+			name:  "unsigned",
+			files: map[string][]string{"metering.log": append(append([]string{}, lines...), `{"usage_bytes":70}`)},
+			kinds: []string{ProblemUnsigned},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			var files []string
+			for name, content := range tt.files {
+				files = append(files, writeFile(t, filepath.Join(dir, name), content))
+			}
+
+			report, err := Verify(testKey, files)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var kinds []string
+			for _, problem := range report.Problems {
+				kinds = append(kinds, problem.Kind)
+			}
+			if strings.Join(kinds, ",") != strings.Join(tt.kinds, ",") {
+				t.Errorf("Verify() problems = %v, want kinds %v", report.Problems, tt.kinds)
+			}
+			if report.FirstSeq != 1 || report.LastSeq != 6 {
+				t.Errorf("Verify() sequence = %d to %d, want 1 to 6", report.FirstSeq, report.LastSeq)
+			}
+		})
+	}
+}
+
+func TestVerifyWrongKey(t *testing.T) {
+	file := writeFile(t, filepath.Join(t.TempDir(), "metering.log"), signedLines(t, 2))
+	report, err := Verify([]byte("another key of 32 bytes........."), []string{file})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Records != 0 || len(report.Problems) != 2 {
+		t.Errorf("Verify() = %+v, want 2 modified records", report)
+	}
+}
+
+func signedLines(t *testing.T, n int) []string {
+	signer := NewSigner(testKey, 0, "")
+	var lines []string
+	for i := 1; i <= n; i++ {
+		signed, err := signer.Sign([]byte(`{"usage_bytes":` + string(rune('0'+i)) + `0}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines = append(lines, string(signed))
+	}
+	return lines
+}
+
+func writeFile(t *testing.T, name string, lines []string) string {
+	f, err := os.Create(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	content := []byte(strings.Join(lines, "\n") + "\n")
+	if strings.HasSuffix(name, ".gz") {
+		gz := gzip.NewWriter(f)
+		defer gz.Close()
+		_, err = gz.Write(content)
+	} else {
+		_, err = f.Write(content)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return name
+}
+
+func TestLoadKey(t *testing.T) {
+	dir := t.TempDir()
+	short := writeFile(t, filepath.Join(dir, "short.key"), []string{"short"})
+	if _, err := LoadKey(short); err == nil {
+		t.Error("LoadKey() of a short key returned no error")
+	}
+	valid := writeFile(t, filepath.Join(dir, "valid.key"), []string{string(testKey)})
+	key, err := LoadKey(valid)
+	if err != nil || string(key) != string(testKey) {
+		t.Errorf("LoadKey() = %q, %v", key, err)
+	}
+}
@@ -0,0 +1,152 @@
+package signing
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Problem kinds found by Verify.
+const (
+	// ProblemModified is a record whose MAC does not match its content, or not chained to the previous one.
+	ProblemModified = "modified"
+	// ProblemDropped is a gap in the sequence numbers.
+	ProblemDropped = "dropped"
+	// ProblemReordered is a record whose sequence number is lower than the one of a previous record.
+	ProblemReordered = "reordered"
+	// ProblemUnsigned is a line without a valid signature.
+	ProblemUnsigned = "unsigned"
+)
+
+// Problem is an inconsistency found in the signed records.
+type Problem struct {
+	File string
+	// Line is the line of the record in File, starting at 1.
+	Line int
+	Kind string
+	Msg  string
+}
+
+func (p Problem) String() string {
+	return fmt.Sprintf("%s:%d: %s: %s", p.File, p.Line, p.Kind, p.Msg)
+}
+
+// Report is the result of Verify.
+type Report struct {
+	// Records is the number of records with a valid MAC.
+	Records int
+	// FirstSeq and LastSeq are the sequence numbers of the first and last records read.
+	FirstSeq uint64
+	LastSeq  uint64
+	Problems []Problem
+}
+
+// Verify checks the chain of the records of the files, such as a file_logger file and its rotated backups,
+// in any order. The files are ordered by the sequence number of their first record, gzip compressed
+// files are supported. Records dropped before the first record or after the last one cannot be detected,
+// the returned report tells the sequence numbers read so they can be compared with the expected ones.
+func Verify(key []byte, files []string) (Report, error) {
+	type signedFile struct {
+		name    string
+		records []signedLine
+	}
+
+	var read []signedFile
+	for _, name := range files {
+		records, err := readFile(name)
+		if err != nil {
+			return Report{}, fmt.Errorf("%s: %w", name, err)
+		}
+		read = append(read, signedFile{name: name, records: records})
+	}
+	firstSeq := func(f signedFile) uint64 {
+		for _, record := range f.records {
+			if record.err == nil {
+				return record.signature.Seq
+			}
+		}
+		return 0
+	}
+	sort.SliceStable(read, func(i, j int) bool {
+		return firstSeq(read[i]) < firstSeq(read[j])
+	})
+
+	var report Report
+	var expected uint64
+	var prev string
+	for _, f := range read {
+		for _, record := range f.records {
+			problem := func(kind string, format string, args ...any) {
+				report.Problems = append(report.Problems, Problem{File: f.name, Line: record.line, Kind: kind, Msg: fmt.Sprintf(format, args...)})
+			}
+			if record.err != nil {
+				problem(ProblemUnsigned, "%s", record.err)
+				continue
+			}
+
+			signature := record.signature
+			if !Valid(key, record.record, signature) {
+				problem(ProblemModified, "record %d does not match its MAC", signature.Seq)
+			} else {
+				report.Records++
+			}
+
+			switch {
+			case expected == 0:
+				report.FirstSeq = signature.Seq
+			case signature.Seq > expected:
+				problem(ProblemDropped, "records %d to %d are missing", expected, signature.Seq-1)
+			case signature.Seq < expected:
+				problem(ProblemReordered, "record %d found after record %d", signature.Seq, expected-1)
+			case signature.Prev != prev:
+				problem(ProblemModified, "record %d is not chained to record %d", signature.Seq, expected-1)
+			}
+			if signature.Seq >= expected {
+				expected, prev = signature.Seq+1, signature.MAC
+				report.LastSeq = signature.Seq
+			}
+		}
+	}
+	return report, nil
+}
+
+type signedLine struct {
+	line      int
+	record    []byte
+	signature Signature
+	err       error
+}
+
+func readFile(name string) ([]signedLine, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if strings.HasSuffix(name, ".gz") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	var records []signedLine
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(strings.TrimSpace(scanner.Text())) == 0 {
+			continue
+		}
+		record, signature, err := Split(scanner.Bytes())
+		records = append(records, signedLine{line: line, record: record, signature: signature, err: err})
+	}
+	return records, scanner.Err()
+}
@@ -0,0 +1,126 @@
+package sampler
+
+import (
+	"sync/atomic"
+
+	"github.com/fsgonz/otelnetstatsreceiver/internal/stats/scraper"
+)
+
+// NetlinkSampler samples the network statistics of an interface from a rtnetlink RTM_GETLINK dump,
+// which provides the 64 bit counters without reading /proc/net/dev.
+type NetlinkSampler struct {
+	// scraper reads the statistics of the interface from the dumped messages.
+	scraper *scraper.NetlinkScraper
+}
+
+// NewNetlinkSampler creates a NetlinkSampler reading the interface statistics with the given scraper.
+func NewNetlinkSampler(statsScraper *scraper.NetlinkScraper) *NetlinkSampler {
+	return &NetlinkSampler{
+		scraper: statsScraper,
+	}
+}
+
+// Sample returns the sum of the received and transmitted bytes of the scraper interface.
+func (s *NetlinkSampler) Sample() (uint64, error) {
+	networkUsageStats, err := s.SampleStats()
+	if err != nil {
+		return 0, err
+	}
+
+	return networkUsageStats.ReceivedBytes + networkUsageStats.TransmittedBytes, nil
+}
+
+// NetlinkSource is an InterfacesSource reading the statistics of all the interfaces from a single rtnetlink
+// RTM_GETLINK dump.
+type NetlinkSource struct{}
+
+// NewNetlinkSource creates a NetlinkSource.
+func NewNetlinkSource() *NetlinkSource {
+	return &NetlinkSource{}
+}
+
+// fallbackLatch remembers that the primary sampler of a fallback failed, so that it is not tried again.
+type fallbackLatch struct {
+	failed atomic.Bool
+	// onFallback, if set, is called with the primary error when the fallback is latched.
+	onFallback func(error)
+}
+
+// fail latches the fallback, calling onFallback the first time.
+func (l *fallbackLatch) fail(err error) {
+	if l.failed.CompareAndSwap(false, true) && l.onFallback != nil {
+		l.onFallback(err)
+	}
+}
+
+// FallbackSampler samples with a primary sampler and, once it fails, with a fallback sampler.
+//
+// It is used to read the statistics from netlink and fall back to procfs where netlink sockets
+// are not permitted, such as in restricted containers. The primary sampler is not tried again
+// after failing, so the counters are not read from both sources in turn.
+type FallbackSampler struct {
+	primary  StatsSampler
+	fallback StatsSampler
+	latch    fallbackLatch
+}
+
+// NewFallbackSampler creates a FallbackSampler. onFallback, if not nil, is called with the primary
+// error when the sampler falls back.
+func NewFallbackSampler(primary StatsSampler, fallback StatsSampler, onFallback func(error)) *FallbackSampler {
+	return &FallbackSampler{
+		primary:  primary,
+		fallback: fallback,
+		latch:    fallbackLatch{onFallback: onFallback},
+	}
+}
+
+func (s *FallbackSampler) Sample() (uint64, error) {
+	networkUsageStats, err := s.SampleStats()
+	if err != nil {
+		return 0, err
+	}
+
+	return networkUsageStats.ReceivedBytes + networkUsageStats.TransmittedBytes, nil
+}
+
+// SampleStats samples the statistics with the primary sampler until it fails, then with the fallback one.
+func (s *FallbackSampler) SampleStats() (scraper.NetworkStats, error) {
+	if !s.latch.failed.Load() {
+		networkUsageStats, err := s.primary.SampleStats()
+		if err == nil {
+			return networkUsageStats, nil
+		}
+		s.latch.fail(err)
+	}
+	return s.fallback.SampleStats()
+}
+
+// FallbackSource samples with a primary InterfacesSource and, once it fails, with a fallback one, as
+// FallbackSampler does.
+type FallbackSource struct {
+	primary  InterfacesSource
+	fallback InterfacesSource
+	latch    fallbackLatch
+}
+
+// NewFallbackSource creates a FallbackSource. onFallback, if not nil, is called with the primary error
+// when the source falls back.
+func NewFallbackSource(primary InterfacesSource, fallback InterfacesSource, onFallback func(error)) *FallbackSource {
+	return &FallbackSource{
+		primary:  primary,
+		fallback: fallback,
+		latch:    fallbackLatch{onFallback: onFallback},
+	}
+}
+
+// SampleEach samples the interfaces with the primary source until it fails, then with the fallback one.
+func (s *FallbackSource) SampleEach(names []string) (map[string]scraper.NetworkStats, error) {
+	if !s.latch.failed.Load() {
+		interfaces, err := s.primary.SampleEach(names)
+		if err == nil {
+			return interfaces, nil
+		}
+		s.latch.fail(err)
+	}
+	return s.fallback.SampleEach(names)
+}
@@ -0,0 +1,130 @@
+//go:build linux
+
+package sampler
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/fsgonz/otelnetstatsreceiver/internal/stats/scraper"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// netlinkSeq is the sequence number of the dump request, the socket only sends one.
+	netlinkSeq = 1
+	// netlinkReceiveTimeout bounds the wait for each datagram of the dump.
+	netlinkReceiveTimeout = 5 * time.Second
+)
+
+// netlinkDump sends a RTM_GETLINK dump request on a NETLINK_ROUTE socket and returns the
+// messages answering it, up to NLMSG_DONE.
+func netlinkDump() ([]byte, error) {
+	fd, err := unix.Socket(unix.AF_NETLINK, unix.SOCK_RAW|unix.SOCK_CLOEXEC, unix.NETLINK_ROUTE)
+	if err != nil {
+		return nil, fmt.Errorf("netlink socket: %w", err)
+	}
+	defer unix.Close(fd)
+
+	timeout := unix.NsecToTimeval(netlinkReceiveTimeout.Nanoseconds())
+	if err := unix.SetsockoptTimeval(fd, unix.SOL_SOCKET, unix.SO_RCVTIMEO, &timeout); err != nil {
+		return nil, fmt.Errorf("netlink receive timeout: %w", err)
+	}
+	if err := unix.Bind(fd, &unix.SockaddrNetlink{Family: unix.AF_NETLINK}); err != nil {
+		return nil, fmt.Errorf("netlink bind: %w", err)
+	}
+	// The kernel answers with the port id it assigned to the socket on bind.
+	sockaddr, err := unix.Getsockname(fd)
+	if err != nil {
+		return nil, fmt.Errorf("netlink sockname: %w", err)
+	}
+	local, ok := sockaddr.(*unix.SockaddrNetlink)
+	if !ok {
+		return nil, fmt.Errorf("netlink sockname: unexpected address %T", sockaddr)
+	}
+
+	request := make([]byte, unix.NLMSG_HDRLEN+unix.SizeofIfInfomsg)
+	binary.NativeEndian.PutUint32(request[0:4], uint32(len(request)))
+	binary.NativeEndian.PutUint16(request[4:6], unix.RTM_GETLINK)
+	binary.NativeEndian.PutUint16(request[6:8], unix.NLM_F_REQUEST|unix.NLM_F_DUMP)
+	binary.NativeEndian.PutUint32(request[8:12], netlinkSeq)
+	request[unix.NLMSG_HDRLEN] = unix.AF_UNSPEC
+	if err := unix.Sendto(fd, request, 0, &unix.SockaddrNetlink{Family: unix.AF_NETLINK}); err != nil {
+		return nil, fmt.Errorf("netlink send: %w", err)
+	}
+
+	var dump bytes.Buffer
+	buf := make([]byte, 32*1024)
+	for {
+		n, _, err := unix.Recvfrom(fd, buf, 0)
+		if errors.Is(err, unix.EAGAIN) {
+			return nil, fmt.Errorf("netlink receive: no answer within %s", netlinkReceiveTimeout)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("netlink receive: %w", err)
+		}
+		messages, done := answers(buf[:n], netlinkSeq, local.Pid)
+		dump.Write(messages)
+		if done {
+			return dump.Bytes(), nil
+		}
+	}
+}
+
+// answers returns the messages of a datagram answering the request seq sent from the socket portID, and
+// whether one of them ends the dump. Other messages, such as the ones of another request, are skipped.
+func answers(datagram []byte, seq uint32, portID uint32) (messages []byte, done bool) {
+	for len(datagram) >= unix.NLMSG_HDRLEN {
+		msgLen := int(binary.NativeEndian.Uint32(datagram[0:4]))
+		if msgLen < unix.NLMSG_HDRLEN || msgLen > len(datagram) {
+			// The malformed message is kept, so the scraper reports it.
+			return append(messages, datagram...), true
+		}
+		msgType := binary.NativeEndian.Uint16(datagram[4:6])
+		msgSeq := binary.NativeEndian.Uint32(datagram[8:12])
+		msgPortID := binary.NativeEndian.Uint32(datagram[12:16])
+		next := min((msgLen+unix.NLMSG_ALIGNTO-1)&^(unix.NLMSG_ALIGNTO-1), len(datagram))
+		if msgSeq == seq && msgPortID == portID {
+			messages = append(messages, datagram[:next]...)
+			if msgType == unix.NLMSG_DONE || msgType == unix.NLMSG_ERROR {
+				return messages, true
+			}
+		}
+		datagram = datagram[next:]
+	}
+	return messages, false
+}
+
+// SampleStats dumps the links over netlink and returns the statistics of the scraper interface.
+func (s *NetlinkSampler) SampleStats() (scraper.NetworkStats, error) {
+	dump, err := netlinkDump()
+	if err != nil {
+		return scraper.NetworkStats{}, err
+	}
+
+	return s.scraper.Scrape(bytes.NewReader(dump))
+}
+
+// SampleEach dumps the links over netlink once and returns the statistics of the named interfaces. An
+// interface removed since it was named is missing from the result.
+func (s *NetlinkSource) SampleEach(names []string) (map[string]scraper.NetworkStats, error) {
+	dump, err := netlinkDump()
+	if err != nil {
+		return nil, err
+	}
+	links, err := scraper.ScrapeLinks(bytes.NewReader(dump))
+	if err != nil {
+		return nil, err
+	}
+
+	interfaces := make(map[string]scraper.NetworkStats, len(names))
+	for _, name := range names {
+		if stats, ok := links[name]; ok {
+			interfaces[name] = stats
+		}
+	}
+	return interfaces, nil
+}
@@ -0,0 +1,55 @@
+//go:build linux
+
+package sampler
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/fsgonz/otelnetstatsreceiver/internal/stats/scraper"
+	"golang.org/x/sys/unix"
+)
+
+func TestAnswers(t *testing.T) {
+	message := func(msgType uint16, seq uint32, portID uint32) []byte {
+		msg := make([]byte, unix.NLMSG_HDRLEN+4)
+		binary.NativeEndian.PutUint32(msg[0:4], uint32(len(msg)))
+		binary.NativeEndian.PutUint16(msg[4:6], msgType)
+		binary.NativeEndian.PutUint32(msg[8:12], seq)
+		binary.NativeEndian.PutUint32(msg[12:16], portID)
+		return msg
+	}
+	own := message(unix.RTM_NEWLINK, 1, 42)
+
+	var datagram []byte
+	datagram = append(datagram, message(unix.RTM_NEWLINK, 7, 42)...)
+	datagram = append(datagram, own...)
+	datagram = append(datagram, message(unix.RTM_NEWLINK, 1, 43)...)
+	messages, done := answers(datagram, 1, 42)
+	if done || string(messages) != string(own) {
+		t.Errorf("got %v done %t want only the message answering the request", messages, done)
+	}
+
+	// The end of another dump does not end the one of the request.
+	if _, done := answers(message(unix.NLMSG_DONE, 2, 42), 1, 42); done {
+		t.Errorf("the end of another dump was taken for the end of the request")
+	}
+	if _, done := answers(message(unix.NLMSG_DONE, 1, 42), 1, 42); !done {
+		t.Errorf("the end of the dump was not detected")
+	}
+}
+
+func TestNetlinkDump(t *testing.T) {
+	dump, err := netlinkDump()
+	if err != nil {
+		t.Skipf("netlink is not permitted: %s", err)
+	}
+	links, err := scraper.ScrapeLinks(bytes.NewReader(dump))
+	if err != nil {
+		t.Fatalf("Error on scraping the dump %s", err.Error())
+	}
+	if _, ok := links["lo"]; !ok {
+		t.Errorf("got links %v want lo among them", links)
+	}
+}
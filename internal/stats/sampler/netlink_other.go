@@ -0,0 +1,19 @@
+//go:build !linux
+
+package sampler
+
+import (
+	"errors"
+
+	"github.com/fsgonz/otelnetstatsreceiver/internal/stats/scraper"
+)
+
+// SampleStats always fails, netlink is only available on linux.
+func (s *NetlinkSampler) SampleStats() (scraper.NetworkStats, error) {
+	return scraper.NetworkStats{}, errors.New("netlink is only supported on linux")
+}
+
+// SampleEach always fails, netlink is only available on linux.
+func (s *NetlinkSource) SampleEach([]string) (map[string]scraper.NetworkStats, error) {
+	return nil, errors.New("netlink is only supported on linux")
+}
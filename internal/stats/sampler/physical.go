@@ -0,0 +1,187 @@
+package sampler
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/fsgonz/otelnetstatsreceiver/internal/stats/scraper"
+)
+
+// InterfacesSampler is a StatsSampler of several interfaces that also samples each one, so that the usage of
+// an interface added or removed between two samples is not mistaken for the one of the others.
+type InterfacesSampler interface {
+	StatsSampler
+	// SampleInterfaces samples the statistics of each interface, by name.
+	SampleInterfaces() (map[string]scraper.NetworkStats, error)
+}
+
+// InterfacesSource samples the statistics of a set of interfaces.
+type InterfacesSource interface {
+	// SampleEach samples the statistics of each of the named interfaces. An interface removed since it was
+	// named may be missing from the result.
+	SampleEach(names []string) (map[string]scraper.NetworkStats, error)
+}
+
+// PhysicalInterfacesSampler samples the sum of the network statistics of the physical interfaces,
+// so traffic going through a NIC is counted once and not again through the bridges, veth pairs,
+// vlans and tunnels it also crosses.
+//
+// The interfaces are listed and classified from sysfs on every sample, so NICs added or removed
+// are followed. SampleInterfaces returns the statistics of each one, so their usage is computed per
+// interface.
+type PhysicalInterfacesSampler struct {
+	// sysfsRoot is the mount point of the sysfs the interfaces are classified from.
+	sysfsRoot string
+	// source reads the counters of the physical interfaces.
+	source InterfacesSource
+}
+
+// NewPhysicalInterfacesSampler creates a PhysicalInterfacesSampler classifying the interfaces below
+// sysfsRoot and reading the counters of the physical ones from source.
+func NewPhysicalInterfacesSampler(sysfsRoot string, source InterfacesSource) *PhysicalInterfacesSampler {
+	if sysfsRoot == "" {
+		sysfsRoot = scraper.DefaultSysfsRoot
+	}
+	return &PhysicalInterfacesSampler{
+		sysfsRoot: sysfsRoot,
+		source:    source,
+	}
+}
+
+func (s *PhysicalInterfacesSampler) Sample() (uint64, error) {
+	networkUsageStats, err := s.SampleStats()
+	if err != nil {
+		return 0, err
+	}
+
+	return networkUsageStats.ReceivedBytes + networkUsageStats.TransmittedBytes, nil
+}
+
+// SampleStats returns the sum of the statistics of the physical interfaces.
+func (s *PhysicalInterfacesSampler) SampleStats() (scraper.NetworkStats, error) {
+	interfaces, err := s.SampleInterfaces()
+	if err != nil {
+		return scraper.NetworkStats{}, err
+	}
+	return SumStats(interfaces), nil
+}
+
+// SampleInterfaces returns the statistics of each physical interface, by name.
+func (s *PhysicalInterfacesSampler) SampleInterfaces() (map[string]scraper.NetworkStats, error) {
+	names, err := s.PhysicalInterfaces()
+	if err != nil {
+		return nil, err
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("no physical interface found in %s", s.sysfsRoot)
+	}
+	return s.source.SampleEach(names)
+}
+
+// Metadata returns the metadata of the source, if it describes the interfaces.
+func (s *PhysicalInterfacesSampler) Metadata() map[string]string {
+	if metadataSource, ok := s.source.(interface{ Metadata() map[string]string }); ok {
+		return metadataSource.Metadata()
+	}
+	return nil
+}
+
+// PhysicalInterfaces returns the names of the physical interfaces, in order.
+func (s *PhysicalInterfacesSampler) PhysicalInterfaces() ([]string, error) {
+	names, err := scraper.ListInterfaces(s.sysfsRoot)
+	if err != nil {
+		return nil, err
+	}
+
+	var physical []string
+	for _, name := range names {
+		// An interface removed since it was listed is skipped.
+		kind, err := scraper.NewSysfsScraper(s.sysfsRoot, name).Kind()
+		if err == nil && kind == scraper.KindPhysical {
+			physical = append(physical, name)
+		}
+	}
+	return physical, nil
+}
+
+// SumStats returns the sum of the statistics of the interfaces.
+func SumStats(interfaces map[string]scraper.NetworkStats) scraper.NetworkStats {
+	var sum scraper.NetworkStats
+	for _, stats := range interfaces {
+		sum.ReceivedBytes += stats.ReceivedBytes
+		sum.TransmittedBytes += stats.TransmittedBytes
+		sum.ReceivedPackets += stats.ReceivedPackets
+		sum.TransmittedPackets += stats.TransmittedPackets
+		sum.ReceivedDropped += stats.ReceivedDropped
+		sum.TransmittedDropped += stats.TransmittedDropped
+	}
+	return sum
+}
+
+// InterfaceSamplers is an InterfacesSource reading each interface with its own StatsSampler. The samplers
+// are kept while their interface is sampled, so stateful ones, such as the SysfsSampler, follow it.
+type InterfaceSamplers struct {
+	// samplerFor returns the sampler reading the counters of an interface.
+	samplerFor func(interfaceName string) StatsSampler
+
+	mu       sync.Mutex
+	samplers map[string]StatsSampler
+	// names are the interfaces of the last sample, in order.
+	names []string
+}
+
+// NewInterfaceSamplers creates an InterfaceSamplers reading each interface with the sampler samplerFor returns.
+func NewInterfaceSamplers(samplerFor func(interfaceName string) StatsSampler) *InterfaceSamplers {
+	return &InterfaceSamplers{
+		samplerFor: samplerFor,
+		samplers:   map[string]StatsSampler{},
+	}
+}
+
+// SampleEach samples each interface with its sampler, creating the samplers of new interfaces and dropping
+// the ones of the interfaces not named anymore.
+func (s *InterfaceSamplers) SampleEach(names []string) (map[string]scraper.NetworkStats, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	samplers := make(map[string]StatsSampler, len(names))
+	for _, name := range names {
+		if samplers[name] = s.samplers[name]; samplers[name] == nil {
+			samplers[name] = s.samplerFor(name)
+		}
+	}
+	s.samplers = samplers
+
+	interfaces := make(map[string]scraper.NetworkStats, len(names))
+	for _, name := range names {
+		stats, err := samplers[name].SampleStats()
+		if err != nil {
+			return nil, fmt.Errorf("interface '%s': %w", name, err)
+		}
+		interfaces[name] = stats
+	}
+	s.names = names
+	return interfaces, nil
+}
+
+// Metadata returns the metadata of the interfaces of the last sample whose samplers describe them, each key
+// prefixed with the name of its interface.
+func (s *InterfaceSamplers) Metadata() map[string]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var metadata map[string]string
+	for _, name := range s.names {
+		metadataSampler, ok := s.samplers[name].(MetadataSampler)
+		if !ok {
+			continue
+		}
+		for key, value := range metadataSampler.Metadata() {
+			if metadata == nil {
+				metadata = map[string]string{}
+			}
+			metadata[name+"."+key] = value
+		}
+	}
+	return metadata
+}
@@ -40,6 +40,14 @@ type Sampler interface {
 	Sample() (sampleValue uint64, err error)
 }
 
+// StatsSampler is a Sampler of network statistics, whose sampled value is the sum of the received
+// and transmitted bytes. The statistics also count the packets, from which rates are computed.
+type StatsSampler interface {
+	Sampler
+	// SampleStats samples the received and transmitted bytes and packets.
+	SampleStats() (scraper.NetworkStats, error)
+}
+
 // Storage for measurements.
 type Storage interface {
 	// Save the sample
@@ -193,11 +201,23 @@ func NewFileBasedSampler(uri string, statsScraper scraper.NetworkStatsScraper) *
 }
 
 func (s *FileBasedSampler) Sample() (uint64, error) {
-	f, err := os.Open(s.uri)
+	networkUsageStats, err := s.SampleStats()
 	if err != nil {
 		return 0, err
 	}
 
+	netIo := networkUsageStats.ReceivedBytes + networkUsageStats.TransmittedBytes
+
+	return netIo, nil
+}
+
+// SampleStats scrapes the network statistics from the file.
+func (s *FileBasedSampler) SampleStats() (scraper.NetworkStats, error) {
+	f, err := os.Open(s.uri)
+	if err != nil {
+		return scraper.NetworkStats{}, err
+	}
+
 	defer func(f *os.File) {
 		err := f.Close()
 		if err != nil {
@@ -205,13 +225,5 @@ func (s *FileBasedSampler) Sample() (uint64, error) {
 		}
 	}(f)
 
-	networkUsageStats, err := s.scraper.Scrape(f)
-
-	if err != nil {
-		return 0, err
-	}
-
-	netIo := networkUsageStats.ReceivedBytes + networkUsageStats.TransmittedBytes
-
-	return netIo, nil
+	return s.scraper.Scrape(f)
 }
@@ -9,8 +9,10 @@ import (
 	"io"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"strconv"
 	"testing"
+	"time"
 )
 
 func TestFileBasedSampler(t *testing.T) {
@@ -199,3 +201,234 @@ func (s *TestStorage) Save(value uint64) error {
 	s.LastCount = value
 	return nil
 }
+
+func TestFallbackSampler(t *testing.T) {
+	t.Run("samples with the primary sampler when it succeeds", func(t *testing.T) {
+		fallbacks := 0
+		sampler := NewFallbackSampler(NewFileBasedSampler("testdata/test1.data", &BreakLineScraper{}),
+			NewFileBasedSampler("nonExistingFile.data", &BreakLineScraper{}), func(error) { fallbacks++ })
+
+		got, err := sampler.Sample()
+
+		if err != nil {
+			t.Errorf("Error on sampling %s", err.Error())
+		}
+		if got != 1030 {
+			t.Errorf("got %d want %d", got, 1030)
+		}
+		if fallbacks != 0 {
+			t.Errorf("got %d fallbacks want 0", fallbacks)
+		}
+	})
+
+	t.Run("samples with the fallback sampler when the primary fails", func(t *testing.T) {
+		var fallbackErr error
+		sampler := NewFallbackSampler(NewFileBasedSampler("testdata/test1.data", &AlwaysFailScraper{Error: "denied"}),
+			NewFileBasedSampler("testdata/test1.data", &BreakLineScraper{}), func(err error) { fallbackErr = err })
+
+		got, err := sampler.Sample()
+
+		if err != nil {
+			t.Errorf("Error on sampling %s", err.Error())
+		}
+		if got != 1030 {
+			t.Errorf("got %d want %d", got, 1030)
+		}
+		if fallbackErr == nil || fallbackErr.Error() != "denied" {
+			t.Errorf("got fallback error %v want denied", fallbackErr)
+		}
+	})
+
+	t.Run("does not try the primary sampler again once it failed", func(t *testing.T) {
+		fallbacks := 0
+		primary := &countingSampler{StatsSampler: NewFileBasedSampler("testdata/test1.data", &AlwaysFailScraper{Error: "denied"})}
+		sampler := NewFallbackSampler(primary, NewFileBasedSampler("testdata/test1.data", &BreakLineScraper{}),
+			func(error) { fallbacks++ })
+
+		for i := 0; i < 3; i++ {
+			if _, err := sampler.Sample(); err != nil {
+				t.Errorf("Error on sampling %s", err.Error())
+			}
+		}
+		if primary.calls != 1 || fallbacks != 1 {
+			t.Errorf("got %d primary samples and %d fallbacks want 1 and 1", primary.calls, fallbacks)
+		}
+	})
+}
+
+// countingSampler counts the statistics sampled with the wrapped sampler.
+type countingSampler struct {
+	StatsSampler
+	calls int
+}
+
+func (s *countingSampler) SampleStats() (scraper.NetworkStats, error) {
+	s.calls++
+	return s.StatsSampler.SampleStats()
+}
+
+func TestSysfsSampler(t *testing.T) {
+	root := t.TempDir()
+	writeSysfsInterface(t, root, 1000000, 500000, "1000")
+
+	now := time.Unix(1700000000, 0)
+	sampler := NewSysfsSampler(scraper.NewSysfsScraper(root, "eth0"))
+	sampler.now = func() time.Time { return now }
+
+	got, err := sampler.Sample()
+	if err != nil {
+		t.Fatalf("Error on sampling %s", err.Error())
+	}
+	if got != 1500000 {
+		t.Errorf("got %d want %d", got, 1500000)
+	}
+	metadata := sampler.Metadata()
+	if metadata[LinkOperStateKey] != "up" || metadata[LinkSpeedKey] != "1000" || metadata[LinkMTUKey] != "1500" ||
+		metadata[LinkAddressKey] != "52:54:00:12:34:56" || metadata[LinkTypeKey] != "1" {
+		t.Errorf("unexpected link metadata %v", metadata)
+	}
+	if _, ok := metadata[LinkUtilizationKey]; ok {
+		t.Errorf("no utilization was expected on the first sample, got %v", metadata)
+	}
+
+	// 25 MB received in 2 seconds on a 1000 Mbps link is 100 Mbps, 10% of the speed.
+	writeSysfsInterface(t, root, 26000000, 600000, "1000")
+	now = now.Add(2 * time.Second)
+	if _, err := sampler.Sample(); err != nil {
+		t.Fatalf("Error on sampling %s", err.Error())
+	}
+	if utilization := sampler.Metadata()[LinkUtilizationKey]; utilization != "10.00" {
+		t.Errorf("got utilization %s want 10.00", utilization)
+	}
+
+	// No utilization is reported without link speed.
+	writeSysfsInterface(t, root, 27000000, 700000, "-1")
+	now = now.Add(time.Second)
+	if _, err := sampler.Sample(); err != nil {
+		t.Fatalf("Error on sampling %s", err.Error())
+	}
+	if utilization, ok := sampler.Metadata()[LinkUtilizationKey]; ok {
+		t.Errorf("no utilization was expected without speed, got %s", utilization)
+	}
+}
+
+func writeSysfsInterface(t *testing.T, root string, receivedBytes uint64, transmittedBytes uint64, speed string) {
+	dir := filepath.Join(root, "class", "net", "eth0")
+	if err := os.MkdirAll(filepath.Join(dir, "statistics"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"statistics/rx_bytes":   strconv.FormatUint(receivedBytes, 10),
+		"statistics/tx_bytes":   strconv.FormatUint(transmittedBytes, 10),
+		"statistics/rx_packets": "10",
+		"statistics/tx_packets": "20",
+		"statistics/rx_dropped": "0",
+		"statistics/tx_dropped": "0",
+		"operstate":             "up",
+		"speed":                 speed,
+		"mtu":                   "1500",
+		"address":               "52:54:00:12:34:56",
+		"type":                  "1",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content+"\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestPhysicalInterfacesSampler(t *testing.T) {
+	const sysfsRoot = "../scraper/testdata/sys"
+
+	t.Run("sums the counters of the physical interfaces only", func(t *testing.T) {
+		sampler := NewPhysicalInterfacesSampler(sysfsRoot, NewInterfaceSamplers(func(name string) StatsSampler {
+			return NewSysfsSampler(scraper.NewSysfsScraper(sysfsRoot, name))
+		}))
+
+		physical, err := sampler.PhysicalInterfaces()
+		if err != nil {
+			t.Fatalf("Error on listing %s", err.Error())
+		}
+		if fmt.Sprint(physical) != "[eth0 eth1]" {
+			t.Errorf("got %v want [eth0 eth1]", physical)
+		}
+
+		got, err := sampler.Sample()
+		if err != nil {
+			t.Fatalf("Error on sampling %s", err.Error())
+		}
+		want := uint64(3862937603 + 281882792 + 1000 + 2000)
+		if got != want {
+			t.Errorf("got %d want %d", got, want)
+		}
+
+		interfaces, err := sampler.SampleInterfaces()
+		if err != nil {
+			t.Fatalf("Error on sampling %s", err.Error())
+		}
+		if eth1 := interfaces["eth1"]; len(interfaces) != 2 || eth1.ReceivedBytes+eth1.TransmittedBytes != 3000 {
+			t.Errorf("got %v want the eth0 and eth1 statistics", interfaces)
+		}
+		if metadata := sampler.Metadata(); metadata["eth0."+LinkOperStateKey] == "" || metadata["eth1."+LinkOperStateKey] == "" {
+			t.Errorf("got metadata %v want the links of eth0 and eth1", metadata)
+		}
+	})
+
+	t.Run("when an interface cannot be sampled an error is raised", func(t *testing.T) {
+		sampler := NewPhysicalInterfacesSampler(sysfsRoot, NewInterfaceSamplers(func(string) StatsSampler {
+			return NewFileBasedSampler("testdata/test1.data", &AlwaysFailScraper{Error: "not found"})
+		}))
+
+		if _, err := sampler.Sample(); err == nil || err.Error() != "interface 'eth0': not found" {
+			t.Errorf("got %v want interface 'eth0': not found", err)
+		}
+	})
+}
+
+func TestInterfaceSamplers(t *testing.T) {
+	created := map[string]int{}
+	samplers := NewInterfaceSamplers(func(name string) StatsSampler {
+		created[name]++
+		return NewFileBasedSampler("testdata/test1.data", &BreakLineScraper{})
+	})
+
+	for _, names := range [][]string{{"eth0"}, {"eth0", "eth1"}, {"eth1"}, {"eth0"}} {
+		interfaces, err := samplers.SampleEach(names)
+		if err != nil {
+			t.Fatalf("Error on sampling %s", err.Error())
+		}
+		if len(interfaces) != len(names) {
+			t.Errorf("got %v want the statistics of %v", interfaces, names)
+		}
+	}
+
+	// The sampler of an interface is kept while it is sampled, and created again once it comes back.
+	if created["eth0"] != 2 || created["eth1"] != 1 {
+		t.Errorf("got %v samplers created want 2 for eth0 and 1 for eth1", created)
+	}
+}
+
+func TestFallbackSource(t *testing.T) {
+	fallbacks := 0
+	source := NewFallbackSource(
+		NewInterfaceSamplers(func(string) StatsSampler {
+			return NewFileBasedSampler("testdata/test1.data", &AlwaysFailScraper{Error: "denied"})
+		}),
+		NewInterfaceSamplers(func(string) StatsSampler {
+			return NewFileBasedSampler("testdata/test1.data", &BreakLineScraper{})
+		}),
+		func(error) { fallbacks++ })
+
+	for i := 0; i < 2; i++ {
+		interfaces, err := source.SampleEach([]string{"eth0"})
+		if err != nil {
+			t.Fatalf("Error on sampling %s", err.Error())
+		}
+		if eth0 := interfaces["eth0"]; eth0.ReceivedBytes+eth0.TransmittedBytes != 1030 {
+			t.Errorf("got %v want 1030 bytes for eth0", interfaces)
+		}
+	}
+	if fallbacks != 1 {
+		t.Errorf("got %d fallbacks want 1", fallbacks)
+	}
+}
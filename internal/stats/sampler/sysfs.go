@@ -0,0 +1,113 @@
+package sampler
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/fsgonz/otelnetstatsreceiver/internal/stats/scraper"
+)
+
+// Metadata keys describing the link of a SysfsSampler sample.
+const (
+	LinkOperStateKey   = "link.operstate"
+	LinkSpeedKey       = "link.speed_mbps"
+	LinkMTUKey         = "link.mtu"
+	LinkAddressKey     = "link.address"
+	LinkTypeKey        = "link.type"
+	LinkUtilizationKey = "link.utilization_pct"
+)
+
+const (
+	// utilizationPrecision is the number of decimals of the utilization percentage.
+	utilizationPrecision = 2
+	bitsPerByte          = 8
+	bitsPerMegabit       = 1_000_000
+)
+
+// MetadataSampler is a Sampler that describes its last sample, so the records can be enriched.
+type MetadataSampler interface {
+	Sampler
+	// Metadata returns attributes of the last sample, or nil if there is none.
+	Metadata() map[string]string
+}
+
+// SysfsSampler samples the network statistics of an interface from /sys/class/net. Besides the
+// received and transmitted bytes it describes the link and, when the link reports its speed, the
+// utilization since the previous sample, the bytes per second relative to the speed.
+type SysfsSampler struct {
+	scraper *scraper.SysfsScraper
+	// now returns the time of a sample, time.Now unless replaced by tests.
+	now func() time.Time
+
+	mu       sync.Mutex
+	last     *scraper.InterfaceStats
+	lastTime time.Time
+	metadata map[string]string
+}
+
+// NewSysfsSampler creates a SysfsSampler reading the interface with the given scraper.
+func NewSysfsSampler(statsScraper *scraper.SysfsScraper) *SysfsSampler {
+	return &SysfsSampler{
+		scraper: statsScraper,
+		now:     time.Now,
+	}
+}
+
+func (s *SysfsSampler) Sample() (uint64, error) {
+	networkUsageStats, err := s.SampleStats()
+	if err != nil {
+		return 0, err
+	}
+
+	return networkUsageStats.ReceivedBytes + networkUsageStats.TransmittedBytes, nil
+}
+
+// SampleStats scrapes the statistics of the interface and describes its link.
+func (s *SysfsSampler) SampleStats() (scraper.NetworkStats, error) {
+	stats, err := s.scraper.ScrapeInterface()
+	if err != nil {
+		return scraper.NetworkStats{}, err
+	}
+	now := s.now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	link := stats.Link
+	s.metadata = map[string]string{
+		LinkOperStateKey: link.OperState,
+		LinkSpeedKey:     strconv.FormatInt(link.SpeedMbps, 10),
+		LinkMTUKey:       strconv.Itoa(link.MTU),
+		LinkAddressKey:   link.Address,
+		LinkTypeKey:      strconv.Itoa(link.Type),
+	}
+	if utilization, ok := s.utilization(stats, now); ok {
+		s.metadata[LinkUtilizationKey] = strconv.FormatFloat(utilization, 'f', utilizationPrecision, 64)
+	}
+	s.last = &stats
+	s.lastTime = now
+
+	return stats.NetworkStats, nil
+}
+
+// utilization returns the percentage of the link speed used since the last sample. The link is
+// full duplex, so the busiest direction is compared with the speed.
+func (s *SysfsSampler) utilization(stats scraper.InterfaceStats, now time.Time) (float64, bool) {
+	elapsed := now.Sub(s.lastTime).Seconds()
+	if s.last == nil || stats.Link.SpeedMbps <= 0 || elapsed <= 0 ||
+		stats.ReceivedBytes < s.last.ReceivedBytes || stats.TransmittedBytes < s.last.TransmittedBytes {
+		return 0, false
+	}
+
+	busiest := max(stats.ReceivedBytes-s.last.ReceivedBytes, stats.TransmittedBytes-s.last.TransmittedBytes)
+	bitsPerSecond := float64(busiest) * bitsPerByte / elapsed
+	return bitsPerSecond / float64(stats.Link.SpeedMbps*bitsPerMegabit) * 100, true
+}
+
+// Metadata returns the link of the last sample and, from the second sample on, its utilization.
+func (s *SysfsSampler) Metadata() map[string]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.metadata
+}
@@ -106,7 +106,7 @@ func NewLinuxNetworkDevicesFileScraper() *LinuxNetworkDevicesFileScraper {
 // - data: An io.Reader that provides the content of the network devices file (e.g., /proc/net/dev).
 //
 // Returns:
-// - networkStats: A struct containing the received and transmitted bytes for the specified network interface.
+// - networkStats: A struct containing the received and transmitted bytes and packets for the specified network interface.
 // - error: An error if the specified network interface is not found or if there are issues parsing the data.
 func (s *LinuxNetworkDevicesFileScraper) Scrape(data io.Reader) (networkStats NetworkStats, err error) {
 	// Create a new scanner to read the data line by line
@@ -124,13 +124,29 @@ func (s *LinuxNetworkDevicesFileScraper) Scrape(data io.Reader) (networkStats Ne
 			// Parse the received bytes (second field)
 			receivedBytes, _ := strconv.ParseUint(fields[1], 10, 64)
 
+			// Parse the received packets (third field)
+			receivedPackets, _ := strconv.ParseUint(fields[2], 10, 64)
+
+			// Parse the received packets dropped (fifth field)
+			receivedDropped, _ := strconv.ParseUint(fields[4], 10, 64)
+
 			// Parse the transmitted bytes (tenth field)
 			transmittedBytes, _ := strconv.ParseUint(fields[9], 10, 64)
 
+			// Parse the transmitted packets (eleventh field)
+			transmittedPackets, _ := strconv.ParseUint(fields[10], 10, 64)
+
+			// Parse the transmitted packets dropped (thirteenth field)
+			transmittedDropped, _ := strconv.ParseUint(fields[12], 10, 64)
+
 			// Return the parsed network statistics
 			return NetworkStats{
-				ReceivedBytes:    receivedBytes,
-				TransmittedBytes: transmittedBytes,
+				ReceivedBytes:      receivedBytes,
+				TransmittedBytes:   transmittedBytes,
+				ReceivedPackets:    receivedPackets,
+				TransmittedPackets: transmittedPackets,
+				ReceivedDropped:    receivedDropped,
+				TransmittedDropped: transmittedDropped,
 			}, nil
 		}
 	}
@@ -2,6 +2,7 @@ package scraper
 
 import (
 	"os"
+	"strings"
 	"testing"
 )
 
@@ -29,6 +30,34 @@ func TestLinuxNetworkStatsScraper(t *testing.T) {
 		const wantedTransmitBytes = 1982736
 		assertExpectedNetUsageBytes("testdata/eth0_test.data", t, wantedReceivedBytes, wantedTransmitBytes, "lo")
 	})
+
+	t.Run("Dropped packets parsed from the line of the interface", func(t *testing.T) {
+		data := "  eth2: 100 2 0 7 0 0 0 0 200 3 0 9 0 0 0 0\n"
+
+		networkStats, err := NewLinuxNetworkDevicesFileScraperWithInterface("eth2").Scrape(strings.NewReader(data))
+		if err != nil {
+			t.Errorf("Error on scraping the net stats: %s", err.Error())
+		}
+		if networkStats.ReceivedDropped != 7 || networkStats.TransmittedDropped != 9 {
+			t.Errorf("Error on dropped packets. Expected: 7/9, Got: %d/%d", networkStats.ReceivedDropped, networkStats.TransmittedDropped)
+		}
+	})
+
+	t.Run("Packets parsed from the file with default interface", func(t *testing.T) {
+		f, err := os.Open("testdata/eth0_test.data")
+		if err != nil {
+			t.Fatalf("The following error occurred on retrieving the test file: %s", err.Error())
+		}
+		defer f.Close()
+
+		networkStats, err := NewLinuxNetworkDevicesFileScraper().Scrape(f)
+		if err != nil {
+			t.Errorf("Error on scraping the net stats: %s", err.Error())
+		}
+		if networkStats.ReceivedPackets != 5723894 || networkStats.TransmittedPackets != 4186353 {
+			t.Errorf("Error on packets. Expected: 5723894/4186353, Got: %d/%d", networkStats.ReceivedPackets, networkStats.TransmittedPackets)
+		}
+	})
 }
 
 func assertExpectedNetUsageBytes(testFile string, t *testing.T, wantedReceivedBytes uint64, wantedTransmitBytes uint64, interfaceName string) {
@@ -0,0 +1,167 @@
+package scraper
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Netlink message and attribute types, as defined by the Linux uapi headers.
+const (
+	nlmsgError  = 0x2
+	nlmsgDone   = 0x3
+	rtmNewLink  = 0x10
+	iflaIfname  = 0x3
+	iflaStats64 = 0x17
+
+	nlmsgHeaderLen    = 16
+	ifInfoMsgLen      = 16
+	rtAttrHeaderLen   = 4
+	rxPacketsOffset   = 0
+	txPacketsOffset   = 8
+	rxBytesOffset     = 16
+	txBytesOffset     = 24
+	rxDroppedOffset   = 48
+	txDroppedOffset   = 56
+	linkStats64MinLen = txDroppedOffset + 8
+)
+
+// NetlinkScraper is a scraper for the RTM_NEWLINK messages answering a rtnetlink RTM_GETLINK dump
+// request, reading the 64 bit IFLA_STATS64 counters of an interface.
+type NetlinkScraper struct {
+	InterfaceName string
+}
+
+// NewNetlinkScraperWithInterface creates a NetlinkScraper for the interface, "eth0" if empty.
+func NewNetlinkScraperWithInterface(interfaceName string) *NetlinkScraper {
+	if interfaceName == "" {
+		interfaceName = "eth0"
+	}
+	return &NetlinkScraper{
+		InterfaceName: interfaceName,
+	}
+}
+
+// Scrape reads netlink messages in host byte order from data, up to NLMSG_DONE, and returns the
+// received and transmitted bytes and packets of the interface.
+func (s *NetlinkScraper) Scrape(data io.Reader) (networkStats NetworkStats, err error) {
+	found := false
+	err = readLinks(data, func(name string, stats NetworkStats) bool {
+		if name == s.InterfaceName {
+			networkStats, found = stats, true
+		}
+		return !found
+	})
+	if found {
+		return networkStats, nil
+	}
+	if err != nil {
+		return NetworkStats{}, err
+	}
+	return NetworkStats{}, fmt.Errorf("interface '%s' not found in netlink messages", s.InterfaceName)
+}
+
+// ScrapeLinks reads netlink messages in host byte order from data, up to NLMSG_DONE, and returns the
+// statistics of every interface, by name, so that a single dump samples all of them.
+func ScrapeLinks(data io.Reader) (map[string]NetworkStats, error) {
+	links := map[string]NetworkStats{}
+	err := readLinks(data, func(name string, stats NetworkStats) bool {
+		links[name] = stats
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return links, nil
+}
+
+// readLinks calls link with the name and statistics of every interface described by the messages, up to
+// NLMSG_DONE or until link returns false.
+func readLinks(data io.Reader, link func(name string, stats NetworkStats) bool) error {
+	messages, err := io.ReadAll(data)
+	if err != nil {
+		return err
+	}
+
+	for len(messages) >= nlmsgHeaderLen {
+		msgLen := int(binary.NativeEndian.Uint32(messages[0:4]))
+		msgType := binary.NativeEndian.Uint16(messages[4:6])
+		if msgLen < nlmsgHeaderLen || msgLen > len(messages) {
+			return fmt.Errorf("malformed netlink message of %d bytes", msgLen)
+		}
+
+		switch msgType {
+		case nlmsgDone:
+			return nil
+		case nlmsgError:
+			if msgLen >= nlmsgHeaderLen+4 {
+				if errno := int32(binary.NativeEndian.Uint32(messages[nlmsgHeaderLen:])); errno != 0 {
+					return fmt.Errorf("netlink error %d", -errno)
+				}
+			}
+		case rtmNewLink:
+			name, stats, ok := parseLink(messages[nlmsgHeaderLen:msgLen])
+			if ok && !link(name, stats) {
+				return nil
+			}
+		}
+		messages = messages[align(msgLen):]
+	}
+
+	return errors.New("netlink messages end without NLMSG_DONE")
+}
+
+// parseLink returns the name and statistics of the interface described by a RTM_NEWLINK payload.
+func parseLink(payload []byte) (name string, stats NetworkStats, ok bool) {
+	if len(payload) < ifInfoMsgLen {
+		return "", NetworkStats{}, false
+	}
+	attrs := payload[ifInfoMsgLen:]
+	hasStats := false
+	for len(attrs) >= rtAttrHeaderLen {
+		attrLen := int(binary.NativeEndian.Uint16(attrs[0:2]))
+		attrType := binary.NativeEndian.Uint16(attrs[2:4])
+		if attrLen < rtAttrHeaderLen || attrLen > len(attrs) {
+			return "", NetworkStats{}, false
+		}
+		value := attrs[rtAttrHeaderLen:attrLen]
+
+		switch attrType {
+		case iflaIfname:
+			name = string(trimNull(value))
+		case iflaStats64:
+			if len(value) >= linkStats64MinLen {
+				stats = NetworkStats{
+					ReceivedBytes:      binary.NativeEndian.Uint64(value[rxBytesOffset:]),
+					TransmittedBytes:   binary.NativeEndian.Uint64(value[txBytesOffset:]),
+					ReceivedPackets:    binary.NativeEndian.Uint64(value[rxPacketsOffset:]),
+					TransmittedPackets: binary.NativeEndian.Uint64(value[txPacketsOffset:]),
+					ReceivedDropped:    binary.NativeEndian.Uint64(value[rxDroppedOffset:]),
+					TransmittedDropped: binary.NativeEndian.Uint64(value[txDroppedOffset:]),
+				}
+				hasStats = true
+			}
+		}
+
+		if align(attrLen) >= len(attrs) {
+			break
+		}
+		attrs = attrs[align(attrLen):]
+	}
+	return name, stats, name != "" && hasStats
+}
+
+// align rounds a netlink length up to the 4 bytes alignment of messages and attributes.
+func align(length int) int {
+	return (length + 3) &^ 3
+}
+
+func trimNull(b []byte) []byte {
+	for i, c := range b {
+		if c == 0 {
+			return b[:i]
+		}
+	}
+	return b
+}
@@ -0,0 +1,131 @@
+package scraper
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"strings"
+	"testing"
+)
+
+// rtm_newlink_dump.bin is a RTM_GETLINK dump recorded on a little endian host with the lo, ifb0, ifb1
+// and eth0 interfaces.
+const netlinkDumpFile = "testdata/rtm_newlink_dump.bin"
+
+func TestNetlinkScraper(t *testing.T) {
+	if binary.NativeEndian.Uint16([]byte{1, 0}) != 1 {
+		t.Skip("the recorded netlink messages are little endian")
+	}
+
+	t.Run("Network stats parsed from the messages with default interface", func(t *testing.T) {
+		assertExpectedNetlinkUsageBytes(t, 8793668, 84897, "")
+	})
+
+	t.Run("Network stats parsed from the messages with lo interface", func(t *testing.T) {
+		assertExpectedNetlinkUsageBytes(t, 43779933, 43779933, "lo")
+	})
+
+	t.Run("Network stats parsed from the messages with idle interface", func(t *testing.T) {
+		assertExpectedNetlinkUsageBytes(t, 0, 0, "ifb1")
+	})
+
+	t.Run("Packets parsed from the messages with default interface", func(t *testing.T) {
+		f, err := os.Open(netlinkDumpFile)
+		if err != nil {
+			t.Fatalf("The following error occurred on retrieving the test file: %s", err.Error())
+		}
+		defer f.Close()
+
+		networkStats, err := NewNetlinkScraperWithInterface("").Scrape(f)
+		if err != nil {
+			t.Errorf("Error on scraping the net stats: %s", err.Error())
+		}
+		if networkStats.ReceivedPackets != 697 || networkStats.TransmittedPackets != 792 {
+			t.Errorf("Error on packets. Expected: 697/792, Got: %d/%d", networkStats.ReceivedPackets, networkStats.TransmittedPackets)
+		}
+	})
+
+	t.Run("when the interface is not in the messages an error is raised", func(t *testing.T) {
+		f, err := os.Open(netlinkDumpFile)
+		if err != nil {
+			t.Fatalf("The following error occurred on retrieving the test file: %s", err.Error())
+		}
+		defer f.Close()
+
+		_, err = NewNetlinkScraperWithInterface("wlan0").Scrape(f)
+		if err == nil || !strings.Contains(err.Error(), "'wlan0' not found") {
+			t.Errorf("Expected a not found error, got %v", err)
+		}
+	})
+
+	t.Run("when the dump is truncated an error is raised", func(t *testing.T) {
+		data, err := os.ReadFile(netlinkDumpFile)
+		if err != nil {
+			t.Fatalf("The following error occurred on retrieving the test file: %s", err.Error())
+		}
+
+		_, err = NewNetlinkScraperWithInterface("eth0").Scrape(bytes.NewReader(data[:len(data)-100]))
+		if err == nil {
+			t.Errorf("An error was expected but err was nil")
+		}
+	})
+
+	t.Run("when the kernel answers with an error it is raised", func(t *testing.T) {
+		msg := make([]byte, 36)
+		binary.NativeEndian.PutUint32(msg[0:4], uint32(len(msg)))
+		binary.NativeEndian.PutUint16(msg[4:6], nlmsgError)
+		errno := int32(-1)
+		binary.NativeEndian.PutUint32(msg[16:20], uint32(errno))
+
+		_, err := NewNetlinkScraperWithInterface("eth0").Scrape(bytes.NewReader(msg))
+		if err == nil || err.Error() != "netlink error 1" {
+			t.Errorf("Expected a netlink error, got %v", err)
+		}
+	})
+}
+
+func TestScrapeLinks(t *testing.T) {
+	if binary.NativeEndian.Uint16([]byte{1, 0}) != 1 {
+		t.Skip("the recorded netlink messages are little endian")
+	}
+	data, err := os.ReadFile(netlinkDumpFile)
+	if err != nil {
+		t.Fatalf("The following error occurred on retrieving the test file: %s", err.Error())
+	}
+
+	links, err := ScrapeLinks(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Error on scraping the links: %s", err.Error())
+	}
+	if len(links) != 4 {
+		t.Errorf("Expected the lo, ifb0, ifb1 and eth0 links, got %v", links)
+	}
+	if eth0 := links["eth0"]; eth0.ReceivedBytes != 8793668 || eth0.TransmittedBytes != 84897 {
+		t.Errorf("Error on eth0 bytes. Expected: 8793668/84897, Got: %d/%d", eth0.ReceivedBytes, eth0.TransmittedBytes)
+	}
+
+	if _, err := ScrapeLinks(bytes.NewReader(data[:len(data)-100])); err == nil {
+		t.Errorf("An error was expected for a truncated dump but err was nil")
+	}
+}
+
+func assertExpectedNetlinkUsageBytes(t *testing.T, wantedReceivedBytes uint64, wantedTransmitBytes uint64, interfaceName string) {
+	f, err := os.Open(netlinkDumpFile)
+	if err != nil {
+		t.Fatalf("The following error occurred on retrieving the test file: %s", err.Error())
+	}
+	defer f.Close()
+
+	networkStats, err := NewNetlinkScraperWithInterface(interfaceName).Scrape(f)
+	if err != nil {
+		t.Errorf("Error on scraping the net stats: %s", err.Error())
+	}
+
+	if networkStats.ReceivedBytes != wantedReceivedBytes {
+		t.Errorf("Error on received bytes. Expected: %d, Got: %d", wantedReceivedBytes, networkStats.ReceivedBytes)
+	}
+
+	if networkStats.TransmittedBytes != wantedTransmitBytes {
+		t.Errorf("Error on transmitted bytes. Expected: %d, Got: %d", wantedTransmitBytes, networkStats.TransmittedBytes)
+	}
+}
@@ -11,6 +11,18 @@ type NetworkStats struct {
 
 	// TransmittedBytes holds the number of bytes transmitted over the network.
 	TransmittedBytes uint64
+
+	// ReceivedPackets holds the number of packets received over the network.
+	ReceivedPackets uint64
+
+	// TransmittedPackets holds the number of packets transmitted over the network.
+	TransmittedPackets uint64
+
+	// ReceivedDropped holds the number of received packets dropped.
+	ReceivedDropped uint64
+
+	// TransmittedDropped holds the number of packets dropped before being transmitted.
+	TransmittedDropped uint64
 }
 
 // NetworkStatsScraper defines an interface for scraping network stats data from an io.Reader.
@@ -0,0 +1,137 @@
+package scraper
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// InterfaceKind classifies a network interface by what carries its traffic.
+type InterfaceKind string
+
+const (
+	// KindPhysical is an interface backed by a device, such as a NIC.
+	KindPhysical InterfaceKind = "physical"
+	// KindLoopback is the loopback interface.
+	KindLoopback InterfaceKind = "loopback"
+	// KindBridge is a bridge, whose traffic also goes through its ports.
+	KindBridge InterfaceKind = "bridge"
+	// KindVeth is one end of a virtual ethernet pair, such as a container interface.
+	KindVeth InterfaceKind = "veth"
+	// KindBond is a bond, whose traffic also goes through its slaves.
+	KindBond InterfaceKind = "bond"
+	// KindVLAN is a VLAN on top of another interface.
+	KindVLAN InterfaceKind = "vlan"
+	// KindTunnel is a tunnel or an overlay, such as ipip, gre, vxlan or wireguard.
+	KindTunnel InterfaceKind = "tunnel"
+	// KindVirtual is any other interface without a device, such as dummy or ifb.
+	KindVirtual InterfaceKind = "virtual"
+)
+
+// ARPHRD_* hardware types of loopback and tunnel interfaces.
+const (
+	arphrdLoopback = 772
+	arphrdTunnel   = 768
+	arphrdTunnel6  = 769
+	arphrdSit      = 776
+	arphrdIPGRE    = 778
+	arphrdIP6GRE   = 823
+	arphrdNone     = 0xfffe
+)
+
+// tunnelDevTypes are the uevent DEVTYPE of overlays, which have the ethernet hardware type.
+var tunnelDevTypes = map[string]bool{
+	"vxlan":     true,
+	"geneve":    true,
+	"wireguard": true,
+	"gretap":    true,
+	"ip6gretap": true,
+}
+
+// ListInterfaces returns the names of the network interfaces below the sysfs root, in order.
+func ListInterfaces(root string) ([]string, error) {
+	if root == "" {
+		root = DefaultSysfsRoot
+	}
+	entries, err := os.ReadDir(filepath.Join(root, "class", "net"))
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// Kind classifies the interface from its sysfs attributes: its hardware type, the bridge and
+// bonding directories, the DEVTYPE of its uevent and whether it is backed by a device. An
+// interface linked to another one without a DEVTYPE is the end of a veth pair.
+func (s *SysfsScraper) Kind() (InterfaceKind, error) {
+	dir := filepath.Join(s.Root, "class", "net", s.InterfaceName)
+	if _, err := os.Stat(dir); err != nil {
+		return "", err
+	}
+
+	linkType := -1
+	if value, err := readAttribute(filepath.Join(dir, "type")); err == nil {
+		linkType, _ = strconv.Atoi(value)
+	}
+	devType := ueventDevType(filepath.Join(dir, "uevent"))
+
+	switch {
+	case linkType == arphrdLoopback:
+		return KindLoopback, nil
+	case exists(filepath.Join(dir, "bridge")) || devType == "bridge":
+		return KindBridge, nil
+	case exists(filepath.Join(dir, "bonding")) || devType == "bond":
+		return KindBond, nil
+	case devType == "vlan":
+		return KindVLAN, nil
+	case tunnelDevTypes[devType]:
+		return KindTunnel, nil
+	}
+
+	switch linkType {
+	case arphrdTunnel, arphrdTunnel6, arphrdSit, arphrdIPGRE, arphrdIP6GRE, arphrdNone:
+		return KindTunnel, nil
+	}
+
+	if exists(filepath.Join(dir, "device")) {
+		return KindPhysical, nil
+	}
+
+	ifindex, _ := readAttribute(filepath.Join(dir, "ifindex"))
+	iflink, _ := readAttribute(filepath.Join(dir, "iflink"))
+	if devType == "" && ifindex != "" && iflink != "" && ifindex != iflink {
+		return KindVeth, nil
+	}
+	return KindVirtual, nil
+}
+
+// ueventDevType returns the DEVTYPE of an uevent file, or an empty string if it has none.
+func ueventDevType(path string) string {
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if value, ok := strings.CutPrefix(scanner.Text(), "DEVTYPE="); ok {
+			return value
+		}
+	}
+	return ""
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
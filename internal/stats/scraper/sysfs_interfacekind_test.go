@@ -0,0 +1,50 @@
+package scraper
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSysfsInterfaceKind(t *testing.T) {
+	t.Run("Interfaces listed from the tree in order", func(t *testing.T) {
+		got, err := ListInterfaces(sysfsFixtureRoot)
+		if err != nil {
+			t.Fatalf("Error on listing the interfaces: %s", err.Error())
+		}
+
+		want := []string{"bond0", "br0", "dummy0", "eth0", "eth1", "eth1.100", "lo", "tunl0", "veth1a2b3c", "vxlan.calico"}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("got %v want %v", got, want)
+		}
+	})
+
+	tests := map[string]InterfaceKind{
+		"lo":           KindLoopback,
+		"eth0":         KindPhysical,
+		"eth1":         KindPhysical,
+		"br0":          KindBridge,
+		"bond0":        KindBond,
+		"eth1.100":     KindVLAN,
+		"veth1a2b3c":   KindVeth,
+		"vxlan.calico": KindTunnel,
+		"tunl0":        KindTunnel,
+		"dummy0":       KindVirtual,
+	}
+	for name, want := range tests {
+		t.Run("Interface "+name+" classified as "+string(want), func(t *testing.T) {
+			got, err := NewSysfsScraper(sysfsFixtureRoot, name).Kind()
+			if err != nil {
+				t.Fatalf("Error on classifying the interface: %s", err.Error())
+			}
+			if got != want {
+				t.Errorf("got %s want %s", got, want)
+			}
+		})
+	}
+
+	t.Run("when the interface does not exist an error is raised", func(t *testing.T) {
+		if _, err := NewSysfsScraper(sysfsFixtureRoot, "wlan0").Kind(); err == nil {
+			t.Errorf("An error was expected but err was nil")
+		}
+	})
+}
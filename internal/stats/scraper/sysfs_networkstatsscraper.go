@@ -0,0 +1,120 @@
+package scraper
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// DefaultSysfsRoot is the mount point of sysfs.
+const DefaultSysfsRoot = "/sys"
+
+// UnknownSpeed is the speed of links that do not report it, such as virtual interfaces.
+const UnknownSpeed = -1
+
+// LinkInfo describes the state of a network interface.
+type LinkInfo struct {
+	// OperState is the operational state, such as "up", "down" or "unknown".
+	OperState string
+	// SpeedMbps is the link speed in megabits per second, UnknownSpeed if not reported.
+	SpeedMbps int64
+	// MTU is the maximum transmission unit in bytes.
+	MTU int
+	// Address is the hardware address.
+	Address string
+	// Type is the ARPHRD_* hardware type, such as 1 for ethernet or 772 for loopback.
+	Type int
+}
+
+// InterfaceStats holds the statistics and the link state of a network interface.
+type InterfaceStats struct {
+	NetworkStats
+
+	Link LinkInfo
+}
+
+// SysfsScraper is a scraper for the /sys/class/net/<interface> directory of a network interface,
+// reading its statistics counters along with its operstate, speed, mtu, address and type.
+//
+// Unlike the NetworkStatsScraper implementations, which parse a single file, it reads a tree of
+// files below Root, which tests point to a fixture tree.
+type SysfsScraper struct {
+	Root          string
+	InterfaceName string
+}
+
+// NewSysfsScraper creates a SysfsScraper for the interface below root. An empty root selects
+// DefaultSysfsRoot and an empty interface "eth0".
+func NewSysfsScraper(root string, interfaceName string) *SysfsScraper {
+	if root == "" {
+		root = DefaultSysfsRoot
+	}
+	if interfaceName == "" {
+		interfaceName = "eth0"
+	}
+	return &SysfsScraper{
+		Root:          root,
+		InterfaceName: interfaceName,
+	}
+}
+
+// ScrapeInterface reads the statistics and the link state of the interface. The counters are
+// required, a link attribute that cannot be read is left empty, or UnknownSpeed for the speed.
+func (s *SysfsScraper) ScrapeInterface() (InterfaceStats, error) {
+	dir := filepath.Join(s.Root, "class", "net", s.InterfaceName)
+
+	var stats InterfaceStats
+	counters := []struct {
+		name  string
+		value *uint64
+	}{
+		{"rx_bytes", &stats.ReceivedBytes},
+		{"tx_bytes", &stats.TransmittedBytes},
+		{"rx_packets", &stats.ReceivedPackets},
+		{"tx_packets", &stats.TransmittedPackets},
+		{"rx_dropped", &stats.ReceivedDropped},
+		{"tx_dropped", &stats.TransmittedDropped},
+	}
+	for _, counter := range counters {
+		value, err := readAttribute(filepath.Join(dir, "statistics", counter.name))
+		if err != nil {
+			return InterfaceStats{}, fmt.Errorf("interface '%s': %w", s.InterfaceName, err)
+		}
+		if *counter.value, err = strconv.ParseUint(value, 10, 64); err != nil {
+			return InterfaceStats{}, fmt.Errorf("interface '%s' %s: %w", s.InterfaceName, counter.name, err)
+		}
+	}
+
+	stats.Link = s.link(dir)
+	return stats, nil
+}
+
+func (s *SysfsScraper) link(dir string) LinkInfo {
+	link := LinkInfo{SpeedMbps: UnknownSpeed}
+	link.OperState, _ = readAttribute(filepath.Join(dir, "operstate"))
+	link.Address, _ = readAttribute(filepath.Join(dir, "address"))
+	// Reading the speed of a link without one fails with EINVAL.
+	if speed, err := readAttribute(filepath.Join(dir, "speed")); err == nil {
+		if mbps, err := strconv.ParseInt(speed, 10, 64); err == nil && mbps > 0 {
+			link.SpeedMbps = mbps
+		}
+	}
+	if mtu, err := readAttribute(filepath.Join(dir, "mtu")); err == nil {
+		link.MTU, _ = strconv.Atoi(mtu)
+	}
+	if linkType, err := readAttribute(filepath.Join(dir, "type")); err == nil {
+		link.Type, _ = strconv.Atoi(linkType)
+	}
+	return link
+}
+
+// readAttribute returns the content of a sysfs attribute without the trailing newline.
+func readAttribute(path string) (string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(content)), nil
+}
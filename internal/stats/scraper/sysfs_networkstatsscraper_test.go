@@ -0,0 +1,61 @@
+package scraper
+
+import (
+	"testing"
+)
+
+const sysfsFixtureRoot = "testdata/sys"
+
+func TestSysfsScraper(t *testing.T) {
+	t.Run("Statistics and link parsed from the tree with default interface", func(t *testing.T) {
+		got, err := NewSysfsScraper(sysfsFixtureRoot, "").ScrapeInterface()
+		if err != nil {
+			t.Fatalf("Error on scraping the interface: %s", err.Error())
+		}
+
+		want := InterfaceStats{
+			NetworkStats: NetworkStats{
+				ReceivedBytes:      3862937603,
+				TransmittedBytes:   281882792,
+				ReceivedPackets:    2911731,
+				TransmittedPackets: 1468853,
+				ReceivedDropped:    12,
+				TransmittedDropped: 3,
+			},
+			Link: LinkInfo{
+				OperState: "up",
+				SpeedMbps: 1000,
+				MTU:       1500,
+				Address:   "52:54:00:12:34:56",
+				Type:      1,
+			},
+		}
+		if got != want {
+			t.Errorf("got %+v want %+v", got, want)
+		}
+	})
+
+	t.Run("Speed is unknown when the interface does not report it", func(t *testing.T) {
+		got, err := NewSysfsScraper(sysfsFixtureRoot, "lo").ScrapeInterface()
+		if err != nil {
+			t.Fatalf("Error on scraping the interface: %s", err.Error())
+		}
+
+		if got.Link.SpeedMbps != UnknownSpeed {
+			t.Errorf("Error on speed. Expected: %d, Got: %d", UnknownSpeed, got.Link.SpeedMbps)
+		}
+		if got.Link.Type != 772 || got.Link.OperState != "unknown" || got.Link.MTU != 65536 {
+			t.Errorf("Error on link. Got: %+v", got.Link)
+		}
+		if got.ReceivedBytes != 1982736 || got.TransmittedBytes != 1982736 {
+			t.Errorf("Error on bytes. Got: %+v", got.NetworkStats)
+		}
+	})
+
+	t.Run("when the interface does not exist an error is raised", func(t *testing.T) {
+		_, err := NewSysfsScraper(sysfsFixtureRoot, "wlan0").ScrapeInterface()
+		if err == nil {
+			t.Errorf("An error was expected but err was nil")
+		}
+	})
+}
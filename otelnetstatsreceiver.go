@@ -6,6 +6,7 @@ import (
 	"github.com/fsgonz/otelnetstatsreceiver/internal/file"
 	"github.com/fsgonz/otelnetstatsreceiver/internal/logsampler"
 	"github.com/fsgonz/otelnetstatsreceiver/internal/metadata"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/entry"
 	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/stanza/operator"
 	"go.opentelemetry.io/collector/component"
 	"go.opentelemetry.io/collector/receiver"
@@ -35,6 +36,12 @@ func createDefaultConfig() *OtelNetStatsReceiverConfig {
 		BaseConfig: adapter.BaseConfig{
 			Operators:      []operator.Config{},
 			RetryOnFailure: consumerretry.NewDefaultConfig(),
+			MaxBatchSize:   adapter.DefaultMaxBatchSize,
+			FlushInterval:  adapter.DefaultFlushInterval,
+			Dedup: adapter.DedupConfig{
+				KeyField:   entry.NewBodyField("id"),
+				WindowSize: adapter.DefaultDedupWindowSize,
+			},
 		},
 		InputConfig: *file.NewFileInputConfig(),
 		LogSamplerConfig: logsampler.Config{
@@ -69,7 +76,3 @@ func (f ReceiverType) InputConfig(cfg component.Config) operator.Config {
 func (f ReceiverType) LogSamplers(cfg component.Config) logsampler.Config {
 	return cfg.(*OtelNetStatsReceiverConfig).LogSamplerConfig
 }
-
-func (f ReceiverType) Input(cfg component.Config) file.Input {
-	return cfg.(*OtelNetStatsReceiverConfig).InputConfig.Input()
-}
@@ -1,9 +1,19 @@
 package otelnetstatsreceiver
 
 import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fsgonz/otelnetstatsreceiver/internal/logsampler"
 	"github.com/stretchr/testify/require"
 	"go.opentelemetry.io/collector/component/componenttest"
-	"testing"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/receiver/receivertest"
 )
 
 func TestDefaultConfig(t *testing.T) {
@@ -12,3 +22,73 @@ func TestDefaultConfig(t *testing.T) {
 	require.NotNil(t, cfg, "failed to create default config")
 	require.NoError(t, componenttest.CheckConfigStruct(cfg))
 }
+
+func TestShutdownDeliversFinalSample(t *testing.T) {
+	cfg := createDefaultConfig()
+	cfg.InputConfig.Include = []string{filepath.Join(t.TempDir(), "*.log")}
+	cfg.LogSamplerConfig.LogSamplers = []logsampler.LogSampler{fixtureSampler()}
+	// The final sample stays in the emitter batch until the next flush, which Shutdown waits for.
+	cfg.FlushInterval = time.Second
+
+	sink := new(consumertest.LogsSink)
+	rcvr, err := NewFactory().CreateLogsReceiver(context.Background(), receivertest.NewNopCreateSettings(), cfg, sink)
+	require.NoError(t, err)
+	require.NoError(t, rcvr.Start(context.Background(), componenttest.NewNopHost()))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, rcvr.Shutdown(ctx))
+	require.Equal(t, 1, sink.LogRecordCount())
+	usage, ok := sink.AllLogs()[0].ResourceLogs().At(0).ScopeLogs().At(0).LogRecords().At(0).Attributes().Get("usage_bytes")
+	require.True(t, ok)
+	require.Equal(t, int64(3862937603+281882792), usage.Int(), "the first sample reports the eth0 counters of the fixture")
+}
+
+func TestShutdownRetriesFinalSample(t *testing.T) {
+	cfg := createDefaultConfig()
+	cfg.InputConfig.Include = []string{filepath.Join(t.TempDir(), "*.log")}
+	cfg.LogSamplerConfig.LogSamplers = []logsampler.LogSampler{fixtureSampler()}
+	cfg.RetryOnFailure.Enabled = true
+	cfg.RetryOnFailure.InitialInterval = 10 * time.Millisecond
+
+	sink := &failingSink{failures: 1}
+	rcvr, err := NewFactory().CreateLogsReceiver(context.Background(), receivertest.NewNopCreateSettings(), cfg, sink)
+	require.NoError(t, err)
+	require.NoError(t, rcvr.Start(context.Background(), componenttest.NewNopHost()))
+
+	// The final sample is delivered by a retry, Shutdown does not cancel it.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, rcvr.Shutdown(ctx))
+	require.Equal(t, 1, sink.LogRecordCount())
+}
+
+// fixtureSampler samples eth0 from the sysfs fixture of the scraper tests once per hour, so the tests neither
+// depend on the interfaces of the host nor on its traffic.
+func fixtureSampler() logsampler.LogSampler {
+	return logsampler.LogSampler{
+		Metric:       "netstats",
+		Output:       "pipeline_emitter",
+		PollInterval: time.Hour,
+		Structured:   true,
+		Source:       "sysfs",
+		SysfsRoot:    filepath.Join("internal", "stats", "scraper", "testdata", "sys"),
+	}
+}
+
+// failingSink fails its first deliveries.
+type failingSink struct {
+	consumertest.LogsSink
+	mu       sync.Mutex
+	failures int
+}
+
+func (s *failingSink) ConsumeLogs(ctx context.Context, ld plog.Logs) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.failures > 0 {
+		s.failures--
+		return errors.New("downstream unavailable")
+	}
+	return s.LogsSink.ConsumeLogs(ctx, ld)
+}